@@ -0,0 +1,38 @@
+// Command genkeys generates a new key pair for signing and encrypting session
+// cookies, and prints the environment variables to configure the server.
+//
+// If SESSION_AUTH_KEY and SESSION_ENC_KEY are already set, the new key pair is
+// added in front of the existing keys. Cookies encoded with the old keys can
+// still be decoded, while new cookies are encoded with the new keys. Old keys
+// can be removed from the end of the list when they have expired.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"harmony/internal/auth/sessionstore"
+	"os"
+)
+
+func main() {
+	keep := flag.Int("keep", -1, "Maximum number of existing keys to keep. -1 keeps all")
+	flag.Parse()
+
+	existing, err := sessionstore.ParseKeyRing(
+		os.Getenv("SESSION_AUTH_KEY"),
+		os.Getenv("SESSION_ENC_KEY"),
+	)
+	if err != nil && !errors.Is(err, sessionstore.ErrNoKeys) {
+		fmt.Fprintf(os.Stderr, "Existing keys are invalid: %v\n", err)
+		os.Exit(1)
+	}
+	if *keep >= 0 && len(existing) > *keep {
+		existing = existing[:*keep]
+	}
+
+	ring := existing.Rotate(sessionstore.GenerateKeyPair())
+	authKeys, encKeys := ring.Env()
+	fmt.Printf("SESSION_AUTH_KEY=%s\n", authKeys)
+	fmt.Printf("SESSION_ENC_KEY=%s\n", encKeys)
+}
@@ -0,0 +1,45 @@
+// Command grantrole grants a role to, or revokes a role from, an existing
+// account, e.g., to create the first administrator.
+//
+//	COUCHDB_URL=... go run ./cmd/grantrole -email jd@example.com -role admin
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"harmony/internal/auth/domain"
+	"harmony/internal/auth/repo"
+	"harmony/internal/core/corerepo"
+	"os"
+)
+
+func main() {
+	email := flag.String("email", "", "Email address of the account")
+	role := flag.String("role", string(domain.RoleAdmin), "The role to grant")
+	revoke := flag.Bool("revoke", false, "Revoke the role instead of granting it")
+	flag.Parse()
+	if *email == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx := context.Background()
+	corerepo.AssertInitialized()
+	r := repo.AccountRepository{Connection: corerepo.DefaultConnection}
+	acc, err := r.FindByEmail(ctx, *email)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot find account: %v\n", err)
+		os.Exit(1)
+	}
+	if *revoke {
+		acc.RevokeRole(domain.Role(*role))
+	} else {
+		acc.GrantRole(domain.Role(*role))
+	}
+	if _, err = r.Update(ctx, acc); err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot update account: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Roles of %s: %v\n", acc.Email, acc.Roles)
+}
@@ -0,0 +1,37 @@
+// Command migrate applies pending database migrations. The server applies them
+// on startup, so the command is mainly useful with -dry-run, to see which
+// migrations are pending, and how many documents they would change.
+//
+//	COUCHDB_URL=... go run ./cmd/migrate -dry-run
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"harmony/internal/core/corerepo"
+	"os"
+
+	// Packages registering migrations
+	_ "harmony/internal/auth/repo"
+)
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "Report changes without modifying the database")
+	flag.Parse()
+
+	corerepo.AssertInitialized()
+	results, err := corerepo.DefaultConnection.Migrate(
+		context.Background(), *dryRun, corerepo.RegisteredMigrations()...,
+	)
+	for _, r := range results {
+		fmt.Printf("%d %s: %d documents changed\n", r.Version, r.Name, r.Changed)
+	}
+	if len(results) == 0 && err == nil {
+		fmt.Println("No pending migrations")
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Migration failed: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,38 @@
+// Command migrateemails moves email documents stored before email addresses
+// were normalized to their canonical ID, and reports accounts registered with
+// addresses only differing by case. Duplicates must be resolved manually.
+//
+// The server moves the documents on startup, by the migration
+// [repo.CanonicalEmailDocuments]; the command is used for the report of
+// duplicates, and to migrate again after resolving them.
+//
+//	COUCHDB_URL=... go run ./cmd/migrateemails -dry-run
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"harmony/internal/auth/repo"
+	"harmony/internal/core/corerepo"
+	"os"
+)
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "Report changes without modifying the database")
+	flag.Parse()
+
+	corerepo.AssertInitialized()
+	r := repo.AccountRepository{Connection: corerepo.DefaultConnection}
+	report, err := r.MigrateEmailDocuments(context.Background(), *dryRun)
+	for _, addr := range report.Migrated {
+		fmt.Printf("Migrated: %s\n", addr)
+	}
+	for _, dup := range report.Duplicates {
+		fmt.Printf("Duplicate: %s used by accounts %v\n", dup.Address, dup.Accounts)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Migration failed: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,55 @@
+// Command projections lists the projections and their checkpoints, or rebuilds
+// a projection from scratch from the event store, e.g., after a bug in the
+// projection has been fixed.
+//
+//	COUCHDB_URL=... go run ./cmd/projections -rebuild host.account_activity
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"harmony/internal/core/corerepo"
+	"harmony/internal/core/projection"
+	hostioc "harmony/internal/host/ioc"
+	"os"
+	"time"
+)
+
+func main() {
+	rebuild := flag.String("rebuild", "", "Name of the projection to rebuild")
+	flag.Parse()
+
+	ctx := context.Background()
+	corerepo.AssertInitialized()
+	runners := hostioc.Projections()
+
+	if *rebuild == "" {
+		for _, r := range runners {
+			name := r.Projection.Name()
+			cp, err := r.Checkpoints.Checkpoint(ctx, name)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Cannot read checkpoint of %s: %v\n", name, err)
+				os.Exit(1)
+			}
+			fmt.Printf("%s: checkpoint %s %s\n", name, cp.CreatedAt.Format(time.RFC3339), cp.EventID)
+		}
+		return
+	}
+
+	var runner *projection.Runner
+	for _, r := range runners {
+		if r.Projection.Name() == *rebuild {
+			runner = r
+		}
+	}
+	if runner == nil {
+		fmt.Fprintf(os.Stderr, "Unknown projection: %s\n", *rebuild)
+		os.Exit(2)
+	}
+	if err := runner.Rebuild(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "Rebuild failed: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Rebuilt %s\n", *rebuild)
+}
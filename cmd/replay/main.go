@@ -0,0 +1,98 @@
+// Command replay delivers historical domain events to a subscriber, e.g., to
+// resend emails after an outage. Events already processed by the subscriber
+// are skipped. Without -subscriber, the selected events are listed.
+//
+//	COUCHDB_URL=... go run ./cmd/replay -type auth.EmailValidationRequest \
+//	  -from 2026-10-19T08:00:00Z -to 2026-10-19T10:00:00Z -subscriber email
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"harmony/internal/auth"
+	"harmony/internal/auth/repo"
+	"harmony/internal/core"
+	"harmony/internal/core/corerepo"
+	"harmony/internal/core/ledger"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// processed is the ledger of events processed by the handlers of the server.
+// Replayed events already processed, e.g., emails already sent, are skipped.
+var processed = ledger.CouchDBLedger{DB: &corerepo.DefaultConnection, Clock: core.SystemClock{}}
+
+// subscribers are the handlers events can be replayed to.
+var subscribers = map[string]func() core.EventHandler{
+	"email": func() core.EventHandler {
+		return auth.EmailValidator{
+			Repository: repo.AccountRepository{Connection: corerepo.DefaultConnection},
+			Ledger:     processed,
+		}
+	},
+}
+
+func parseTime(name, value string) time.Time {
+	if value == "" {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid -%s: %v\n", name, err)
+		os.Exit(2)
+	}
+	return t
+}
+
+func main() {
+	names := slices.Sorted(maps.Keys(subscribers))
+	typ := flag.String("type", "", "Event type, e.g., auth.EmailValidationRequest")
+	aggregate := flag.String("aggregate", "", "Aggregate ID, e.g., an account ID")
+	from := flag.String("from", "", "Only events created at or after this time (RFC 3339)")
+	to := flag.String("to", "", "Only events created before this time (RFC 3339)")
+	subscriber := flag.String("subscriber", "",
+		"Subscriber receiving the events: "+strings.Join(names, ", "))
+	flag.Parse()
+
+	q := corerepo.EventQuery{
+		Type:        *typ,
+		AggregateID: *aggregate,
+		From:        parseTime("from", *from),
+		To:          parseTime("to", *to),
+	}
+	ctx := context.Background()
+	corerepo.AssertInitialized()
+	events := corerepo.DefaultDomainEventRepo
+
+	if *subscriber == "" {
+		err := events.EachEvent(ctx, q, func(e core.DomainEvent) error {
+			fmt.Printf("%s %s %s\n", e.CreatedAt.Format(time.RFC3339), e.ID, core.EventTypeName(e))
+			return nil
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Cannot read events: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	newHandler, ok := subscribers[*subscriber]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown subscriber %q, expected one of: %s\n",
+			*subscriber, strings.Join(names, ", "))
+		os.Exit(2)
+	}
+	res, err := events.Replay(ctx, q, newHandler())
+	fmt.Printf("Delivered %d events, %d failed\n", res.Delivered, res.Failed)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Replay failed: %v\n", err)
+		os.Exit(1)
+	}
+	if res.Failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -1,11 +1,18 @@
 package ioc
 
 import (
+	"context"
 	authioc "harmony/internal/auth/ioc"
+	"harmony/internal/core"
 	"harmony/internal/core/corerepo"
+	"harmony/internal/core/jobs"
+	"harmony/internal/core/lease"
+	"harmony/internal/core/ledger"
+	hostioc "harmony/internal/host/ioc"
 	"harmony/internal/messaging"
 	mioc "harmony/internal/messaging/ioc"
 	"harmony/internal/web/server"
+	"time"
 
 	"github.com/gost-dom/surgeon"
 )
@@ -13,20 +20,63 @@ import (
 type RootGraph struct {
 	Server      *server.Server
 	MessagePump messaging.MessagePump
+	Jobs        jobs.Runner
 }
 
 var Graph *surgeon.Graph[RootGraph]
 
+// Leases are shared by all server instances using the database. One message
+// pump handles events, and updates each projection, and each job runs on one
+// instance.
+var Leases = lease.CouchDBStore{DB: &corerepo.DefaultConnection, Clock: core.SystemClock{}}
+
+// leaseCleanup deletes expired leases, e.g., of stopped instances.
+var leaseCleanup = jobs.Job{
+	Name:     "lease.cleanup",
+	Interval: time.Hour,
+	Run: func(ctx context.Context) error {
+		_, err := Leases.DeleteExpired(ctx)
+		return err
+	},
+}
+
+// ledgerCleanup deletes entries of the ledger of processed events older than
+// [ledger.DefaultRetention].
+var ledgerCleanup = jobs.Job{
+	Name:     "ledger.cleanup",
+	Interval: time.Hour,
+	Run: func(ctx context.Context) error {
+		_, err := mioc.Ledger.DeleteOlderThan(ctx, ledger.DefaultRetention)
+		return err
+	},
+}
+
 func init() {
+	holder := lease.NewHolderID()
 	Graph = surgeon.BuildGraph(RootGraph{
 		server.New(),
 		messaging.MessagePump{
-			MessageSource:         corerepo.DefaultMessageSource,
-			DomainEventRepository: corerepo.DefaultDomainEventRepo,
-			Handler:               mioc.Handler(),
+			Source:      corerepo.DefaultMessageSource,
+			Events:      corerepo.DefaultDomainEventRepo,
+			Handler:     mioc.Handler(),
+			Projections: hostioc.Projections(),
+			Leases:      Leases,
+			Holder:      holder,
+		},
+		jobs.Runner{
+			Jobs:   append(authioc.Jobs(), leaseCleanup, ledgerCleanup),
+			Leases: Leases,
+			Holder: holder,
 		},
 	})
 	Graph = authioc.Install(Graph)
+	Graph = hostioc.Install(Graph)
+	// Applies migrations registered by the bounded contexts. Instances starting
+	// at the same time don't fail, as Migrate handles concurrent updates of the
+	// migration state.
+	if err := corerepo.DefaultConnection.Bootstrap(context.Background()); err != nil {
+		panic(err)
+	}
 	if err := Graph.Validate(); err != nil {
 		panic(err)
 	}
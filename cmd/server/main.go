@@ -1,11 +1,19 @@
 package main
 
 import (
+	"cmp"
 	"context"
+	"errors"
 	"harmony/cmd/server/ioc"
+	"harmony/internal/infrastructure/metrics"
+	"harmony/internal/infrastructure/trace"
 	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/golang-cz/devslog"
 )
@@ -13,17 +21,57 @@ import (
 func main() {
 	graph := ioc.Root()
 	slog.SetDefault(slog.New(devslog.NewHandler(os.Stdout, nil)))
+	trace.SetExporter(trace.LogExporter{})
 
+	// Cancelled on shutdown, stopping the pump and jobs, and releasing their
+	// leases, so other instances take over.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var stopped sync.WaitGroup
 	pump := graph.MessagePump
-	server := graph.Server
-	err := pump.Start(context.Background())
+	pump.Stopped = &stopped
+	jobs := graph.Jobs
+	jobs.Stopped = &stopped
+	server := &http.Server{Addr: "0.0.0.0:9999", Handler: graph.Server}
+	err := pump.Start(ctx)
 	if err != nil {
 		slog.Error("Error starting pump", "err", err)
 		os.Exit(1)
 	}
+	jobs.Start(ctx)
+	go serveMetrics(cmp.Or(os.Getenv("METRICS_ADDR"), defaultMetricsAddr))
+	go func() {
+		if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Error starting http server", "err", err)
+			os.Exit(1)
+		}
+	}()
 
-	if err := http.ListenAndServe("0.0.0.0:9999", server); err != nil {
-		slog.Error("Error starting http server", "err", err)
-		os.Exit(1)
+	<-ctx.Done()
+	slog.Info("Shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Error shutting down http server", "err", err)
+	}
+	stopped.Wait()
+}
+
+// shutdownTimeout is the time requests in progress have to complete when the
+// server shuts down.
+const shutdownTimeout = 10 * time.Second
+
+// defaultMetricsAddr only accepts local connections, as metrics are not
+// public.
+const defaultMetricsAddr = "127.0.0.1:9998"
+
+// serveMetrics serves metrics on an internal listener, separate from the
+// public server.
+func serveMetrics(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", metrics.Handler())
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		slog.Error("Error starting metrics server", "err", err)
 	}
 }
@@ -0,0 +1,124 @@
+// Package router serves the administration area, allowing administrators to
+// find and manage user accounts.
+package router
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"harmony/internal/admin/router/views"
+	"harmony/internal/auth"
+	"harmony/internal/auth/domain"
+	authrouter "harmony/internal/auth/router"
+	"harmony/internal/infrastructure/log"
+)
+
+type AccountAdministrator interface {
+	Search(context.Context, domain.AuthenticatedAccount, string) ([]domain.Account, error)
+	Details(
+		context.Context,
+		domain.AuthenticatedAccount,
+		domain.AccountID,
+	) (auth.AccountDetails, error)
+	VerifyEmail(context.Context, domain.AuthenticatedAccount, domain.AccountID) error
+	Lock(context.Context, domain.AuthenticatedAccount, domain.AccountID) error
+	Unlock(context.Context, domain.AuthenticatedAccount, domain.AccountID) error
+	ForcePasswordReset(context.Context, domain.AuthenticatedAccount, domain.AccountID) error
+}
+
+// AdminRouter serves the administration pages. The router is expected to be
+// mounted under /admin, and protected by [authrouter.RequirePermission].
+// Handlers modifying accounts additionally require
+// [domain.PermManageAccounts].
+type AdminRouter struct {
+	*http.ServeMux
+	Administrator AccountAdministrator
+}
+
+type adminAction = func(
+	AccountAdministrator,
+	context.Context,
+	domain.AuthenticatedAccount,
+	domain.AccountID,
+) error
+
+var actions = map[string]adminAction{
+	"verify-email":         AccountAdministrator.VerifyEmail,
+	"lock":                 AccountAdministrator.Lock,
+	"unlock":               AccountAdministrator.Unlock,
+	"force-password-reset": AccountAdministrator.ForcePasswordReset,
+}
+
+// Init implements interface [surgeon.Initer].
+func (r *AdminRouter) Init() {
+	r.ServeMux = http.NewServeMux()
+	r.HandleFunc("GET /{$}", r.getSearch)
+	r.HandleFunc("GET /accounts/{id}", r.getAccount)
+	r.Handle("POST /accounts/{id}/{action}",
+		authrouter.RequirePermission(domain.PermManageAccounts)(
+			http.HandlerFunc(r.postAction),
+		),
+	)
+}
+
+func (r *AdminRouter) getSearch(w http.ResponseWriter, req *http.Request) {
+	admin, _ := auth.AuthenticatedUser(req.Context())
+	data := views.SearchData{Query: req.URL.Query().Get("q")}
+	if data.Query != "" {
+		accounts, err := r.Administrator.Search(req.Context(), admin, data.Query)
+		if err != nil {
+			handleError(w, req, err)
+			return
+		}
+		data.Searched = true
+		data.Accounts = accounts
+		data.Limited = len(accounts) >= auth.SearchLimit
+	}
+	views.SearchPage(data).Render(req.Context(), w)
+}
+
+func (r *AdminRouter) getAccount(w http.ResponseWriter, req *http.Request) {
+	admin, _ := auth.AuthenticatedUser(req.Context())
+	id := domain.AccountID(req.PathValue("id"))
+	details, err := r.Administrator.Details(req.Context(), admin, id)
+	if err != nil {
+		handleError(w, req, err)
+		return
+	}
+	views.AccountPage(details).Render(req.Context(), w)
+}
+
+func (r *AdminRouter) postAction(w http.ResponseWriter, req *http.Request) {
+	admin, _ := auth.AuthenticatedUser(req.Context())
+	id := domain.AccountID(req.PathValue("id"))
+	action, ok := actions[req.PathValue("action")]
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+	if err := action(r.Administrator, req.Context(), admin, id); err != nil {
+		handleError(w, req, err)
+		return
+	}
+	http.Redirect(w, req, fmt.Sprintf("/admin/accounts/%s", id), http.StatusSeeOther)
+}
+
+func handleError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, auth.ErrNotFound):
+		http.NotFound(w, r)
+	case errors.Is(err, auth.ErrForbidden):
+		http.Error(w, "Forbidden", http.StatusForbidden)
+	default:
+		log.LogError(r.Context(), "admin: unexpected error", err)
+		http.Error(w, "Unexpected error", http.StatusInternalServerError)
+	}
+}
+
+func New() *AdminRouter {
+	r := new(AdminRouter)
+	r.Init()
+	return r
+}
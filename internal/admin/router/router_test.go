@@ -0,0 +1,176 @@
+package router_test
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"harmony/internal/admin/router"
+	"harmony/internal/auth"
+	"harmony/internal/auth/domain"
+	"harmony/internal/testing/domaintest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// administratorFake records the actions performed, returning err.
+type administratorFake struct {
+	accounts []domain.Account
+	err      error
+	actions  []string
+}
+
+func (a *administratorFake) Search(
+	context.Context, domain.AuthenticatedAccount, string,
+) ([]domain.Account, error) {
+	return a.accounts, a.err
+}
+
+func (a *administratorFake) Details(
+	_ context.Context, _ domain.AuthenticatedAccount, id domain.AccountID,
+) (auth.AccountDetails, error) {
+	return auth.AccountDetails{Account: domain.Account{ID: id}}, a.err
+}
+
+func (a *administratorFake) record(action string, id domain.AccountID) error {
+	a.actions = append(a.actions, fmt.Sprintf("%s %s", action, id))
+	return a.err
+}
+
+func (a *administratorFake) VerifyEmail(
+	_ context.Context, _ domain.AuthenticatedAccount, id domain.AccountID,
+) error {
+	return a.record("verify-email", id)
+}
+
+func (a *administratorFake) Lock(
+	_ context.Context, _ domain.AuthenticatedAccount, id domain.AccountID,
+) error {
+	return a.record("lock", id)
+}
+
+func (a *administratorFake) Unlock(
+	_ context.Context, _ domain.AuthenticatedAccount, id domain.AccountID,
+) error {
+	return a.record("unlock", id)
+}
+
+func (a *administratorFake) ForcePasswordReset(
+	_ context.Context, _ domain.AuthenticatedAccount, id domain.AccountID,
+) error {
+	return a.record("force-password-reset", id)
+}
+
+var actions = []string{"verify-email", "lock", "unlock", "force-password-reset"}
+
+// roleViewer is a role granting only the permission to view accounts, set up
+// by withViewerRole.
+const roleViewer domain.Role = "router_test.viewer"
+
+// withViewerRole adds roleViewer to the default policy for the duration of the
+// test.
+func withViewerRole(t *testing.T) {
+	policy := domain.DefaultPolicy
+	t.Cleanup(func() { domain.DefaultPolicy = policy })
+	domain.DefaultPolicy = maps.Clone(policy)
+	domain.DefaultPolicy[roleViewer] = []domain.Permission{domain.PermViewAccounts}
+}
+
+func initRouter() (*router.AdminRouter, *administratorFake) {
+	admin := &administratorFake{}
+	r := router.New()
+	r.Administrator = admin
+	return r, admin
+}
+
+func serve(
+	r http.Handler, method, path string, acc *domain.AuthenticatedAccount,
+) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if acc != nil {
+		auth.SetAuthenticatedUser(&req, *acc)
+	}
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestPostActionAsAdmin(t *testing.T) {
+	admin := domaintest.InitAuthenticatedAccount(domaintest.WithRole(domain.RoleAdmin))
+	for _, action := range actions {
+		t.Run(action, func(t *testing.T) {
+			r, fake := initRouter()
+			rec := serve(r, "POST", "/accounts/acc-1/"+action, &admin)
+			assert.Equal(t, http.StatusSeeOther, rec.Code)
+			assert.Equal(t, "/admin/accounts/acc-1", rec.Header().Get("Location"))
+			assert.Equal(t, []string{action + " acc-1"}, fake.actions)
+		})
+	}
+
+	t.Run("Unknown action", func(t *testing.T) {
+		r, fake := initRouter()
+		rec := serve(r, "POST", "/accounts/acc-1/delete", &admin)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+		assert.Empty(t, fake.actions)
+	})
+}
+
+func TestPostActionAsViewer(t *testing.T) {
+	withViewerRole(t)
+	viewer := domaintest.InitAuthenticatedAccount(domaintest.WithRole(roleViewer))
+	for _, action := range actions {
+		t.Run(action, func(t *testing.T) {
+			r, fake := initRouter()
+			rec := serve(r, "POST", "/accounts/acc-1/"+action, &viewer)
+			assert.Equal(t, http.StatusForbidden, rec.Code)
+			assert.Empty(t, fake.actions, "Action not performed")
+		})
+	}
+
+	t.Run("Viewing accounts", func(t *testing.T) {
+		r, _ := initRouter()
+		assert.Equal(t, http.StatusOK, serve(r, "GET", "/?q=john", &viewer).Code)
+		assert.Equal(t, http.StatusOK, serve(r, "GET", "/accounts/acc-1", &viewer).Code)
+	})
+}
+
+func TestPostActionUnauthenticated(t *testing.T) {
+	r, fake := initRouter()
+	rec := serve(r, "POST", "/accounts/acc-1/lock", nil)
+	assert.Equal(t, http.StatusSeeOther, rec.Code)
+	assert.Contains(t, rec.Header().Get("Location"), "/auth/login")
+	assert.Empty(t, fake.actions)
+}
+
+func TestPostActionErrors(t *testing.T) {
+	admin := domaintest.InitAuthenticatedAccount(domaintest.WithRole(domain.RoleAdmin))
+	for err, code := range map[error]int{
+		auth.ErrNotFound:  http.StatusNotFound,
+		auth.ErrForbidden: http.StatusForbidden,
+	} {
+		r, fake := initRouter()
+		fake.err = err
+		rec := serve(r, "POST", "/accounts/acc-1/lock", &admin)
+		assert.Equal(t, code, rec.Code, "Status for %v", err)
+	}
+}
+
+func TestSearchShowsLimitedResults(t *testing.T) {
+	admin := domaintest.InitAuthenticatedAccount(domaintest.WithRole(domain.RoleAdmin))
+	r, fake := initRouter()
+	fake.accounts = []domain.Account{domaintest.InitAccount(domaintest.WithName("John"))}
+
+	rec := serve(r, "GET", "/?q=john", &admin)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "Refine the search")
+
+	fake.accounts = make([]domain.Account, auth.SearchLimit)
+	for i := range fake.accounts {
+		fake.accounts[i] = domaintest.InitAccount(domaintest.WithName("John"))
+	}
+	rec = serve(r, "GET", "/?q=john", &admin)
+	assert.Contains(t, rec.Body.String(), "Refine the search")
+}
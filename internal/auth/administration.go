@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"harmony/internal/auth/domain"
+	"harmony/internal/auth/sessionstore"
+	"harmony/internal/core"
+)
+
+// ErrForbidden indicates that the user doesn't have permission to perform the
+// operation.
+var ErrForbidden = errors.New("auth: forbidden")
+
+type AccountAdminRepository interface {
+	Get(context.Context, domain.AccountID) (domain.Account, error)
+	Search(ctx context.Context, query string, limit int) ([]domain.Account, error)
+	UpdateWithEvents(context.Context, core.UseCaseResult[domain.Account]) (domain.Account, error)
+}
+
+type AccountSessions interface {
+	SessionsForAccount(context.Context, domain.AccountID) ([]sessionstore.SessionDoc, error)
+	RevokeAccountSessions(context.Context, domain.AccountID) error
+}
+
+// AccountDetails contains the information about an account shown to
+// administrators.
+type AccountDetails struct {
+	domain.Account
+	Sessions []sessionstore.SessionDoc
+}
+
+// AccountAdministrator implements account management for administrators.
+//
+// Every operation modifying an account emits an audit event identifying the
+// administrator performing the operation.
+type AccountAdministrator struct {
+	Repository AccountAdminRepository
+	Sessions   AccountSessions
+	Clock      core.Clock
+}
+
+func authorize(admin domain.AuthenticatedAccount, perm domain.Permission) error {
+	if !admin.Can(domain.DefaultPolicy, perm) {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// SearchLimit is the maximum number of accounts returned by
+// [AccountAdministrator.Search]. Administrators refine the query to find
+// accounts not returned.
+const SearchLimit = 50
+
+// Search finds accounts where the email, name, or display name starts with
+// the query. At most [SearchLimit] accounts are returned.
+func (a AccountAdministrator) Search(
+	ctx context.Context,
+	admin domain.AuthenticatedAccount,
+	query string,
+) ([]domain.Account, error) {
+	if err := authorize(admin, domain.PermViewAccounts); err != nil {
+		return nil, err
+	}
+	return a.Repository.Search(ctx, query, SearchLimit)
+}
+
+// Details returns the account, and its active sessions.
+func (a AccountAdministrator) Details(
+	ctx context.Context,
+	admin domain.AuthenticatedAccount,
+	id domain.AccountID,
+) (res AccountDetails, err error) {
+	if err = authorize(admin, domain.PermViewAccounts); err != nil {
+		return
+	}
+	if res.Account, err = a.Repository.Get(ctx, id); err != nil {
+		return
+	}
+	res.Sessions, err = a.Sessions.SessionsForAccount(ctx, id)
+	return
+}
+
+// VerifyEmail marks the email address of the account as verified.
+func (a AccountAdministrator) VerifyEmail(
+	ctx context.Context, admin domain.AuthenticatedAccount, id domain.AccountID,
+) error {
+	return a.update(ctx, admin, id, false, (*domain.Account).VerifyEmail)
+}
+
+// Lock prevents the account from logging in, and ends all current sessions.
+func (a AccountAdministrator) Lock(
+	ctx context.Context, admin domain.AuthenticatedAccount, id domain.AccountID,
+) error {
+	return a.update(ctx, admin, id, true, (*domain.Account).Lock)
+}
+
+// Unlock allows a locked account to log in again.
+func (a AccountAdministrator) Unlock(
+	ctx context.Context, admin domain.AuthenticatedAccount, id domain.AccountID,
+) error {
+	return a.update(ctx, admin, id, false, (*domain.Account).Unlock)
+}
+
+// ForcePasswordReset requires the user to choose a new password, and ends all
+// current sessions. The user can log in again after resetting the password
+// with a code sent to the email address, see [PasswordResetter].
+func (a AccountAdministrator) ForcePasswordReset(
+	ctx context.Context, admin domain.AuthenticatedAccount, id domain.AccountID,
+) error {
+	return a.update(ctx, admin, id, true, (*domain.Account).ForcePasswordReset)
+}
+
+// update loads the account, applies the operation, and stores the account with
+// the resulting audit event. If revokeSessions is true, all sessions of the
+// account are ended.
+func (a AccountAdministrator) update(
+	ctx context.Context,
+	admin domain.AuthenticatedAccount,
+	id domain.AccountID,
+	revokeSessions bool,
+	op func(*domain.Account, domain.AccountID, core.Clock) core.DomainEvent,
+) error {
+	if err := authorize(admin, domain.PermManageAccounts); err != nil {
+		return err
+	}
+	acc, err := a.Repository.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	res := core.UseCaseOfEntity(acc)
+	res.AddEvent(op(&res.Entity, admin.ID, a.Clock))
+	if _, err = a.Repository.UpdateWithEvents(ctx, res); err != nil {
+		return err
+	}
+	if revokeSessions {
+		err = a.Sessions.RevokeAccountSessions(ctx, id)
+	}
+	return err
+}
@@ -0,0 +1,105 @@
+package auth_test
+
+import (
+	"context"
+	"testing"
+
+	"harmony/internal/auth"
+	"harmony/internal/auth/domain"
+	"harmony/internal/auth/sessionstore"
+	"harmony/internal/testing/clocktest"
+	"harmony/internal/testing/domaintest"
+	"harmony/internal/testing/repotest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type sessionsFake struct {
+	revoked []domain.AccountID
+}
+
+func (s *sessionsFake) SessionsForAccount(
+	context.Context, domain.AccountID,
+) ([]sessionstore.SessionDoc, error) {
+	return nil, nil
+}
+
+func (s *sessionsFake) RevokeAccountSessions(_ context.Context, id domain.AccountID) error {
+	s.revoked = append(s.revoked, id)
+	return nil
+}
+
+func initAdministrator(
+	t *testing.T, acc *domain.Account,
+) (auth.AccountAdministrator, *AccountRepositoryStub, *sessionsFake) {
+	repo := NewAccountRepositoryStub(t, acc)
+	sessions := &sessionsFake{}
+	return auth.AccountAdministrator{Repository: repo, Sessions: sessions}, repo, sessions
+}
+
+func TestAccountAdministratorRequiresPermission(t *testing.T) {
+	acc := domaintest.InitAccount()
+	admin, repo, _ := initAdministrator(t, &acc)
+	user := domaintest.InitAuthenticatedAccount()
+
+	_, err := admin.Search(t.Context(), user, "")
+	assert.ErrorIs(t, err, auth.ErrForbidden)
+	assert.ErrorIs(t, admin.Lock(t.Context(), user, acc.ID), auth.ErrForbidden)
+	assert.False(t, acc.Locked)
+	assert.Empty(t, repo.Events)
+}
+
+func TestAccountAdministratorLock(t *testing.T) {
+	acc := domaintest.InitAccount(domaintest.WithEmailValidation())
+	admin, repo, sessions := initAdministrator(t, &acc)
+	clock := clocktest.New()
+	admin.Clock = clock
+	adminAcc := domaintest.InitAuthenticatedAccount(domaintest.WithRole(domain.RoleAdmin))
+
+	assert.NoError(t, admin.Lock(t.Context(), adminAcc, acc.ID))
+	assert.True(t, acc.Locked)
+	if assert.Len(t, repo.Events, 1) {
+		assert.Equal(t, clock.Now(), repo.Events[0].CreatedAt, "Time of the clock")
+	}
+	assert.Equal(t, []domain.AccountID{acc.ID}, sessions.revoked, "Sessions revoked")
+	event := repotest.SingleEventOfType[domain.AccountLocked](repo)
+	assert.Equal(t, domain.AccountLocked{AccountID: acc.ID, AdminID: adminAcc.ID}, event)
+	_, err := acc.Authenticated()
+	assert.ErrorIs(t, err, domain.ErrAccountLocked)
+
+	assert.NoError(t, admin.Unlock(t.Context(), adminAcc, acc.ID))
+	assert.False(t, acc.Locked)
+	repotest.SingleEventOfType[domain.AccountUnlocked](repo)
+}
+
+func TestAccountAdministratorVerifyEmail(t *testing.T) {
+	acc := domaintest.InitAccount()
+	admin, repo, _ := initAdministrator(t, &acc)
+	adminAcc := domaintest.InitAuthenticatedAccount(domaintest.WithRole(domain.RoleAdmin))
+
+	assert.NoError(t, admin.VerifyEmail(t.Context(), adminAcc, acc.ID))
+	assert.True(t, acc.Validated())
+	repotest.SingleEventOfType[domain.EmailVerifiedByAdmin](repo)
+}
+
+func TestAccountAdministratorForcePasswordReset(t *testing.T) {
+	acc := domaintest.InitAccount(domaintest.WithEmailValidation())
+	admin, repo, sessions := initAdministrator(t, &acc)
+	adminAcc := domaintest.InitAuthenticatedAccount(domaintest.WithRole(domain.RoleAdmin))
+
+	assert.NoError(t, admin.ForcePasswordReset(t.Context(), adminAcc, acc.ID))
+	_, err := acc.Authenticated()
+	assert.ErrorIs(t, err, domain.ErrPasswordResetRequired)
+	assert.Equal(t, []domain.AccountID{acc.ID}, sessions.revoked, "Sessions revoked")
+	repotest.SingleEventOfType[domain.PasswordResetForced](repo)
+}
+
+func TestAccountAdministratorSearch(t *testing.T) {
+	acc := domaintest.InitAccount(domaintest.WithName("John Smith"))
+	admin, _, _ := initAdministrator(t, &acc)
+	adminAcc := domaintest.InitAuthenticatedAccount(domaintest.WithRole(domain.RoleAdmin))
+
+	res, err := admin.Search(t.Context(), adminAcc, "john")
+	assert.NoError(t, err)
+	assert.Equal(t, []domain.Account{acc}, res)
+}
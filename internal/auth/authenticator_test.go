@@ -37,7 +37,7 @@ func (s *AuthenticatorTestSuite) SetupTest() {
 	input.Password = password.Parse("valid_password")
 	repo := NewPWAuthRepositoryStub(s.T())
 
-	assert.NoError(s.T(), auth.Registrator{repo}.Register(s.Context(), input))
+	assert.NoError(s.T(), auth.Registrator{Repository: repo}.Register(s.Context(), input))
 
 	s.Authenticator = auth.Authenticator{repo}
 	s.Account = repo.Single()
@@ -56,7 +56,7 @@ func (s *AuthenticatorTestSuite) TestAuthenticateUnvalidatedAccount() {
 }
 
 func (s *AuthenticatorTestSuite) validateAccount() {
-	s.Assert().NoError(s.Account.ValidateEmail(s.Account.Email.Challenge.Code))
+	s.Assert().NoError(s.Account.ValidateEmail(s.Account.Email.Challenge.Code, nil))
 	s.T().Helper()
 	s.Assert().True(s.Account.Email.Validated)
 }
@@ -3,6 +3,8 @@ package auth
 import (
 	"context"
 	"harmony/internal/auth/domain"
+	"harmony/internal/core"
+	"harmony/internal/infrastructure/log"
 )
 
 // Auth-related context values
@@ -45,7 +47,22 @@ type Contexter[T any] interface {
 }
 
 // SetAuthenticatedUser stores an authenticated user in the request context.
+// The account is the actor of domain events created in the context, and is
+// added to log entries.
 func SetAuthenticatedUser[T Contexter[T]](r *T, acc domain.AuthenticatedAccount) {
 	ctx := context.WithValue((*r).Context(), CtxKeyAuthAccount, acc)
+	ctx = core.WithActor(ctx, string(acc.ID))
+	ctx = log.With(ctx, "actor", acc.ID)
 	*r = (*r).WithContext(ctx)
 }
+
+// UserHasPermission returns whether the authenticated user has been granted
+// the permission by [domain.DefaultPolicy]. Returns false if no user is
+// authenticated.
+//
+// This is intended for views, e.g., only rendering links to pages the user has
+// access to. Handlers should be protected by the RequirePermission middleware.
+func UserHasPermission(ctx context.Context, perm domain.Permission) bool {
+	acc, ok := AuthenticatedUser(ctx)
+	return ok && acc.Can(domain.DefaultPolicy, perm)
+}
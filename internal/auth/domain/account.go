@@ -1,9 +1,11 @@
 package domain
 
 import (
+	"crypto/subtle"
 	"errors"
-	"harmony/internal/core"
 	"harmony/internal/auth/domain/password"
+	"harmony/internal/core"
+	"time"
 )
 
 // ErrAccountNotValidated is returned when an action requires the account
@@ -11,6 +13,18 @@ import (
 // can successfully authenticate.
 var ErrAccountNotValidated = errors.New("Account not validated")
 
+// ErrAccountLocked is returned when authenticating with an account that has
+// been locked by an administrator.
+var ErrAccountLocked = errors.New("Account locked")
+
+// ErrPasswordResetRequired is returned when authenticating with an account
+// that must reset the password before it can be used again.
+var ErrPasswordResetRequired = errors.New("Password reset required")
+
+// ErrEmailAlreadyRegistered is returned when registering an email address
+// that belongs to an existing account.
+var ErrEmailAlreadyRegistered = errors.New("Email address already registered")
+
 type AccountID string
 
 var NewID = core.NewID
@@ -21,6 +35,17 @@ type Account struct {
 	Email       Email
 	Name        string
 	DisplayName string
+	Roles       []Role
+	// Locked accounts cannot be authenticated.
+	Locked bool
+	// PasswordResetRequired indicates that the user must choose a new password
+	// before the account can be authenticated.
+	PasswordResetRequired bool
+	// PasswordReset is the challenge sent to the email address when the user
+	// has requested to reset the password.
+	PasswordReset *EmailChallenge `json:",omitempty"`
+	// RegisteredAt is the time the account was registered.
+	RegisteredAt time.Time `json:",omitzero"`
 }
 
 // Validated returns if the account has been validated. E.g., if the user has
@@ -30,9 +55,9 @@ func (a Account) Validated() bool {
 }
 
 // ValidateEmail is the email "challenge response" for the email validation
-// code.
-func (a *Account) ValidateEmail(code EmailValidationCode) (err error) {
-	a.Email, err = a.Email.ChallengeResponse(code)
+// code. The clock decides if the challenge has expired.
+func (a *Account) ValidateEmail(code EmailValidationCode, clock core.Clock) (err error) {
+	a.Email, err = a.Email.ChallengeResponse(code, clock)
 	return
 }
 
@@ -47,19 +72,130 @@ func (a *Account) Authenticated() (AuthenticatedAccount, error) {
 	if !a.Email.Validated {
 		return res, ErrAccountNotValidated
 	}
+	if a.Locked {
+		return res, ErrAccountLocked
+	}
+	if a.PasswordResetRequired {
+		return res, ErrPasswordResetRequired
+	}
 	res.Account = a
 	return res, nil
 }
 
-func (a *Account) StartEmailValidationChallenge() core.DomainEvent {
-	challenge := a.Email.NewChallenge()
-	return core.NewDomainEvent(EmailValidationRequest{
+func (a *Account) StartEmailValidationChallenge(clock core.Clock) core.DomainEvent {
+	challenge := a.Email.NewChallenge(clock)
+	return core.NewDomainEventAt(clock, EmailValidationRequest{
+		AccountID:  a.ID,
+		Code:       challenge.Code,
+		ValidUntil: challenge.NotAfter,
+	})
+}
+
+// RegistrationAbandoned returns whether the account was registered more than
+// maxAge ago at the time of the clock, and the email address is still not
+// validated. Accounts without a registration time are never abandoned.
+func (a Account) RegistrationAbandoned(clock core.Clock, maxAge time.Duration) bool {
+	if a.Validated() || a.RegisteredAt.IsZero() {
+		return false
+	}
+	return core.Now(clock).Sub(a.RegisteredAt) > maxAge
+}
+
+// AbandonRegistration returns the event published when an abandoned
+// registration is removed. See [Account.RegistrationAbandoned].
+func (a Account) AbandonRegistration(clock core.Clock) core.DomainEvent {
+	return core.NewDomainEventAt(clock, AccountRegistrationAbandoned{AccountID: a.ID})
+}
+
+// ScheduleEmailValidationReminder creates an event reminding the user to
+// validate the email address, if not done within
+// [EmailValidationReminderDelay].
+func (a Account) ScheduleEmailValidationReminder(clock core.Clock) core.DomainEvent {
+	return core.NewScheduledEvent(
+		clock,
+		EmailValidationReminder{AccountID: a.ID},
+		core.Now(clock).Add(EmailValidationReminderDelay),
+	)
+}
+
+/* -------- Administration -------- */
+
+// VerifyEmail marks the email address as verified without a challenge
+// response, e.g., when an administrator has verified ownership through other
+// means.
+func (a *Account) VerifyEmail(by AccountID, clock core.Clock) core.DomainEvent {
+	a.Email = a.Email.Verified()
+	return core.NewDomainEventAt(clock, EmailVerifiedByAdmin{AccountID: a.ID, AdminID: by})
+}
+
+// Lock prevents the account from being authenticated.
+func (a *Account) Lock(by AccountID, clock core.Clock) core.DomainEvent {
+	a.Locked = true
+	return core.NewDomainEventAt(clock, AccountLocked{AccountID: a.ID, AdminID: by})
+}
+
+// Unlock allows a locked account to be authenticated again.
+func (a *Account) Unlock(by AccountID, clock core.Clock) core.DomainEvent {
+	a.Locked = false
+	return core.NewDomainEventAt(clock, AccountUnlocked{AccountID: a.ID, AdminID: by})
+}
+
+// ForcePasswordReset requires the user to choose a new password before the
+// account can be authenticated again.
+func (a *Account) ForcePasswordReset(by AccountID, clock core.Clock) core.DomainEvent {
+	a.PasswordResetRequired = true
+	return core.NewDomainEventAt(clock, PasswordResetForced{AccountID: a.ID, AdminID: by})
+}
+
+/* -------- Password reset -------- */
+
+// StartPasswordReset sends a code to the email address, which the user must
+// provide to choose a new password. The code expires [EmailChallengeDuration]
+// after the time of the clock.
+func (a *Account) StartPasswordReset(clock core.Clock) core.DomainEvent {
+	challenge := newEmailChallenge(clock)
+	challenge.Code = NewPasswordResetCode()
+	a.PasswordReset = &challenge
+	return core.NewDomainEventAt(clock, PasswordResetRequested{
 		AccountID:  a.ID,
 		Code:       challenge.Code,
 		ValidUntil: challenge.NotAfter,
 	})
 }
 
+// ResetPassword sets a new password, if the code matches the password reset
+// challenge, and clears a reset required by an administrator. Returns
+// [ErrBadEmailChallengeResponse] if no reset was started, or the code is
+// wrong, and [ErrEmailChallengeExpired] if the code has expired.
+//
+// A wrong code is counted on the challenge, which must be stored. After
+// [MaxChallengeAttempts] wrong codes, the challenge is discarded, and
+// [ErrTooManyChallengeAttempts] is returned.
+func (a *PasswordAuthentication) ResetPassword(
+	code EmailValidationCode, hash password.PasswordHash, clock core.Clock,
+) (core.DomainEvent, error) {
+	if a.PasswordReset == nil {
+		return core.DomainEvent{}, ErrBadEmailChallengeResponse
+	}
+	challenge := *a.PasswordReset
+	if subtle.ConstantTimeCompare([]byte(challenge.Code), []byte(code)) != 1 {
+		challenge.FailedAttempts++
+		if challenge.FailedAttempts >= MaxChallengeAttempts {
+			a.PasswordReset = nil
+			return core.DomainEvent{}, ErrTooManyChallengeAttempts
+		}
+		a.PasswordReset = &challenge
+		return core.DomainEvent{}, ErrBadEmailChallengeResponse
+	}
+	if challenge.Expired(clock) {
+		return core.DomainEvent{}, ErrEmailChallengeExpired
+	}
+	a.PasswordHash = hash
+	a.PasswordReset = nil
+	a.PasswordResetRequired = false
+	return core.NewDomainEventAt(clock, PasswordResetCompleted{AccountID: a.ID}), nil
+}
+
 /* -------- PasswordAuthentication -------- */
 
 // PasswordAuthentication represents an account and it's associated password.
@@ -0,0 +1,61 @@
+package domain
+
+import "slices"
+
+// Role is a named set of permissions that can be granted to an account.
+type Role string
+
+// Permission represents the right to perform a specific kind of operation.
+// Code that needs to verify access should check for a permission, not a role,
+// so the mapping of roles to permissions can change without affecting the
+// code.
+type Permission string
+
+const (
+	RoleAdmin Role = "admin"
+)
+
+const (
+	PermViewAccounts   Permission = "accounts:view"
+	PermManageAccounts Permission = "accounts:manage"
+)
+
+// Policy maps roles to the permissions they grant.
+type Policy map[Role][]Permission
+
+// DefaultPolicy is the authorization policy for the application.
+var DefaultPolicy = Policy{
+	RoleAdmin: {PermViewAccounts, PermManageAccounts},
+}
+
+// Allows returns whether any of the roles grant the permission.
+func (p Policy) Allows(roles []Role, perm Permission) bool {
+	for _, r := range roles {
+		if slices.Contains(p[r], perm) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasRole returns whether the role has been granted to the account.
+func (a Account) HasRole(r Role) bool { return slices.Contains(a.Roles, r) }
+
+// GrantRole adds the role to the account. Granting an already granted role has
+// no effect.
+func (a *Account) GrantRole(r Role) {
+	if !a.HasRole(r) {
+		a.Roles = append(a.Roles, r)
+	}
+}
+
+// RevokeRole removes the role from the account.
+func (a *Account) RevokeRole(r Role) {
+	a.Roles = slices.DeleteFunc(a.Roles, func(role Role) bool { return role == r })
+}
+
+// Can returns whether the authenticated account has the permission according
+// to the policy.
+func (a AuthenticatedAccount) Can(p Policy, perm Permission) bool {
+	return a.Account != nil && p.Allows(a.Roles, perm)
+}
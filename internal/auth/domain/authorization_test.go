@@ -0,0 +1,31 @@
+package domain_test
+
+import (
+	"testing"
+
+	"harmony/internal/auth/domain"
+	"harmony/internal/testing/domaintest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDefaultPolicy(t *testing.T) {
+	user := domaintest.InitAuthenticatedAccount()
+	admin := domaintest.InitAuthenticatedAccount(domaintest.WithRole(domain.RoleAdmin))
+
+	assert.False(t, user.Can(domain.DefaultPolicy, domain.PermManageAccounts))
+	assert.True(t, admin.Can(domain.DefaultPolicy, domain.PermManageAccounts))
+	assert.False(t, domain.AuthenticatedAccount{}.Can(
+		domain.DefaultPolicy, domain.PermManageAccounts,
+	), "Zero value has no permissions")
+}
+
+func TestGrantAndRevokeRole(t *testing.T) {
+	acc := domaintest.InitAccount()
+	acc.GrantRole(domain.RoleAdmin)
+	acc.GrantRole(domain.RoleAdmin)
+	assert.Equal(t, []domain.Role{domain.RoleAdmin}, acc.Roles, "Roles are only granted once")
+
+	acc.RevokeRole(domain.RoleAdmin)
+	assert.False(t, acc.HasRole(domain.RoleAdmin))
+}
@@ -0,0 +1,50 @@
+package domain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"harmony/internal/core"
+	"time"
+)
+
+// ErrEmailRecentlyDeleted is returned when registering an email address that
+// belonged to an account deleted within the [TombstonePeriod].
+var ErrEmailRecentlyDeleted = errors.New("Email address belongs to a recently deleted account")
+
+// TombstonePeriod is how long the email address of a deleted account is blocked
+// from being registered again. This prevents users from deleting and
+// recreating accounts to escape, e.g., a locked account.
+const TombstonePeriod = 30 * 24 * time.Hour
+
+// Tombstone is kept when an account has been deleted. It contains a hash of the
+// email address rather than the address itself, as no personal information may
+// be kept after deleting the account.
+type Tombstone struct {
+	EmailHash string    `json:"email_hash"`
+	DeletedAt time.Time `json:"deleted_at"`
+	Rev       string    `json:"-"`
+}
+
+// HashEmail returns the hash of an email address used to identify tombstones.
+// The hash is calculated from the [CanonicalAddress].
+func HashEmail(address string) string {
+	sum := sha256.Sum256([]byte(CanonicalAddress(address)))
+	return hex.EncodeToString(sum[:])
+}
+
+// Active returns whether the tombstone still blocks registration of the email
+// address at the time of the clock.
+func (t Tombstone) Active(clock core.Clock) bool {
+	return core.Now(clock).Sub(t.DeletedAt) < TombstonePeriod
+}
+
+// Delete marks the account as deleted by the user, returning the tombstone to
+// keep, and the AccountDeleted event.
+func (a Account) Delete(clock core.Clock) (Tombstone, core.DomainEvent) {
+	tombstone := Tombstone{
+		EmailHash: HashEmail(a.Email.String()),
+		DeletedAt: core.Now(clock).UTC(),
+	}
+	return tombstone, core.NewDomainEventAt(clock, AccountDeleted{AccountID: a.ID})
+}
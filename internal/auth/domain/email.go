@@ -2,6 +2,7 @@ package domain
 
 import (
 	"errors"
+	"harmony/internal/core"
 	"net/mail"
 	"strings"
 	"time"
@@ -17,21 +18,48 @@ var ErrEmailChallengeExpired = errors.New(
 	"authdomain: email challenge response has expired",
 )
 
+// ErrTooManyChallengeAttempts is returned when a challenge has been answered
+// wrong too many times. The challenge is discarded, and a new must be started.
+var ErrTooManyChallengeAttempts = errors.New(
+	"authdomain: too many challenge attempts",
+)
+
+// MaxChallengeAttempts is the number of wrong responses to a password reset
+// challenge before it is discarded.
+const MaxChallengeAttempts = 5
+
+// EmailChallengeDuration is the time the owner of an email address has to
+// complete a challenge.
+const EmailChallengeDuration = 15 * time.Minute
+
 type EmailValidationCode string
 
 func NewValidationCode() EmailValidationCode {
 	return EmailValidationCode(gonanoid.MustGenerate("0123456789", 6))
 }
 
+// PasswordResetCodeAlphabet has the characters of password reset codes,
+// leaving out characters easily mistaken for others, e.g., 0 and O.
+const PasswordResetCodeAlphabet = "23456789ABCDEFGHJKLMNPQRSTUVWXYZ"
+
+// NewPasswordResetCode returns a code for resetting a password. The code is
+// longer than a validation code, as it grants access to an existing account.
+func NewPasswordResetCode() EmailValidationCode {
+	return EmailValidationCode(gonanoid.MustGenerate(PasswordResetCodeAlphabet, 12))
+}
+
 // An email "challenge", i.e., a randomly generated code sent to an email
 // address that the owner must provide as a "challenge response" to prove
 // ownership of the email address.
 type EmailChallenge struct {
 	Code     EmailValidationCode
 	NotAfter time.Time // A deadline for completing the challenge
+	// FailedAttempts counts wrong responses to the challenge
+	FailedAttempts int `json:",omitempty"`
 }
 
-func (c EmailChallenge) Expired() bool { return time.Now().After(c.NotAfter) }
+// Expired returns whether the deadline has passed at the time of the clock.
+func (c EmailChallenge) Expired(clock core.Clock) bool { return core.Now(clock).After(c.NotAfter) }
 
 // Email is a value object encapsulating the complexities of email address
 // validation through a challenge.
@@ -41,11 +69,21 @@ type Email struct {
 	Challenge *EmailChallenge
 }
 
+// CanonicalAddress returns the normalized form of an email address used to
+// identify accounts. Addresses are treated as case insensitive, so
+// JD@example.com and jd@example.com belong to the same account.
+func CanonicalAddress(address string) string {
+	return strings.ToLower(strings.TrimSpace(address))
+}
+
 // Equals returns true of the two emails have the same address.
 func (e Email) Equals(address string) bool {
-	return strings.EqualFold(e.Address.Address, address) && address != ""
+	return e.Canonical() == CanonicalAddress(address) && address != ""
 }
 
+// Canonical returns the canonical form of the address. See [CanonicalAddress].
+func (e Email) Canonical() string { return CanonicalAddress(e.Address.Address) }
+
 func (e Email) String() string { return e.Address.Address }
 
 // ChallengeResponse processes a challenge response and returns a validated Email if
@@ -54,31 +92,42 @@ func (e Email) String() string { return e.Address.Address }
 //
 //   - [ErrBadEmailValidationCode] if the validation code was wrong
 //   - [ErrEmailChallengeExpired] if the validation code has expired
-func (e Email) ChallengeResponse(response EmailValidationCode) (Email, error) {
+func (e Email) ChallengeResponse(response EmailValidationCode, clock core.Clock) (Email, error) {
 	if e.Validated {
 		return e, nil
 	}
 	if e.Challenge != nil && e.Challenge.Code == response {
-		if e.Challenge.Expired() {
+		if e.Challenge.Expired(clock) {
 			return e, ErrEmailChallengeExpired
 		}
-		res := e
-		res.Validated = true
-		res.Challenge = nil
-		return res, nil
+		return e.Verified(), nil
 	}
 	return e, ErrBadEmailChallengeResponse
 }
 
-func (e *Email) NewChallenge() EmailChallenge {
-	challenge := EmailChallenge{
-		Code:     NewValidationCode(),
-		NotAfter: time.Now().Add(15 * time.Minute).UTC(),
-	}
+// Verified returns a validated Email without requiring a challenge response.
+func (e Email) Verified() Email {
+	res := e
+	res.Validated = true
+	res.Challenge = nil
+	return res
+}
+
+// NewChallenge starts a new challenge, with a deadline
+// [EmailChallengeDuration] after the time of the clock.
+func (e *Email) NewChallenge(clock core.Clock) EmailChallenge {
+	challenge := newEmailChallenge(clock)
 	e.Challenge = &challenge
 	return challenge
 }
 
+func newEmailChallenge(clock core.Clock) EmailChallenge {
+	return EmailChallenge{
+		Code:     NewValidationCode(),
+		NotAfter: core.Now(clock).Add(EmailChallengeDuration).UTC(),
+	}
+}
+
 func NewUnvalidatedEmail(address mail.Address) Email {
 	return Email{Address: address}
 }
@@ -0,0 +1,65 @@
+package domain_test
+
+import (
+	"net/mail"
+	"testing"
+	"time"
+
+	"harmony/internal/auth/domain"
+	"harmony/internal/auth/domain/password"
+	"harmony/internal/testing/clocktest"
+	"harmony/internal/testing/domaintest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEmailChallengeExpiry(t *testing.T) {
+	clock := clocktest.New()
+	email := domain.NewUnvalidatedEmail(mail.Address{Address: "jd@example.com"})
+	challenge := email.NewChallenge(clock)
+
+	clock.Advance(domain.EmailChallengeDuration)
+	assert.False(t, challenge.Expired(clock), "Expired at deadline")
+
+	clock.Advance(time.Nanosecond)
+	assert.True(t, challenge.Expired(clock), "Expired after deadline")
+	_, err := email.ChallengeResponse(challenge.Code, clock)
+	assert.ErrorIs(t, err, domain.ErrEmailChallengeExpired)
+}
+
+func TestPasswordResetAttemptLimit(t *testing.T) {
+	clock := clocktest.New()
+	acc := domaintest.InitPasswordAuthAccount(domaintest.WithEmailValidation())
+	event := acc.StartPasswordReset(clock)
+	code := event.Body.(domain.PasswordResetRequested).Code
+	assert.Len(t, code, 12)
+	hash := password.HashFromBytes([]byte("hash"))
+
+	for range domain.MaxChallengeAttempts - 1 {
+		_, err := acc.ResetPassword("WRONG", hash, clock)
+		assert.ErrorIs(t, err, domain.ErrBadEmailChallengeResponse)
+	}
+	assert.Equal(t, domain.MaxChallengeAttempts-1, acc.PasswordReset.FailedAttempts)
+
+	_, err := acc.ResetPassword("WRONG", hash, clock)
+	assert.ErrorIs(t, err, domain.ErrTooManyChallengeAttempts)
+	assert.Nil(t, acc.PasswordReset, "Challenge discarded")
+
+	_, err = acc.ResetPassword(code, hash, clock)
+	assert.ErrorIs(t, err, domain.ErrBadEmailChallengeResponse, "Correct code after limit")
+}
+
+func TestTombstoneExpiry(t *testing.T) {
+	clock := clocktest.New()
+	acc := domain.Account{
+		ID:    domain.AccountID(domain.NewID()),
+		Email: domain.NewUnvalidatedEmail(mail.Address{Address: "jd@example.com"}),
+	}
+	tombstone, _ := acc.Delete(clock)
+
+	clock.Advance(domain.TombstonePeriod - time.Nanosecond)
+	assert.True(t, tombstone.Active(clock), "Active within the tombstone period")
+
+	clock.Advance(time.Nanosecond)
+	assert.False(t, tombstone.Active(clock), "Active after the tombstone period")
+}
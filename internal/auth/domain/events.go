@@ -15,14 +15,83 @@ type EmailValidationRequest struct {
 	ValidUntil time.Time           `json:"valid_until"`
 }
 
+// EmailValidationReminderDelay is the time after registration when the owner of
+// an email address that hasn't been validated is reminded.
+const EmailValidationReminderDelay = 24 * time.Hour
+
+// EmailValidationReminder is a scheduled domain event, reminding the owner of
+// an email address to complete the validation.
+type EmailValidationReminder struct {
+	AccountID AccountID `json:"account_id"`
+}
+
+// Moot returns whether a reminder is no longer relevant for the account, i.e.,
+// the email address has been validated.
+func (r EmailValidationReminder) Moot(acc Account) bool { return acc.Validated() }
+
 // AccountRegistered is a domain event published when a new account has been
 // created.
 type AccountRegistered struct {
 	AccountID
 }
 
-func CreateAccountRegisteredEvent(account Account) core.DomainEvent {
-	return core.NewDomainEvent(AccountRegistered{AccountID: account.ID})
+func CreateAccountRegisteredEvent(account Account, clock core.Clock) core.DomainEvent {
+	return core.NewDomainEventAt(clock, AccountRegistered{AccountID: account.ID})
+}
+
+// AccountRegistrationAbandoned is published when an account was removed,
+// because the email address wasn't validated in time. The email address can be
+// registered again.
+type AccountRegistrationAbandoned struct {
+	AccountID AccountID `json:"account_id"`
+}
+
+// EmailVerifiedByAdmin is an audit event published when an administrator has
+// marked an email address as verified.
+type EmailVerifiedByAdmin struct {
+	AccountID AccountID `json:"account_id"`
+	AdminID   AccountID `json:"admin_id"`
+}
+
+// AccountLocked is an audit event published when an administrator has locked
+// an account.
+type AccountLocked struct {
+	AccountID AccountID `json:"account_id"`
+	AdminID   AccountID `json:"admin_id"`
+}
+
+// AccountUnlocked is an audit event published when an administrator has
+// unlocked an account.
+type AccountUnlocked struct {
+	AccountID AccountID `json:"account_id"`
+	AdminID   AccountID `json:"admin_id"`
+}
+
+// PasswordResetForced is an audit event published when an administrator has
+// required the user to choose a new password.
+type PasswordResetForced struct {
+	AccountID AccountID `json:"account_id"`
+	AdminID   AccountID `json:"admin_id"`
+}
+
+// PasswordResetRequested is published when the user has requested to reset
+// the password. The code is sent to the email address of the account.
+type PasswordResetRequested struct {
+	AccountID  AccountID           `json:"account_id"`
+	Code       EmailValidationCode `json:"reset_code"`
+	ValidUntil time.Time           `json:"valid_until"`
+}
+
+// PasswordResetCompleted is published when the user has chosen a new password
+// with a password reset code.
+type PasswordResetCompleted struct {
+	AccountID AccountID `json:"account_id"`
+}
+
+// AccountDeleted is published when a user has deleted their account. Handlers
+// must remove any data they keep about the account.
+type AccountDeleted struct {
+	AccountID AccountID `json:"account_id"`
 }
 
 func init() {
@@ -30,5 +99,29 @@ func init() {
 		reflect.TypeFor[EmailValidationRequest](),
 		"auth.EmailValidationRequest",
 	)
+	core.RegisterEventType(
+		reflect.TypeFor[EmailValidationReminder](),
+		"auth.EmailValidationReminder",
+	)
 	core.RegisterEventType(reflect.TypeFor[AccountRegistered](), "auth.AccountRegistered")
+	core.RegisterEventType(
+		reflect.TypeFor[AccountRegistrationAbandoned](),
+		"auth.AccountRegistrationAbandoned",
+	)
+	core.RegisterEventType(
+		reflect.TypeFor[EmailVerifiedByAdmin](),
+		"auth.EmailVerifiedByAdmin",
+	)
+	core.RegisterEventType(reflect.TypeFor[AccountLocked](), "auth.AccountLocked")
+	core.RegisterEventType(reflect.TypeFor[AccountUnlocked](), "auth.AccountUnlocked")
+	core.RegisterEventType(reflect.TypeFor[PasswordResetForced](), "auth.PasswordResetForced")
+	core.RegisterEventType(
+		reflect.TypeFor[PasswordResetRequested](),
+		"auth.PasswordResetRequested",
+	)
+	core.RegisterEventType(
+		reflect.TypeFor[PasswordResetCompleted](),
+		"auth.PasswordResetCompleted",
+	)
+	core.RegisterEventType(reflect.TypeFor[AccountDeleted](), "auth.AccountDeleted")
 }
@@ -1,17 +1,24 @@
 package auth
 
 import (
+	"cmp"
 	"context"
 	"errors"
 	"fmt"
 	"harmony/internal/auth/domain"
 	"harmony/internal/core"
+	"harmony/internal/core/ledger"
 	"net/smtp"
+	"net/url"
 	"strings"
 )
 
 const host = "harmony.example.com"
 
+// EmailValidatorLedgerName identifies the [EmailValidator] in the ledger of
+// processed events.
+const EmailValidatorLedgerName = "auth.EmailValidator"
+
 type EmailChallengeRepository interface {
 	FindByEmail(context.Context, string) (domain.Account, error)
 	Update(context.Context, domain.Account) (domain.Account, error)
@@ -19,6 +26,7 @@ type EmailChallengeRepository interface {
 
 type EmailChallengeValidator struct {
 	Repository EmailChallengeRepository
+	Clock      core.Clock
 }
 
 func (a EmailChallengeValidator) Validate(
@@ -33,7 +41,7 @@ func (a EmailChallengeValidator) Validate(
 
 	acc, err := a.Repository.FindByEmail(ctx, input.Email.Address)
 	if err == nil {
-		err = acc.ValidateEmail(input.Code)
+		err = acc.ValidateEmail(input.Code, a.Clock)
 	}
 	if err == nil {
 		acc, err = a.Repository.Update(ctx, acc)
@@ -48,21 +56,70 @@ type AccountLoader interface {
 	Get(context.Context, domain.AccountID) (domain.Account, error)
 }
 
+// EmailValidatorRepository loads the accounts of events handled by the
+// [EmailValidator], and stores new email challenges started by reminders.
+type EmailValidatorRepository interface {
+	AccountLoader
+	Update(context.Context, domain.Account) (domain.Account, error)
+}
+
+// EmailSender delivers email messages. Implemented by [SMTPSender].
+type EmailSender interface {
+	SendMail(ctx context.Context, from string, to []string, msg []byte) error
+}
+
+// SMTPSender sends email through an SMTP server, by default on
+// localhost:1025, e.g., mailhog started by docker compose.
+type SMTPSender struct {
+	Addr string
+}
+
+func (s SMTPSender) SendMail(_ context.Context, from string, to []string, msg []byte) error {
+	return smtp.SendMail(cmp.Or(s.Addr, "localhost:1025"), nil, from, to, msg)
+}
+
 type EmailValidator struct {
-	Repository AccountLoader
+	Repository EmailValidatorRepository
+	// Clock decides when email challenges started by reminders expire. The
+	// system clock is used if nil.
+	Clock core.Clock
+	// Sender delivers the emails. An [SMTPSender] is used if nil.
+	Sender EmailSender
+	// Ledger prevents sending the same email twice when an event is delivered
+	// again. Each delivery sends the email if nil.
+	Ledger ledger.Ledger
 }
 
-func NewEmailValidator() *EmailValidator { return &EmailValidator{nil} }
+func NewEmailValidator() *EmailValidator { return &EmailValidator{} }
+
+func (v EmailValidator) sender() EmailSender {
+	if v.Sender == nil {
+		return SMTPSender{}
+	}
+	return v.Sender
+}
 
 func (v EmailValidator) ProcessDomainEvent(ctx context.Context, event core.DomainEvent) error {
-	req, ok := event.Body.(domain.EmailValidationRequest)
-	if !ok { // Not an event we want to handle
+	var send func(context.Context, string, domain.Account) error
+	var accountID domain.AccountID
+	switch body := event.Body.(type) {
+	case domain.EmailValidationRequest:
+		send, accountID = v.sendChallengeEmail, body.AccountID
+	case domain.EmailValidationReminder:
+		send, accountID = v.sendReminderEmail, body.AccountID
+	case domain.PasswordResetRequested:
+		send = func(ctx context.Context, eventID string, acc domain.Account) error {
+			return v.sendPasswordResetEmail(ctx, eventID, acc, body.Code)
+		}
+		accountID = body.AccountID
+	default: // Not an event we want to handle
 		return nil
 	}
 
-	acc, err := v.Repository.Get(ctx, req.AccountID)
+	acc, err := v.Repository.Get(ctx, accountID)
 	if err == nil {
-		err = sendChallengeEmail(string(event.ID), acc)
+		err = ledger.Once(ctx, v.Ledger, EmailValidatorLedgerName, event,
+			func(ctx context.Context) error { return send(ctx, string(event.ID), acc) })
 	}
 	if err != nil {
 		err = fmt.Errorf("auth: ProcessDomainEvent: %w", err)
@@ -70,10 +127,33 @@ func (v EmailValidator) ProcessDomainEvent(ctx context.Context, event core.Domai
 	return err
 }
 
-func sendChallengeEmail(eventID string, acc domain.Account) error {
-	messageID := fmt.Sprintf("<%s@%s>", eventID, host)
+// CancelScheduledEvent cancels an [domain.EmailValidationReminder] when it is
+// moot, or the account has been deleted.
+func (v EmailValidator) CancelScheduledEvent(ctx context.Context, event core.DomainEvent) (bool, error) {
+	reminder, ok := event.Body.(domain.EmailValidationReminder)
+	if !ok {
+		return false, nil
+	}
+	acc, err := v.Repository.Get(ctx, reminder.AccountID)
+	if errors.Is(err, core.ErrNotFound) {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("auth: CancelScheduledEvent: %w", err)
+	}
+	return reminder.Moot(acc), nil
+}
+
+// sendChallengeEmail sends the code of the current email challenge. Nothing is
+// sent if the email address has been validated since the event, e.g., when the
+// event is replayed.
+func (v EmailValidator) sendChallengeEmail(
+	ctx context.Context, eventID string, acc domain.Account,
+) error {
+	if acc.Email.Validated || acc.Email.Challenge == nil {
+		return nil
+	}
 	receiver := acc.Email.Address // Yeah, net/mail.Address has an Address field
-	receiver.Name = acc.Name
 	firstName := acc.DisplayName
 	code := string(acc.Email.Challenge.Code)
 
@@ -93,9 +173,91 @@ func sendChallengeEmail(eventID string, acc domain.Account) error {
 		"",
 		"The Harmony Team.",
 	}
+	return v.sendEmail(ctx, eventID, acc,
+		"Welcome to Harmony. Please validate your email address.", bodyLines)
+}
+
+// sendReminderEmail starts a new email challenge, as the challenge sent when
+// registering has expired, and sends the new code. Nothing is sent if the email
+// address has been validated since the reminder was scheduled.
+func (v EmailValidator) sendReminderEmail(
+	ctx context.Context, eventID string, acc domain.Account,
+) error {
+	if acc.Email.Validated {
+		return nil
+	}
+	acc.Email.NewChallenge(v.Clock)
+	acc, err := v.Repository.Update(ctx, acc)
+	if err != nil {
+		return err
+	}
+	bodyLines := []string{
+		fmt.Sprintf(`Hi %s`, acc.DisplayName),
+		"",
+		"You registered at Harmony, but haven't validated your email address yet.",
+		"Until you do, you cannot log in. Use the following validation code",
+		"",
+		"    " + string(acc.Email.Challenge.Code),
+		"",
+		"",
+		"You can enter the code at the following address:",
+		"",
+		fmt.Sprintf(
+			"http://localhost:7331/auth/validate-email?email=%s",
+			url.QueryEscape(acc.Email.Address.Address),
+		),
+		"",
+		"The Harmony Team.",
+	}
+	return v.sendEmail(ctx, eventID, acc,
+		"Reminder: Please validate your email address.", bodyLines)
+}
+
+// sendPasswordResetEmail sends the password reset code. Nothing is sent if the
+// code is no longer valid, i.e., the reset was completed, or a new code was
+// requested since the event.
+func (v EmailValidator) sendPasswordResetEmail(
+	ctx context.Context, eventID string, acc domain.Account, code domain.EmailValidationCode,
+) error {
+	if acc.PasswordReset == nil || acc.PasswordReset.Code != code {
+		return nil
+	}
+	bodyLines := []string{
+		fmt.Sprintf(`Hi %s`, acc.DisplayName),
+		"",
+		"A password reset was requested for your Harmony account. Use the",
+		"following code to choose a new password",
+		"",
+		"    " + string(code),
+		"",
+		"",
+		"You can enter the code at the following address:",
+		"",
+		fmt.Sprintf(
+			"http://localhost:7331/auth/reset-password/confirm?email=%s",
+			url.QueryEscape(acc.Email.Address.Address),
+		),
+		"",
+		"If you didn't request a password reset, you can ignore this email.",
+		"",
+		"The Harmony Team.",
+	}
+	return v.sendEmail(ctx, eventID, acc, "Reset your Harmony password", bodyLines)
+}
+
+func (v EmailValidator) sendEmail(
+	ctx context.Context,
+	eventID string,
+	acc domain.Account,
+	subject string,
+	bodyLines []string,
+) error {
+	messageID := fmt.Sprintf("<%s@%s>", eventID, host)
+	receiver := acc.Email.Address
+	receiver.Name = acc.Name
 	body := strings.Join(bodyLines, "\r\n")
 	msg := []byte("To: " + receiver.Address + "\r\n" +
-		"Subject: Welcome to Harmony. Please validate your email address.\r\n" +
+		"Subject: " + subject + "\r\n" +
 		"From: info@harmony.example.com\r\n" +
 		fmt.Sprintf("To: %s\r\n", receiver.String()) +
 		fmt.Sprintf("Message-ID: %s\r\n", messageID) +
@@ -104,9 +266,8 @@ func sendChallengeEmail(eventID string, acc domain.Account) error {
 		"\r\n")
 
 	// Send the email
-	return smtp.SendMail(
-		"localhost:1025",
-		nil,
+	return v.sender().SendMail(
+		ctx,
 		"info@harmony.example.com",
 		[]string{receiver.Address},
 		msg,
@@ -37,7 +37,7 @@ func TestEmailValidatorValidate(t *testing.T) {
 
 	t.Run("Passing an invalid code", func(t *testing.T) {
 		acc := domaintest.InitAccount(domaintest.WithEmailAddress(addr))
-		acc.StartEmailValidationChallenge()
+		acc.StartEmailValidationChallenge(nil)
 		repo := NewAccountRepositoryStub(t, &acc)
 		validator := auth.EmailChallengeValidator{Repository: repo}
 
@@ -53,12 +53,12 @@ func TestEmailValidatorValidate(t *testing.T) {
 
 	t.Run("Passing an invalid email", func(t *testing.T) {
 		acc := domaintest.InitAccount(domaintest.WithEmailAddress(addr))
-		acc.StartEmailValidationChallenge()
+		acc.StartEmailValidationChallenge(nil)
 		repo := NewAccountRepositoryStub(t, &acc)
 		validator := auth.EmailChallengeValidator{Repository: repo}
 
 		em := domaintest.InitEmail()
-		em.NewChallenge()
+		em.NewChallenge(nil)
 		got, err := validator.Validate(t.Context(), auth.ValidateEmailInput{
 			Email: &em.Address,
 			Code:  em.Challenge.Code,
@@ -71,7 +71,7 @@ func TestEmailValidatorValidate(t *testing.T) {
 
 	t.Run("Passing the valid code", func(t *testing.T) {
 		acc := domaintest.InitAccount(domaintest.WithEmailAddress(addr))
-		acc.StartEmailValidationChallenge()
+		acc.StartEmailValidationChallenge(nil)
 		repo := NewAccountRepositoryStub(t, &acc)
 		validator := auth.EmailChallengeValidator{Repository: repo}
 
@@ -98,11 +98,11 @@ func TestSendEmailValidationChallenge(t *testing.T) {
 		acc.DisplayName = "John"
 		acc.Name = "John Smith"
 	})
-	event := acc.StartEmailValidationChallenge()
+	event := acc.StartEmailValidationChallenge(nil)
 	assert.False(t, acc.Validated(), "guard: account should be an invalidated account")
 
 	domainEvents := domainEvt{}
-	graph := surgeon.Replace[auth.AccountLoader](ioc.Graph, NewAccountRepositoryStub(t, &acc))
+	graph := surgeon.Replace[auth.EmailValidatorRepository](ioc.Graph, NewAccountRepositoryStub(t, &acc))
 	graph = surgeon.Replace[messaging.DomainEventUpdater](graph, domainEvents)
 	v := graph.Instance()
 
@@ -116,6 +116,30 @@ func TestSendEmailValidationChallenge(t *testing.T) {
 	assert.NotNil(t, domainEvents[event.ID].PublishedAt, "Domain event marked as published")
 }
 
+func TestEmailValidatorSkipsEmailsNoLongerValid(t *testing.T) {
+	ctx := t.Context()
+	acc := domaintest.InitPasswordAuthAccount()
+	validation := acc.StartEmailValidationChallenge(nil)
+	reminder := core.NewDomainEvent(domain.EmailValidationReminder{AccountID: acc.ID})
+	firstReset := acc.StartPasswordReset(nil)
+	acc.VerifyEmail(domain.AccountID("admin"), nil)
+	secondReset := acc.StartPasswordReset(nil)
+
+	repo := NewPWAuthRepositoryStub(t)
+	repo.Inject(&acc)
+	sender := &recipientsSender{}
+	v := auth.EmailValidator{Repository: pwAuthAccounts{repo}, Sender: sender}
+
+	// E.g., when the events are replayed
+	assert.NoError(t, v.ProcessDomainEvent(ctx, validation), "Validated email")
+	assert.NoError(t, v.ProcessDomainEvent(ctx, reminder), "Reminder of validated email")
+	assert.NoError(t, v.ProcessDomainEvent(ctx, firstReset), "Replaced reset code")
+	assert.Empty(t, sender.recipients)
+
+	assert.NoError(t, v.ProcessDomainEvent(ctx, secondReset))
+	assert.Equal(t, []string{acc.Email.String()}, sender.recipients, "Current reset code")
+}
+
 func TestIntegrationSendEmailValidationChallenge(t *testing.T) {
 	// This test should be more general. This verifies that domain events marked
 	// as published are not returned when starting a channel of unpublished
@@ -127,14 +151,14 @@ func TestIntegrationSendEmailValidationChallenge(t *testing.T) {
 
 	acc1 := domaintest.InitAccount()
 	acc2 := domaintest.InitAccount()
-	event1 := acc1.StartEmailValidationChallenge()
-	event2 := acc2.StartEmailValidationChallenge()
+	event1 := acc1.StartEmailValidationChallenge(nil)
+	event2 := acc2.StartEmailValidationChallenge(nil)
 
 	event1, err1 := corerepo.DefaultDomainEventRepo.Insert(ctx, event1)
 	event2, err2 := corerepo.DefaultDomainEventRepo.Insert(ctx, event2)
 	assert.NoError(t, errors.Join(err1, err2))
 
-	graph := surgeon.Replace[auth.AccountLoader](ioc.Graph, NewAccountRepositoryStub(t, &acc1))
+	graph := surgeon.Replace[auth.EmailValidatorRepository](ioc.Graph, NewAccountRepositoryStub(t, &acc1))
 	v := graph.Instance()
 
 	assert.NoError(t, v.ProcessDomainEvent(t.Context(), event1))
@@ -10,6 +10,22 @@ import (
 // import path
 var ErrAccountNotValidated = domain.ErrAccountNotValidated
 
+// ErrAccountLocked is re-exported from authdom so callers need a single import
+// path
+var ErrAccountLocked = domain.ErrAccountLocked
+
+// ErrPasswordResetRequired is re-exported from authdom so callers need a single
+// import path
+var ErrPasswordResetRequired = domain.ErrPasswordResetRequired
+
+// ErrEmailAlreadyRegistered is re-exported from authdom so callers need a
+// single import path
+var ErrEmailAlreadyRegistered = domain.ErrEmailAlreadyRegistered
+
+// ErrEmailRecentlyDeleted is re-exported from authdom so callers need a single
+// import path
+var ErrEmailRecentlyDeleted = domain.ErrEmailRecentlyDeleted
+
 // ErrNotFound is re-exported from core so callers need a single import path
 var ErrNotFound = core.ErrNotFound
 
@@ -20,3 +36,7 @@ var ErrBadCredentials = errors.New("auth: bad credentials")
 // ErrBadChallengeResponse indicates that the email validation challenge failed
 // with a bad code.
 var ErrBadChallengeResponse = errors.New("auth: bad challenge response")
+
+// ErrTooManyAttempts indicates that a code was guessed wrong too many times.
+// The user must request a new code, or wait before trying again.
+var ErrTooManyAttempts = errors.New("auth: too many attempts")
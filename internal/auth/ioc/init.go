@@ -1,39 +1,115 @@
 package ioc
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	adminrouter "harmony/internal/admin/router"
 	"harmony/internal/auth"
 	"harmony/internal/auth/repo"
 	"harmony/internal/auth/router"
 	"harmony/internal/auth/sessionstore"
+	"harmony/internal/core"
 	"harmony/internal/core/corerepo"
+	"harmony/internal/core/jobs"
+	"harmony/internal/infrastructure/env"
 	"os"
+	"time"
 
 	"github.com/gost-dom/surgeon"
 )
 
 func Install[T any](graph *surgeon.Graph[T]) *surgeon.Graph[T] {
+	graph.Inject(core.SystemClock{})
 	graph = surgeon.Replace[router.Registrator](graph, &auth.Registrator{})
 	graph = surgeon.Replace[router.Authenticator](graph, &auth.Authenticator{})
 	graph = surgeon.Replace[router.EmailValidator](graph, &auth.EmailChallengeValidator{})
+	graph = surgeon.Replace[router.AccountSelfService](graph, &auth.AccountSelfService{})
+	graph = surgeon.Replace[router.PasswordResetter](graph, &auth.PasswordResetter{})
+	graph = surgeon.Replace[adminrouter.AccountAdministrator](
+		graph, &auth.AccountAdministrator{},
+	)
 
-	authKey := os.Getenv("SESSION_AUTH_KEY")
-	encKey := os.Getenv("SESSION_ENC_KEY")
-	if authKey == "" && encKey == "" {
-		// Fallback values for development.
-		authKey = "authkey1234"
-		encKey = "enckey12341234567890123456789012"
+	keys, err := sessionKeys()
+	if err != nil {
+		panic(err)
 	}
-
-	graph.Inject(sessionstore.NewCouchDBStore(
-		&corerepo.DefaultConnection,
-		[][]byte{
-			[]byte(authKey),
-			[]byte(encKey),
-		},
-	))
+	store := sessionstore.NewCouchDBStore(&corerepo.DefaultConnection, keys)
+	if err := store.Bootstrap(context.Background()); err != nil {
+		panic(err)
+	}
+	graph.Inject(store)
 	repo := &repo.AccountRepository{
 		Connection: corerepo.DefaultConnection,
 	}
+	if err := repo.Bootstrap(context.Background()); err != nil {
+		panic(err)
+	}
 	graph = surgeon.ReplaceAll(graph, repo)
 	return graph
 }
+
+// sessionKeys reads the session key ring from the SESSION_AUTH_KEY and
+// SESSION_ENC_KEY environment variables. If neither is set, the development
+// keys are used, unless running in production.
+func sessionKeys() (sessionstore.KeyRing, error) {
+	keys, err := sessionstore.ParseKeyRing(
+		os.Getenv("SESSION_AUTH_KEY"),
+		os.Getenv("SESSION_ENC_KEY"),
+	)
+	if errors.Is(err, sessionstore.ErrNoKeys) {
+		if env.Production() {
+			return nil, errors.New(
+				"auth: session keys must be configured in production. Run cmd/genkeys",
+			)
+		}
+		return sessionstore.DevelopmentKeys, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("auth: invalid session keys: %w", err)
+	}
+	return keys, nil
+}
+
+// RegistrationCleanupInterval is how often abandoned registrations are
+// removed.
+const RegistrationCleanupInterval = time.Hour
+
+// Jobs returns the background jobs of the auth context.
+func Jobs() []jobs.Job {
+	maxAge, err := abandonedRegistrationAge()
+	if err != nil {
+		panic(err)
+	}
+	cleanup := auth.RegistrationCleanup{
+		Repository: repo.AccountRepository{Connection: corerepo.DefaultConnection},
+		Clock:      core.SystemClock{},
+		MaxAge:     maxAge,
+	}
+	return []jobs.Job{{
+		Name:     "auth.registration_cleanup",
+		Interval: RegistrationCleanupInterval,
+		Run: func(ctx context.Context) error {
+			_, err := cleanup.Run(ctx)
+			return err
+		},
+	}}
+}
+
+// abandonedRegistrationAge reads the time users have to validate the email
+// address from the ABANDONED_REGISTRATION_AGE environment variable, e.g.,
+// "72h". If not set, [auth.DefaultAbandonedRegistrationAge] is used.
+func abandonedRegistrationAge() (time.Duration, error) {
+	v := os.Getenv("ABANDONED_REGISTRATION_AGE")
+	if v == "" {
+		return auth.DefaultAbandonedRegistrationAge, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err == nil && d <= 0 {
+		err = errors.New("must be positive")
+	}
+	if err != nil {
+		return 0, fmt.Errorf("auth: invalid ABANDONED_REGISTRATION_AGE: %w", err)
+	}
+	return d, nil
+}
@@ -0,0 +1,151 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"harmony/internal/auth/domain"
+	"harmony/internal/auth/domain/password"
+	"harmony/internal/core"
+	"harmony/internal/core/ratelimit"
+	"time"
+)
+
+type PasswordResetRepository interface {
+	FindPWAuthByEmail(ctx context.Context, email string) (domain.PasswordAuthentication, error)
+	UpdateWithEvents(context.Context, core.UseCaseResult[domain.Account]) (domain.Account, error)
+	UpdatePassword(
+		context.Context, core.UseCaseResult[domain.PasswordAuthentication],
+	) (domain.PasswordAuthentication, error)
+}
+
+// PasswordResetter lets users choose a new password, proving ownership of the
+// email address with a code sent to it. This is the only way to use an account
+// after an administrator has forced a password reset.
+type PasswordResetter struct {
+	Repository PasswordResetRepository
+	Sessions   AccountSessions
+	Clock      core.Clock
+	// AccountLimiter limits reset requests and attempts for each account, and
+	// AddressLimiter limits attempts for each client address. If nil, limiters
+	// shared by all resetters are used, allowing [ResetAttemptsPerAccount] and
+	// [ResetAttemptsPerAddress] in each [ResetAttemptWindow].
+	AccountLimiter *ratelimit.Limiter
+	AddressLimiter *ratelimit.Limiter
+}
+
+// Rate limits of password resets. An account gets more attempts than allowed
+// by a single code, as the user may request a new code.
+const (
+	ResetAttemptsPerAccount = 10
+	ResetAttemptsPerAddress = 30
+	ResetAttemptWindow      = 15 * time.Minute
+)
+
+var (
+	defaultAccountLimiter = ratelimit.New(ResetAttemptsPerAccount, ResetAttemptWindow)
+	defaultAddressLimiter = ratelimit.New(ResetAttemptsPerAddress, ResetAttemptWindow)
+)
+
+type ResetPasswordInput struct {
+	Email    string
+	Code     domain.EmailValidationCode
+	Password password.Password
+	// RemoteAddr is the address of the client, for rate limiting. May be empty,
+	// in which case only attempts for the account are limited.
+	RemoteAddr string
+}
+
+// allow returns whether another request or attempt is allowed for the account
+// and client address, either of which may be empty. Both limits are counted,
+// even if one is exceeded.
+func (r PasswordResetter) allow(id domain.AccountID, remoteAddr string) bool {
+	accounts, addresses := r.AccountLimiter, r.AddressLimiter
+	if accounts == nil {
+		accounts = defaultAccountLimiter
+	}
+	if addresses == nil {
+		addresses = defaultAddressLimiter
+	}
+	allowed := true
+	if id != "" {
+		allowed = accounts.Allow(string(id))
+	}
+	if remoteAddr != "" {
+		allowed = addresses.Allow(remoteAddr) && allowed
+	}
+	return allowed
+}
+
+// RequestReset sends a password reset code to the email address. If no account
+// has the address, nothing is sent, and no error is returned, not revealing
+// which addresses are registered.
+//
+// Returns [ErrTooManyAttempts] if too many codes have been requested for the
+// account recently.
+func (r PasswordResetter) RequestReset(ctx context.Context, email string) error {
+	acc, err := r.Repository.FindPWAuthByEmail(ctx, email)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !r.allow(acc.ID, "") {
+		return ErrTooManyAttempts
+	}
+	res := core.UseCaseOfEntity(acc.Account)
+	res.AddEvent(res.Entity.StartPasswordReset(r.Clock))
+	_, err = r.Repository.UpdateWithEvents(ctx, res)
+	return err
+}
+
+// ResetPassword sets the new password, if the code matches the code sent to
+// the email address, and ends all sessions of the account.
+//
+// Returns [ErrBadChallengeResponse] if the email address or code is wrong, and
+// [domain.ErrEmailChallengeExpired] if the code has expired. Returns
+// [ErrTooManyAttempts] if the code was wrong too many times, in which case a
+// new code must be requested, or if too many attempts were made recently for
+// the account or from the client address.
+func (r PasswordResetter) ResetPassword(ctx context.Context, input ResetPasswordInput) error {
+	acc, err := r.Repository.FindPWAuthByEmail(ctx, input.Email)
+	if errors.Is(err, ErrNotFound) {
+		if !r.allow("", input.RemoteAddr) {
+			return ErrTooManyAttempts
+		}
+		return ErrBadChallengeResponse
+	}
+	if err != nil {
+		return err
+	}
+	if !r.allow(acc.ID, input.RemoteAddr) {
+		return ErrTooManyAttempts
+	}
+	hash, err := input.Password.Hash()
+	if err != nil {
+		return err
+	}
+	res := core.UseCaseOfEntity(acc)
+	event, err := res.Entity.ResetPassword(input.Code, hash, r.Clock)
+	if errors.Is(err, domain.ErrBadEmailChallengeResponse) ||
+		errors.Is(err, domain.ErrTooManyChallengeAttempts) {
+		// Store the failed attempt on the challenge
+		if _, updateErr := r.Repository.UpdateWithEvents(
+			ctx, core.UseCaseOfEntity(res.Entity.Account),
+		); updateErr != nil {
+			return updateErr
+		}
+		if errors.Is(err, domain.ErrTooManyChallengeAttempts) {
+			return ErrTooManyAttempts
+		}
+		return ErrBadChallengeResponse
+	}
+	if err != nil {
+		return err
+	}
+	res.AddEvent(event)
+	if _, err = r.Repository.UpdatePassword(ctx, res); err != nil {
+		return err
+	}
+	return r.Sessions.RevokeAccountSessions(ctx, acc.ID)
+}
@@ -0,0 +1,146 @@
+package auth_test
+
+import (
+	"testing"
+	"time"
+
+	"harmony/internal/auth"
+	"harmony/internal/auth/domain"
+	"harmony/internal/auth/domain/password"
+	"harmony/internal/core/ratelimit"
+	"harmony/internal/testing/clocktest"
+	"harmony/internal/testing/domaintest"
+	"harmony/internal/testing/repotest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func initPasswordResetter(
+	t *testing.T, acc *domain.PasswordAuthentication,
+) (auth.PasswordResetter, *PWAuthRepositoryStub, *sessionsFake, *clocktest.FakeClock) {
+	repo := NewPWAuthRepositoryStub(t)
+	repo.Inject(acc)
+	sessions := &sessionsFake{}
+	clock := clocktest.New()
+	accounts := ratelimit.New(auth.ResetAttemptsPerAccount, auth.ResetAttemptWindow)
+	addresses := ratelimit.New(auth.ResetAttemptsPerAddress, auth.ResetAttemptWindow)
+	accounts.Clock, addresses.Clock = clock, clock
+	return auth.PasswordResetter{
+		Repository:     repo,
+		Sessions:       sessions,
+		Clock:          clock,
+		AccountLimiter: accounts,
+		AddressLimiter: addresses,
+	}, repo, sessions, clock
+}
+
+func TestPasswordResetAfterForcedReset(t *testing.T) {
+	ctx := t.Context()
+	acc := domaintest.InitPasswordAuthAccount(
+		domaintest.WithEmailValidation(), domaintest.WithPassword("0ld s3cret"),
+	)
+	acc.ForcePasswordReset(domain.AccountID("admin"), nil)
+	resetter, repo, sessions, _ := initPasswordResetter(t, &acc)
+	authenticator := auth.Authenticator{Repository: repo}
+	_, err := authenticator.Authenticate(ctx, acc.Email.String(), password.Parse("0ld s3cret"))
+	assert.ErrorIs(t, err, auth.ErrPasswordResetRequired)
+
+	assert.NoError(t, resetter.RequestReset(ctx, acc.Email.String()))
+	requested := repotest.SingleEventOfType[domain.PasswordResetRequested](repo)
+	assert.Equal(t, acc.ID, requested.AccountID)
+
+	assert.NoError(t, resetter.ResetPassword(ctx, auth.ResetPasswordInput{
+		Email:    acc.Email.String(),
+		Code:     requested.Code,
+		Password: password.Parse("n3w s3cret"),
+	}))
+	repotest.SingleEventOfType[domain.PasswordResetCompleted](repo)
+	assert.Equal(t, []domain.AccountID{acc.ID}, sessions.revoked, "Sessions revoked")
+
+	_, err = authenticator.Authenticate(ctx, acc.Email.String(), password.Parse("0ld s3cret"))
+	assert.ErrorIs(t, err, auth.ErrBadCredentials, "Old password")
+	_, err = authenticator.Authenticate(ctx, acc.Email.String(), password.Parse("n3w s3cret"))
+	assert.NoError(t, err, "New password")
+}
+
+func TestPasswordResetWithBadCode(t *testing.T) {
+	ctx := t.Context()
+	acc := domaintest.InitPasswordAuthAccount(domaintest.WithEmailValidation())
+	resetter, repo, sessions, clock := initPasswordResetter(t, &acc)
+	assert.NoError(t, resetter.RequestReset(ctx, acc.Email.String()))
+	code := repotest.SingleEventOfType[domain.PasswordResetRequested](repo).Code
+
+	input := auth.ResetPasswordInput{
+		Email: acc.Email.String(), Code: "bad", Password: password.Parse("n3w s3cret"),
+	}
+	assert.ErrorIs(t, resetter.ResetPassword(ctx, input), auth.ErrBadChallengeResponse)
+
+	input.Email, input.Code = "unknown@example.com", code
+	assert.ErrorIs(t, resetter.ResetPassword(ctx, input), auth.ErrBadChallengeResponse)
+
+	clock.Advance(domain.EmailChallengeDuration + time.Second)
+	input.Email = acc.Email.String()
+	assert.ErrorIs(t, resetter.ResetPassword(ctx, input), domain.ErrEmailChallengeExpired)
+	assert.Empty(t, sessions.revoked)
+}
+
+func TestPasswordResetRequestForUnknownEmail(t *testing.T) {
+	acc := domaintest.InitPasswordAuthAccount()
+	resetter, repo, _, _ := initPasswordResetter(t, &acc)
+	assert.NoError(t, resetter.RequestReset(t.Context(), "unknown@example.com"))
+	assert.Empty(t, repo.Events)
+}
+
+func TestPasswordResetCodeDiscardedAfterFailedAttempts(t *testing.T) {
+	ctx := t.Context()
+	acc := domaintest.InitPasswordAuthAccount(domaintest.WithEmailValidation())
+	resetter, repo, sessions, _ := initPasswordResetter(t, &acc)
+	assert.NoError(t, resetter.RequestReset(ctx, acc.Email.String()))
+	code := repotest.SingleEventOfType[domain.PasswordResetRequested](repo).Code
+
+	input := auth.ResetPasswordInput{
+		Email: acc.Email.String(), Code: "bad", Password: password.Parse("n3w s3cret"),
+	}
+	for range domain.MaxChallengeAttempts - 1 {
+		assert.ErrorIs(t, resetter.ResetPassword(ctx, input), auth.ErrBadChallengeResponse)
+	}
+	assert.ErrorIs(t, resetter.ResetPassword(ctx, input), auth.ErrTooManyAttempts)
+
+	input.Code = code
+	assert.ErrorIs(t, resetter.ResetPassword(ctx, input), auth.ErrBadChallengeResponse,
+		"Correct code after too many attempts")
+	assert.Empty(t, sessions.revoked)
+}
+
+func TestPasswordResetRateLimit(t *testing.T) {
+	ctx := t.Context()
+	acc := domaintest.InitPasswordAuthAccount(domaintest.WithEmailValidation())
+	resetter, _, _, clock := initPasswordResetter(t, &acc)
+
+	t.Run("Requests for an account", func(t *testing.T) {
+		for range auth.ResetAttemptsPerAccount {
+			assert.NoError(t, resetter.RequestReset(ctx, acc.Email.String()))
+		}
+		assert.ErrorIs(t,
+			resetter.RequestReset(ctx, acc.Email.String()), auth.ErrTooManyAttempts)
+		clock.Advance(auth.ResetAttemptWindow)
+		assert.NoError(t, resetter.RequestReset(ctx, acc.Email.String()))
+	})
+
+	t.Run("Attempts from an address", func(t *testing.T) {
+		input := auth.ResetPasswordInput{
+			Email:      "unknown@example.com",
+			Code:       "bad",
+			Password:   password.Parse("n3w s3cret"),
+			RemoteAddr: "192.0.2.1",
+		}
+		for range auth.ResetAttemptsPerAddress {
+			assert.ErrorIs(t, resetter.ResetPassword(ctx, input), auth.ErrBadChallengeResponse)
+		}
+		assert.ErrorIs(t, resetter.ResetPassword(ctx, input), auth.ErrTooManyAttempts)
+
+		input.RemoteAddr = "192.0.2.2"
+		assert.ErrorIs(t, resetter.ResetPassword(ctx, input), auth.ErrBadChallengeResponse,
+			"Other address")
+	})
+}
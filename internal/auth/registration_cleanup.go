@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"harmony/internal/auth/domain"
+	"harmony/internal/core"
+	"harmony/internal/core/corerepo"
+	"harmony/internal/infrastructure/log"
+	"harmony/internal/infrastructure/metrics"
+	"time"
+)
+
+// DefaultAbandonedRegistrationAge is how long users have to validate the email
+// address after registering, before the account is removed.
+const DefaultAbandonedRegistrationAge = 7 * 24 * time.Hour
+
+var abandonedRegistrations = metrics.NewCounterVec(
+	"auth_abandoned_registrations_total",
+	"Number of abandoned registrations processed by the cleanup job",
+	"result",
+)
+
+type AbandonedRegistrationRepository interface {
+	EachUnvalidatedAccount(
+		ctx context.Context,
+		registeredBefore time.Time,
+		fn func(domain.Account) error,
+	) error
+	DeleteAbandonedRegistration(context.Context, core.UseCaseResult[domain.Account]) error
+}
+
+// RegistrationCleanup removes accounts where the email address wasn't
+// validated within MaxAge of registering, so the email address can be
+// registered again.
+type RegistrationCleanup struct {
+	Repository AbandonedRegistrationRepository
+	Clock      core.Clock
+	// MaxAge is the time users have to validate the email address. If zero,
+	// [DefaultAbandonedRegistrationAge] is used.
+	MaxAge time.Duration
+}
+
+func (c RegistrationCleanup) maxAge() time.Duration {
+	if c.MaxAge <= 0 {
+		return DefaultAbandonedRegistrationAge
+	}
+	return c.MaxAge
+}
+
+// Run removes all abandoned registrations, returning the number of accounts
+// removed. Accounts changed while running, e.g., if the user validates the
+// email address, are skipped.
+func (c RegistrationCleanup) Run(ctx context.Context) (int, error) {
+	maxAge := c.maxAge()
+	registeredBefore := core.Now(c.Clock).Add(-maxAge)
+	var count int
+	err := c.Repository.EachUnvalidatedAccount(ctx, registeredBefore,
+		func(acc domain.Account) error {
+			if !acc.RegistrationAbandoned(c.Clock, maxAge) {
+				return nil
+			}
+			res := core.UseCaseOfEntity(acc)
+			res.AddEvent(acc.AbandonRegistration(c.Clock))
+			err := c.Repository.DeleteAbandonedRegistration(ctx, res)
+			switch {
+			case errors.Is(err, corerepo.ErrConflict):
+				abandonedRegistrations.Inc("conflict")
+				log.Info(ctx, "RegistrationCleanup: account changed, skipping",
+					"account_id", acc.ID)
+				return nil
+			case err != nil:
+				abandonedRegistrations.Inc("error")
+				return err
+			}
+			abandonedRegistrations.Inc("deleted")
+			count++
+			return nil
+		})
+	if err != nil {
+		return count, fmt.Errorf("RegistrationCleanup.Run: %w", err)
+	}
+	if count > 0 {
+		log.Info(ctx, "RegistrationCleanup: removed abandoned registrations", "count", count)
+	}
+	return count, nil
+}
@@ -0,0 +1,77 @@
+package auth_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"harmony/internal/auth"
+	"harmony/internal/auth/domain"
+	"harmony/internal/core"
+	"harmony/internal/core/corerepo"
+	"harmony/internal/testing/clocktest"
+	"harmony/internal/testing/domaintest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// abandonedRegistrationsStub returns all unvalidated accounts as candidates,
+// leaving the age check to the use case.
+type abandonedRegistrationsStub struct {
+	accounts  []domain.Account
+	conflicts map[domain.AccountID]bool
+	deleted   []domain.AccountID
+	events    []core.DomainEvent
+}
+
+func (s *abandonedRegistrationsStub) EachUnvalidatedAccount(
+	_ context.Context, _ time.Time, fn func(domain.Account) error,
+) error {
+	for _, acc := range s.accounts {
+		if !acc.Validated() {
+			if err := fn(acc); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *abandonedRegistrationsStub) DeleteAbandonedRegistration(
+	_ context.Context, uc core.UseCaseResult[domain.Account],
+) error {
+	if s.conflicts[uc.Entity.ID] {
+		return corerepo.ErrConflict
+	}
+	s.deleted = append(s.deleted, uc.Entity.ID)
+	s.events = append(s.events, uc.Events...)
+	return nil
+}
+
+func TestRegistrationCleanup(t *testing.T) {
+	clock := clocktest.New()
+	registered := func(age time.Duration, opts ...domaintest.InitAccountOption) domain.Account {
+		acc := domaintest.InitAccount(opts...)
+		acc.RegisteredAt = clock.Now().Add(-age)
+		return acc
+	}
+	abandoned := registered(8 * 24 * time.Hour)
+	changed := registered(8 * 24 * time.Hour)
+	recent := registered(24 * time.Hour)
+	validated := registered(8*24*time.Hour, domaintest.WithEmailValidation())
+	repo := &abandonedRegistrationsStub{
+		accounts:  []domain.Account{abandoned, changed, recent, validated},
+		conflicts: map[domain.AccountID]bool{changed.ID: true},
+	}
+	cleanup := auth.RegistrationCleanup{Repository: repo, Clock: clock}
+
+	count, err := cleanup.Run(t.Context())
+	assert.NoError(t, err, "Conflicts are skipped")
+	assert.Equal(t, 1, count)
+	assert.Equal(t, []domain.AccountID{abandoned.ID}, repo.deleted)
+	if assert.Len(t, repo.events, 1) {
+		assert.Equal(t,
+			domain.AccountRegistrationAbandoned{AccountID: abandoned.ID},
+			repo.events[0].Body)
+	}
+}
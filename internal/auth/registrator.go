@@ -7,6 +7,7 @@ import (
 	"harmony/internal/auth/domain/password"
 	"harmony/internal/core"
 	"net/mail"
+	"time"
 )
 
 var ErrInvalidInput = errors.New("Invalid input")
@@ -32,10 +33,14 @@ type RegistratorInput struct {
 
 type Registrator struct {
 	Repository AccountInserter
+	Clock      core.Clock
 }
 
 // Register attempts to create a new user account with password-based
 // authentication.
+//
+// Returns [ErrEmailAlreadyRegistered] if an account exists with the same
+// email address, ignoring case.
 func (r Registrator) Register(ctx context.Context, input RegistratorInput) error {
 	hash, err := input.Password.Hash()
 	if err != nil {
@@ -50,13 +55,16 @@ func (r Registrator) Register(ctx context.Context, input RegistratorInput) error
 			Email:       domain.NewUnvalidatedEmail(*input.Email),
 			Name:        input.Name,
 			DisplayName: input.DisplayName,
+			// Truncated, so the JSON time is sortable in CouchDB views
+			RegisteredAt: core.Now(r.Clock).Truncate(time.Second),
 		},
 		PasswordHash: hash,
 	}
 
 	res := core.UseCaseOfEntity(account)
-	res.AddEvent(domain.CreateAccountRegisteredEvent(account.Account))
-	res.AddEvent(res.Entity.StartEmailValidationChallenge())
+	res.AddEvent(domain.CreateAccountRegisteredEvent(account.Account, r.Clock))
+	res.AddEvent(res.Entity.StartEmailValidationChallenge(r.Clock))
+	res.AddEvent(res.Entity.ScheduleEmailValidationReminder(r.Clock))
 	_, err = r.Repository.Insert(ctx, res)
 	return err
 }
@@ -1,15 +1,17 @@
 package auth_test
 
 import (
+	"context"
 	"net/mail"
 	"testing"
-	"testing/synctest"
 	"time"
 
 	"harmony/internal/core"
 	. "harmony/internal/auth"
 	"harmony/internal/auth/domain"
 	"harmony/internal/auth/domain/password"
+	"harmony/internal/testing/clocktest"
+	"harmony/internal/testing/eventstest"
 	"harmony/internal/testing/htest"
 	"harmony/internal/testing/repotest"
 
@@ -34,13 +36,15 @@ type RegisterTestSuite struct {
 	htest.GomegaSuite
 	Registrator
 	repo       *PWAuthRepositoryStub
+	clock      *clocktest.FakeClock
 	validInput RegistratorInput
 }
 
 func (s *RegisterTestSuite) SetupTest() {
 	s.repo = NewPWAuthRepositoryStub(s.T())
+	s.clock = clocktest.New()
 
-	s.Registrator = Registrator{Repository: s.repo}
+	s.Registrator = Registrator{Repository: s.repo, Clock: s.clock}
 	s.validInput = CreateValidInput()
 }
 
@@ -63,6 +67,15 @@ func (s *RegisterTestSuite) TestValidRegistrationInput() {
 	)
 }
 
+func (s *RegisterTestSuite) TestDuplicateEmail() {
+	s.Assert().NoError(s.Register(s.Context(), s.validInput))
+
+	input := s.validInput
+	input.Email = MustParseEmail("JD@Example.com")
+	s.Assert().ErrorIs(s.Register(s.Context(), input), ErrEmailAlreadyRegistered)
+	s.Assert().Len(s.repo.All(), 1)
+}
+
 func (s *RegisterTestSuite) TestActivation() {
 	s.Register(s.Context(), s.validInput)
 	entity := s.repo.Single()
@@ -70,47 +83,83 @@ func (s *RegisterTestSuite) TestActivation() {
 	s.Assert().False(entity.Email.Validated, "Email validated - before validation")
 
 	s.Assert().ErrorIs(entity.ValidateEmail(
-		domain.EmailValidationCode("invalid")),
+		domain.EmailValidationCode("invalid"), s.clock),
 		domain.ErrBadEmailChallengeResponse, "Validating wrong code")
 
 	code := repotest.SingleEventOfType[domain.EmailValidationRequest](s.repo).Code
-	s.Assert().NoError(entity.ValidateEmail(code), "Validating right code")
+	s.Assert().NoError(entity.ValidateEmail(code, s.clock), "Validating right code")
 	s.Assert().True(entity.Email.Validated, "Email validated - after validation")
 }
 
 func (s *RegisterTestSuite) TestActivationCodeBeforeExpiry() {
-	synctest.Run(func() {
-		s.Register(s.Context(), s.validInput)
-		entity := s.repo.Single()
-		code := repotest.SingleEventOfType[domain.EmailValidationRequest](
-			s.repo,
-		).Code
-
-		time.Sleep(14 * time.Minute)
-		synctest.Wait()
-
-		s.Assert().NoError(entity.ValidateEmail(code), "Validation error")
-		s.Assert().True(entity.Email.Validated, "Email validated")
-	})
+	s.Register(s.Context(), s.validInput)
+	entity := s.repo.Single()
+	code := repotest.SingleEventOfType[domain.EmailValidationRequest](
+		s.repo,
+	).Code
+
+	s.clock.Advance(domain.EmailChallengeDuration)
+
+	s.Assert().NoError(entity.ValidateEmail(code, s.clock), "Validation error")
+	s.Assert().True(entity.Email.Validated, "Email validated")
 }
 
 func (s *RegisterTestSuite) TestActivationCodeExpired() {
-	synctest.Run(func() {
-		s.Register(s.Context(), s.validInput)
-		entity := s.repo.Single()
-		validationRequest := repotest.SingleEventOfType[domain.EmailValidationRequest](
-			s.repo,
-		)
-		code := validationRequest.Code
+	s.Register(s.Context(), s.validInput)
+	entity := s.repo.Single()
+	validationRequest := repotest.SingleEventOfType[domain.EmailValidationRequest](
+		s.repo,
+	)
+	code := validationRequest.Code
+
+	s.Assert().False(entity.Email.Validated, "Email validated - before validation")
 
-		s.Assert().False(entity.Email.Validated, "Email validated - before validation")
+	s.clock.Advance(domain.EmailChallengeDuration + time.Nanosecond)
 
-		time.Sleep(16 * time.Minute)
-		synctest.Wait()
+	s.Assert().ErrorIs(entity.ValidateEmail(code, s.clock), domain.ErrEmailChallengeExpired)
+	s.Assert().False(entity.Email.Validated, "Email validated - after validation")
+}
+
+func (s *RegisterTestSuite) TestEmailValidationReminderScheduled() {
+	s.Register(s.Context(), s.validInput)
+	entity := s.repo.Single()
 
-		s.Assert().ErrorIs(entity.ValidateEmail(code), domain.ErrEmailChallengeExpired)
-		s.Assert().False(entity.Email.Validated, "Email validated - after validation")
+	var reminder *core.DomainEvent
+	for _, e := range s.repo.Events {
+		if _, ok := e.Body.(domain.EmailValidationReminder); ok {
+			reminder = &e
+		}
+	}
+	if s.Assert().NotNil(reminder, "Reminder scheduled") {
+		s.Assert().Equal(domain.EmailValidationReminder{AccountID: entity.ID}, reminder.Body)
+		s.Assert().False(reminder.Due(s.clock.Now()), "Reminder due at registration")
+		s.clock.Advance(domain.EmailValidationReminderDelay)
+		s.Assert().True(reminder.Due(s.clock.Now()), "Reminder due after delay")
+	}
+}
+
+// recipientsSender records the recipients of sent emails.
+type recipientsSender struct{ recipients []string }
+
+func (s *recipientsSender) SendMail(_ context.Context, _ string, to []string, _ []byte) error {
+	s.recipients = append(s.recipients, to...)
+	return nil
+}
+
+func (s *RegisterTestSuite) TestRegistrationSendsValidationEmail() {
+	sender := &recipientsSender{}
+	bus := eventstest.StartPump(s.T(), &EmailValidator{
+		Repository: pwAuthAccounts{s.repo},
+		Clock:      s.clock,
+		Sender:     sender,
 	})
+	s.repo.Publisher = bus
+
+	s.Assert().NoError(s.Register(s.Context(), s.validInput))
+	eventstest.WaitForEvents(s.T(), bus)
+
+	s.Assert().Equal([]string{"jd@example.com"}, sender.recipients,
+		"Validation email sent, and the reminder kept until due")
 }
 
 func MatchDomainEvent(data any) types.GomegaMatcher {
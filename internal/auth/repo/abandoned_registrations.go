@@ -0,0 +1,87 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"harmony/internal/auth/domain"
+	"harmony/internal/core"
+	"harmony/internal/core/corerepo"
+	"harmony/internal/infrastructure/log"
+)
+
+// EachUnvalidatedAccount calls fn for each account with an email address that
+// isn't validated, registered before the time, oldest first. The accounts are
+// read in batches of [corerepo.DefaultEventBatchSize], and fn may delete the
+// account. Iteration stops if fn returns an error, which is returned, unless
+// it is [corerepo.ErrStopIteration].
+func (r AccountRepository) EachUnvalidatedAccount(
+	ctx context.Context,
+	registeredBefore time.Time,
+	fn func(domain.Account) error,
+) error {
+	q := corerepo.ViewQuery{
+		EndKey: registeredBefore.UTC().Truncate(time.Second).Format(time.RFC3339),
+		Limit:  corerepo.DefaultEventBatchSize,
+	}
+	for {
+		res, err := corerepo.QueryViewDocs[accountDoc](
+			ctx, r.Connection, designDocID, "unvalidated_by_registration", q,
+		)
+		if err != nil {
+			return fmt.Errorf("AccountRepository.EachUnvalidatedAccount: %w", err)
+		}
+		for _, doc := range res.Docs() {
+			doc.Account.Rev = doc.CouchRev
+			if err := fn(doc.Account); err != nil {
+				if errors.Is(err, corerepo.ErrStopIteration) {
+					return nil
+				}
+				return err
+			}
+		}
+		if res.Next == nil {
+			return nil
+		}
+		q = *res.Next
+	}
+}
+
+// DeleteAbandonedRegistration removes the account, email, and password
+// documents of an account that never completed registration, storing the
+// domain events of the use case. Unlike [AccountRepository.Delete], no
+// tombstone is kept, so the email address can be registered again.
+//
+// The account document is deleted with the revision of the entity. If the
+// account was changed, e.g., the email address was validated in the meantime,
+// [ErrConflict] is returned, and nothing is deleted.
+func (r AccountRepository) DeleteAbandonedRegistration(
+	ctx context.Context,
+	uc core.UseCaseResult[domain.Account],
+) error {
+	acc := uc.Entity
+	saga := insertSaga{conn: r.Connection}
+	if err := r.insertEventsDoc(ctx, &saga, acc.ID, uc.Events); err != nil {
+		return fmt.Errorf("AccountRepository.DeleteAbandonedRegistration: events: %w", err)
+	}
+	if _, err := r.Connection.Delete(ctx, r.accDocId(acc.ID), acc.Rev); err != nil {
+		if compErr := saga.compensate(ctx); compErr != nil {
+			err = errors.Join(err, compErr)
+		}
+		return fmt.Errorf("AccountRepository.DeleteAbandonedRegistration: %w", err)
+	}
+	emailDocID := r.accEmailDocID(acc)
+	err := errors.Join(
+		r.Connection.DeleteIfExists(ctx, emailDocID),
+		r.Connection.DeleteIfExists(ctx, passwordDocId(acc.ID)),
+	)
+	if err != nil {
+		return fmt.Errorf("AccountRepository.DeleteAbandonedRegistration: %w", err)
+	}
+	if err := r.Connection.PurgeDocument(ctx, emailDocID); err != nil {
+		log.LogError(ctx, "AccountRepository.DeleteAbandonedRegistration: purge email document", err)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package repo_test
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"harmony/internal/auth/domain"
+	. "harmony/internal/auth/repo"
+	"harmony/internal/core"
+	"harmony/internal/core/corerepo"
+	"harmony/internal/testing/domaintest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccountRepositoryDeleteAbandonedRegistration(t *testing.T) {
+	ctx := t.Context()
+	repo := initRepository()
+	assert.NoError(t, repo.Bootstrap(ctx))
+
+	longAgo := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	insert := func(registeredAt time.Time, opts ...any) domain.PasswordAuthentication {
+		acc := domaintest.InitPasswordAuthAccount(opts...)
+		acc.RegisteredAt = registeredAt
+		inserted, err := repo.Insert(ctx, core.UseCaseOfEntity(acc))
+		assert.NoError(t, err)
+		return inserted
+	}
+	abandoned := insert(longAgo)
+	validated := insert(longAgo, domaintest.WithEmailValidation())
+	recent := insert(time.Now())
+
+	var found []domain.Account
+	assert.NoError(t, repo.EachUnvalidatedAccount(ctx, longAgo.Add(time.Hour),
+		func(acc domain.Account) error {
+			found = append(found, acc)
+			return nil
+		}))
+	index := func(id domain.AccountID) int {
+		return slices.IndexFunc(found, func(acc domain.Account) bool { return acc.ID == id })
+	}
+	assert.Equal(t, -1, index(validated.ID), "Validated account")
+	assert.Equal(t, -1, index(recent.ID), "Recently registered account")
+	i := index(abandoned.ID)
+	if !assert.NotEqual(t, -1, i, "Abandoned account") {
+		return
+	}
+
+	t.Run("Account changed", func(t *testing.T) {
+		stale := abandoned.Account
+		stale.Rev = "1-00000000000000000000000000000000"
+		err := repo.DeleteAbandonedRegistration(ctx, core.UseCaseOfEntity(stale))
+		assert.ErrorIs(t, err, ErrConflict)
+		_, err = repo.Get(ctx, abandoned.ID)
+		assert.NoError(t, err, "Account not deleted")
+	})
+
+	uc := core.UseCaseOfEntity(found[i])
+	uc.AddEvent(found[i].AbandonRegistration(nil))
+	assert.NoError(t, repo.DeleteAbandonedRegistration(ctx, uc))
+
+	_, err := repo.Get(ctx, abandoned.ID)
+	assert.ErrorIs(t, err, corerepo.ErrNotFound, "Account document deleted")
+	_, err = repo.FindPWAuthByEmail(ctx, abandoned.Email.String())
+	assert.ErrorIs(t, err, corerepo.ErrNotFound, "Email document deleted")
+
+	newAcc := domaintest.InitPasswordAuthAccount(domaintest.WithEmail(abandoned.Email.String()))
+	_, err = repo.Insert(ctx, core.UseCaseOfEntity(newAcc))
+	assert.NoError(t, err, "Email address can be registered again")
+}
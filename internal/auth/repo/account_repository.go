@@ -1,17 +1,28 @@
 package repo
 
 import (
+	"bytes"
 	"context"
+	"embed"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"harmony/internal/core/corerepo"
 	"harmony/internal/auth"
 	"harmony/internal/auth/domain"
 	"harmony/internal/auth/domain/password"
+	"harmony/internal/core"
+	"harmony/internal/core/corerepo"
+	"harmony/internal/infrastructure/log"
+	"strings"
 )
 
 var ErrConflict = corerepo.ErrConflict
 
+// errEmailConflict is returned when inserting an account with an email address
+// that is already registered. It is both a [domain.ErrEmailAlreadyRegistered]
+// and an [ErrConflict].
+var errEmailConflict = fmt.Errorf("%w: %w", domain.ErrEmailAlreadyRegistered, ErrConflict)
+
 type accountEmailDoc struct {
 	domain.AccountID
 }
@@ -23,73 +34,176 @@ type accountPasswordDoc struct {
 
 type AccountRepository struct {
 	corerepo.Connection
+	// Clock decides if tombstones of deleted accounts are still active. The
+	// system clock is used if nil.
+	Clock core.Clock
+}
+
+// designDocID is the design document with the views used by the repository,
+// "by_search_term", indexing accounts by lower case email address, name, and
+// display name, and "domain_events_by_account", indexing domain events by the
+// accounts they refer to.
+const designDocID = "accounts"
+
+//go:embed designdocs
+var designDocFS embed.FS
+
+var designDoc = corerepo.MustLoadDesignDoc(designDocFS, "designdocs/accounts")
+
+func init() { corerepo.RegisterDesignDoc(designDocID, designDoc) }
+
+// Bootstrap installs the design document with views used by the repository.
+// The design document is also installed by [corerepo.Connection.Bootstrap].
+func (r AccountRepository) Bootstrap(ctx context.Context) error {
+	return r.Connection.SetDesignDoc(ctx, designDocID, designDoc)
+}
+
+// accountDoc is used to read account documents returned from views, where the
+// revision is part of the document itself.
+type accountDoc struct {
+	domain.Account
+	CouchRev string `json:"_rev"`
+}
+
+// aggregateCtx returns a context for storing events of the account.
+func aggregateCtx(ctx context.Context, id domain.AccountID) context.Context {
+	return core.WithAggregateID(ctx, string(id))
 }
 
 func (r AccountRepository) accDocId(id domain.AccountID) string {
 	return fmt.Sprintf("auth:account:%s", id)
 }
 
+// emailDocPrefix is the ID prefix of email documents, which map email
+// addresses to accounts. As document IDs are unique, the email documents
+// guarantee that an address cannot be registered by more than one account.
+const emailDocPrefix = "auth:account:email:"
+
+// addrDocId returns the ID of the email document for the address. The ID
+// contains the [domain.CanonicalAddress], making lookups case insensitive.
 func (r AccountRepository) addrDocId(addr string) string {
-	return fmt.Sprintf("auth:account:email:%s", addr)
+	return emailDocPrefix + domain.CanonicalAddress(addr)
 }
 
 func (r AccountRepository) accEmailDocID(acc domain.Account) string {
 	return r.addrDocId(acc.Email.String())
 }
 
+func (r AccountRepository) eventsDocID(id domain.AccountID, e core.DomainEvent) string {
+	return fmt.Sprintf("auth:account:%s:events:%s", id, e.ID)
+}
+
+func (r AccountRepository) tombstones() TombstoneRepository {
+	return NewTombstoneRepository(&r.Connection)
+}
+
+func tombstoneDocID(emailHash string) string {
+	return fmt.Sprintf("auth:tombstone:%s", emailHash)
+}
+
 func passwordDocId(id domain.AccountID) string {
-	return fmt.Sprintf("auth:accunt:%s:password", id)
+	return fmt.Sprintf("auth:account:%s:password", id)
 }
 
 func (r AccountRepository) insertAccountDoc(
 	ctx context.Context,
+	saga *insertSaga,
 	acc domain.Account,
 ) (domain.Account, error) {
-	rev, err := r.Connection.Insert(ctx, r.accDocId(acc.ID), acc)
+	rev, err := saga.insert(ctx, r.accDocId(acc.ID), acc)
 	acc.Rev = rev
 	return acc, err
 }
 
 func (r AccountRepository) insertEmailDoc(
 	ctx context.Context,
+	saga *insertSaga,
 	acc auth.AccountUseCaseResult,
 ) error {
 	doc := corerepo.DocumentWithEvents[accountEmailDoc]{
 		Document: accountEmailDoc{acc.Entity.ID},
-		Events:   acc.Events,
+		Events:   core.WithEventMetadata(aggregateCtx(ctx, acc.Entity.ID), acc.Events),
 	}
-	_, err := r.Connection.Insert(ctx,
+	_, err := saga.insert(ctx,
 		r.accEmailDocID(acc.Entity.Account),
 		doc,
 	)
+	if errors.Is(err, corerepo.ErrConflict) {
+		err = errEmailConflict
+	}
 	return err
 }
 
 func (r AccountRepository) insertPasswordDoc(
 	ctx context.Context,
+	saga *insertSaga,
 	acc domain.PasswordAuthentication,
 ) error {
 	doc := accountPasswordDoc{
 		acc.ID,
 		acc.PasswordHash.UnsecureRead(),
 	}
-	_, err := r.Connection.Insert(ctx, passwordDocId(acc.ID), doc)
+	_, err := saga.insert(ctx, passwordDocId(acc.ID), doc)
+	return err
+}
+
+// checkEmailAvailable returns [errEmailConflict] if the email
+// address belongs to an existing account, and
+// [domain.ErrEmailRecentlyDeleted] if it belonged to an account that was
+// recently deleted.
+//
+// This check avoids writing account documents for registrations that will
+// fail, but only the email document guarantees uniqueness.
+func (r AccountRepository) checkEmailAvailable(ctx context.Context, address string) error {
+	var emailDoc json.RawMessage
+	_, err := r.Connection.Get(ctx, r.addrDocId(address), &emailDoc)
+	if err == nil {
+		return errEmailConflict
+	}
+	if !errors.Is(err, corerepo.ErrNotFound) {
+		return err
+	}
+	tombstone, err := r.tombstones().Get(ctx, domain.HashEmail(address))
+	if errors.Is(err, corerepo.ErrNotFound) {
+		return nil
+	}
+	if err == nil && tombstone.Active(r.Clock) {
+		err = domain.ErrEmailRecentlyDeleted
+	}
 	return err
 }
 
+// Insert stores a new account. Returns [domain.ErrEmailAlreadyRegistered] if
+// the email address belongs to another account.
+//
+// The account is stored in three documents; the account, the password, and
+// the email document. The email document is written last, as it carries the
+// domain events, and guarantees that the email address is unique. If any
+// write fails, the documents already written are deleted again, so either the
+// whole account is stored, and the events published, or nothing is.
 func (r AccountRepository) Insert(
 	ctx context.Context,
 	acc auth.AccountUseCaseResult,
 ) (domain.PasswordAuthentication, error) {
-	res, err := r.insertAccountDoc(ctx, acc.Entity.Account)
+	if err := r.checkEmailAvailable(ctx, acc.Entity.Email.String()); err != nil {
+		return acc.Entity, err
+	}
+	saga := insertSaga{conn: r.Connection}
+	res, err := r.insertAccountDoc(ctx, &saga, acc.Entity.Account)
 	if err == nil {
-		err = r.insertPasswordDoc(ctx, acc.Entity)
+		err = r.insertPasswordDoc(ctx, &saga, acc.Entity)
 	}
 	if err == nil {
-		err = r.insertEmailDoc(ctx, acc)
+		err = r.insertEmailDoc(ctx, &saga, acc)
+	}
+	if err != nil {
+		if compErr := saga.compensate(ctx); compErr != nil {
+			err = errors.Join(err, fmt.Errorf("AccountRepository.Insert: compensate: %w", compErr))
+		}
+		return acc.Entity, err
 	}
 	acc.Entity.Account = res
-	return acc.Entity, err
+	return acc.Entity, nil
 }
 
 func (r AccountRepository) Get(
@@ -141,3 +255,275 @@ func (r AccountRepository) Update(
 	acc.Rev = newRev
 	return acc, err
 }
+
+// UpdateWithEvents updates the account, and stores the domain events of the use
+// case for publishing.
+//
+// Account documents don't contain domain events, so the events are stored in a
+// separate document. The events document is written first, and deleted again
+// if the account update fails, so events are never published for an update
+// that failed, and never lost for an update that succeeded.
+func (r AccountRepository) UpdateWithEvents(
+	ctx context.Context, uc core.UseCaseResult[domain.Account],
+) (domain.Account, error) {
+	saga := insertSaga{conn: r.Connection}
+	if err := r.insertEventsDoc(ctx, &saga, uc.Entity.ID, uc.Events); err != nil {
+		return uc.Entity, fmt.Errorf("AccountRepository.UpdateWithEvents: events: %w", err)
+	}
+	acc, err := r.Update(ctx, uc.Entity)
+	if err != nil {
+		if compErr := saga.compensate(ctx); compErr != nil {
+			err = errors.Join(err, compErr)
+		}
+		return acc, fmt.Errorf("AccountRepository.UpdateWithEvents: %w", err)
+	}
+	return acc, nil
+}
+
+// UpdatePassword stores the password hash, and updates the account, storing
+// the domain events of the use case for publishing.
+//
+// The events are written first, and deleted again if an update fails. The
+// password is written before the account, so the account never permits
+// authentication, e.g., after a password reset, unless the new password is
+// stored.
+func (r AccountRepository) UpdatePassword(
+	ctx context.Context, uc core.UseCaseResult[domain.PasswordAuthentication],
+) (domain.PasswordAuthentication, error) {
+	res := uc.Entity
+	saga := insertSaga{conn: r.Connection}
+	err := r.insertEventsDoc(ctx, &saga, res.ID, uc.Events)
+	if err == nil {
+		var pwDoc accountPasswordDoc
+		var rev string
+		if rev, err = r.Connection.Get(ctx, passwordDocId(res.ID), &pwDoc); err == nil {
+			pwDoc.PasswordHash = res.PasswordHash.UnsecureRead()
+			_, err = r.Connection.Update(ctx, passwordDocId(res.ID), rev, pwDoc)
+		}
+	}
+	if err == nil {
+		res.Account, err = r.Update(ctx, res.Account)
+	}
+	if err != nil {
+		if compErr := saga.compensate(ctx); compErr != nil {
+			err = errors.Join(err, compErr)
+		}
+		return uc.Entity, fmt.Errorf("AccountRepository.UpdatePassword: %w", err)
+	}
+	return res, nil
+}
+
+// insertEventsDoc stores domain events of the account in a separate document,
+// as part of the saga. Nothing is written if there are no events.
+func (r AccountRepository) insertEventsDoc(
+	ctx context.Context, saga *insertSaga, id domain.AccountID, events []core.DomainEvent,
+) error {
+	if len(events) == 0 {
+		return nil
+	}
+	doc := corerepo.DocumentWithEvents[domain.AccountID]{
+		Document: id,
+		Events:   core.WithEventMetadata(aggregateCtx(ctx, id), events),
+	}
+	_, err := saga.insert(ctx, r.eventsDocID(id, events[0]), doc)
+	return err
+}
+
+// Search returns accounts where the email address, name, or display name
+// starts with the query, ordered by the matching field. The search is case
+// insensitive. At most limit accounts are returned, if limit is positive.
+func (r AccountRepository) Search(
+	ctx context.Context, query string, limit int,
+) ([]domain.Account, error) {
+	query = strings.ToLower(strings.TrimSpace(query))
+	vq := corerepo.PrefixQuery(query)
+	vq.Limit = limit
+	// The same account is returned multiple times if more than one field
+	// matches, so more pages may be needed to find limit accounts.
+	seen := make(map[domain.AccountID]bool)
+	var accounts []domain.Account
+	for {
+		res, err := corerepo.QueryViewDocs[accountDoc](
+			ctx, r.Connection, designDocID, "by_search_term", vq,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("AccountRepository.Search: %w", err)
+		}
+		for _, doc := range res.Docs() {
+			if seen[doc.ID] {
+				continue
+			}
+			seen[doc.ID] = true
+			doc.Account.Rev = doc.CouchRev
+			accounts = append(accounts, doc.Account)
+			if len(accounts) == limit {
+				return accounts, nil
+			}
+		}
+		if res.Next == nil {
+			return accounts, nil
+		}
+		vq = *res.Next
+	}
+}
+
+// Delete removes the account, email, and password documents of the account,
+// and stores the tombstone, including domain events.
+//
+// The tombstone is written first, so registration with the email address is
+// blocked, and the events are published, even if removing the documents
+// fails. Domain events referring to the account are not removed, as they only
+// contain IDs.
+//
+// The documents are purged, as deleted documents are kept by CouchDB, and
+// replicated. The ID of the email document contains the email address, and
+// previous revisions of the account contain personal information. Purging
+// requires admin privileges; if it fails, the error is logged, but the account
+// is deleted.
+func (r AccountRepository) Delete(
+	ctx context.Context,
+	acc domain.Account,
+	tombstone core.UseCaseResult[domain.Tombstone],
+) error {
+	// The events belong to the account, not the tombstone
+	tombstone.Events = core.WithEventMetadata(aggregateCtx(ctx, acc.ID), tombstone.Events)
+	existing, err := r.tombstones().Get(ctx, tombstone.Entity.EmailHash)
+	switch {
+	case errors.Is(err, corerepo.ErrNotFound):
+		_, err = r.tombstones().Insert(ctx, tombstone)
+	case err == nil:
+		// Tombstone of a previously deleted account with the same address.
+		tombstone.Entity.Rev = existing.Rev
+		_, err = r.tombstones().UpdateWithEvents(ctx, tombstone)
+	}
+	if err != nil {
+		return fmt.Errorf("AccountRepository.Delete: tombstone: %w", err)
+	}
+	docIDs := []string{r.accEmailDocID(acc), passwordDocId(acc.ID), r.accDocId(acc.ID)}
+	var errs []error
+	for _, id := range docIDs {
+		errs = append(errs, r.Connection.DeleteIfExists(ctx, id))
+	}
+	if err = errors.Join(errs...); err != nil {
+		return fmt.Errorf("AccountRepository.Delete: %w", err)
+	}
+	for _, id := range docIDs {
+		// The ID is not logged, as the email document ID contains the address
+		if err := r.Connection.PurgeDocument(ctx, id); err != nil {
+			log.LogError(ctx, "AccountRepository.Delete: purge document", err,
+				"accountID", acc.ID)
+		}
+	}
+	return nil
+}
+
+// exportSecretFields are the fields left out of exported documents and domain
+// events; password hashes, and the codes of email challenges and password
+// resets, which would let anyone reading the export take over the account.
+var exportSecretFields = []string{"PasswordHash", "Code", "validation_code", "reset_code"}
+
+// ExportData returns all documents and domain events referring to the account.
+// Password hashes and codes are left out; see [exportSecretFields].
+func (r AccountRepository) ExportData(
+	ctx context.Context, id domain.AccountID,
+) (res auth.AccountData, err error) {
+	acc, err := r.Get(ctx, id)
+	if err == nil {
+		res.Documents, err = r.eventDocuments(ctx, id)
+	}
+	for _, docID := range []string{r.accDocId(id), r.accEmailDocID(acc), passwordDocId(id)} {
+		if err == nil {
+			err = r.exportDoc(ctx, docID, res.Documents)
+		}
+	}
+	if err == nil {
+		res.Events, err = r.domainEvents(ctx, id)
+	}
+	for docID, doc := range res.Documents {
+		if err == nil {
+			res.Documents[docID], err = withoutSecrets(doc)
+		}
+	}
+	for i, event := range res.Events {
+		if err == nil {
+			res.Events[i], err = withoutSecrets(event)
+		}
+	}
+	if err != nil {
+		return res, fmt.Errorf("AccountRepository.ExportData: %w", err)
+	}
+	return res, nil
+}
+
+// withoutSecrets returns the JSON document with [exportSecretFields] removed
+// at any depth.
+func withoutSecrets(doc json.RawMessage) (json.RawMessage, error) {
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	removeSecrets(v)
+	return json.Marshal(v)
+}
+
+func removeSecrets(v any) {
+	switch v := v.(type) {
+	case map[string]any:
+		for _, field := range exportSecretFields {
+			delete(v, field)
+		}
+		for _, child := range v {
+			removeSecrets(child)
+		}
+	case []any:
+		for _, child := range v {
+			removeSecrets(child)
+		}
+	}
+}
+
+// exportDoc adds the document with the id to docs, if it exists.
+func (r AccountRepository) exportDoc(
+	ctx context.Context, id string, docs map[string]json.RawMessage,
+) error {
+	var doc json.RawMessage
+	_, err := r.Connection.Get(ctx, id, &doc)
+	if err == nil {
+		docs[id] = doc
+	}
+	if errors.Is(err, corerepo.ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// eventDocuments returns the documents storing domain events for account
+// updates.
+func (r AccountRepository) eventDocuments(
+	ctx context.Context, id domain.AccountID,
+) (map[string]json.RawMessage, error) {
+	prefix := fmt.Sprintf("%s:events:", r.accDocId(id))
+	res, err := corerepo.AllDocs[json.RawMessage](
+		ctx, r.Connection, corerepo.PrefixQuery(prefix),
+	)
+	if err != nil {
+		return nil, err
+	}
+	docs := make(map[string]json.RawMessage, len(res.Rows))
+	for _, row := range res.Rows {
+		docs[row.ID] = row.Doc
+	}
+	return docs, nil
+}
+
+// domainEvents returns the domain event documents referring to the account.
+func (r AccountRepository) domainEvents(
+	ctx context.Context, id domain.AccountID,
+) ([]json.RawMessage, error) {
+	res, err := corerepo.QueryViewDocs[json.RawMessage](
+		ctx, r.Connection, designDocID, "domain_events_by_account", corerepo.KeyQuery(id),
+	)
+	return res.Docs(), err
+}
@@ -2,8 +2,10 @@ package repo_test
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -16,13 +18,14 @@ import (
 	_ "harmony/internal/testing/couchtest" // clear database before tests
 	"harmony/internal/testing/domaintest"
 
+	gonanoid "github.com/matoous/go-nanoid/v2"
 	"github.com/stretchr/testify/assert"
 )
 
 func initRepository() AccountRepository {
 	corerepo.AssertInitialized()
 	conn := corerepo.DefaultConnection
-	return AccountRepository{conn}
+	return AccountRepository{Connection: conn}
 }
 
 func insertAccount(c context.Context, repo AccountRepository, acc auth.AccountUseCaseResult) error {
@@ -68,7 +71,13 @@ func TestDuplicateEmail(t *testing.T) {
 	acc2 := core.UseCaseOfEntity(
 		domaintest.InitPasswordAuthAccount(domaintest.WithEmail(email)))
 	assert.NoError(t, insertAccount(ctx, repo, acc1))
-	assert.ErrorIs(t, insertAccount(ctx, repo, acc2), ErrConflict)
+	err := insertAccount(ctx, repo, acc2)
+	assert.ErrorIs(t, err, ErrConflict)
+	assert.ErrorIs(t, err, domain.ErrEmailAlreadyRegistered)
+
+	found, err := repo.FindByEmail(ctx, email)
+	assert.NoError(t, err, "Email of the first account kept")
+	assert.Equal(t, acc1.Entity.ID, found.ID)
 }
 
 func TestAccountRepositoryUpdate(t *testing.T) {
@@ -157,8 +166,8 @@ func TestInsertDomainEvents(t *testing.T) {
 
 		// Insert an entity with two domain events
 		acc := core.UseCaseOfEntity(domaintest.InitPasswordAuthAccount())
-		event1 := acc.Entity.StartEmailValidationChallenge()
-		event2 := domain.CreateAccountRegisteredEvent(acc.Entity.Account)
+		event1 := acc.Entity.StartEmailValidationChallenge(nil)
+		event2 := domain.CreateAccountRegisteredEvent(acc.Entity.Account, nil)
 		acc.AddEvent(event1)
 		acc.AddEvent(event2)
 		assert.NoError(t, insertAccount(ctx, repo, acc))
@@ -180,3 +189,263 @@ func TestInsertDomainEvents(t *testing.T) {
 		}
 	}).RunWithErrorf("Failed finding all expected events. Found %+v", actual)
 }
+
+func TestAccountRepositorySearch(t *testing.T) {
+	ctx := t.Context()
+	repo := initRepository()
+	assert.NoError(t, repo.Bootstrap(ctx))
+
+	// Names are unique to the test run, as accounts of earlier runs remain
+	email := domaintest.NewAddress()
+	name := "Searchable " + gonanoid.MustGenerate("abcdefghijklmnopqrstuvwxyz", 10)
+	acc := core.UseCaseOfEntity(domaintest.InitPasswordAuthAccount(
+		domaintest.WithEmail(email),
+		domaintest.WithName(name),
+	))
+	inserted, err := repo.Insert(ctx, acc)
+	assert.NoError(t, err)
+
+	for _, query := range []string{email, strings.ToUpper(email[0:5]), name[0:15]} {
+		found, err := repo.Search(ctx, query, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, []domain.Account{inserted.Account}, found, "Search for %s", query)
+	}
+}
+
+func TestAccountRepositorySearchLimit(t *testing.T) {
+	ctx := t.Context()
+	repo := initRepository()
+	assert.NoError(t, repo.Bootstrap(ctx))
+
+	// Names and display names match the query, returning two rows per account
+	name := "Limited " + gonanoid.MustGenerate("abcdefghijklmnopqrstuvwxyz", 10)
+	for range 3 {
+		acc := core.UseCaseOfEntity(domaintest.InitPasswordAuthAccount(
+			domaintest.WithName(name),
+			domaintest.WithDisplayName(name),
+		))
+		_, err := repo.Insert(ctx, acc)
+		assert.NoError(t, err)
+	}
+
+	found, err := repo.Search(ctx, name, 2)
+	assert.NoError(t, err)
+	assert.Len(t, found, 2)
+	assert.NotEqual(t, found[0].ID, found[1].ID, "Distinct accounts")
+
+	found, err = repo.Search(ctx, name, 0)
+	assert.NoError(t, err)
+	assert.Len(t, found, 3, "Unlimited search")
+}
+
+func TestAccountRepositoryUpdateWithEvents(t *testing.T) {
+	ctx := t.Context()
+	repo := initRepository()
+
+	inserted, err := repo.Insert(ctx, core.UseCaseOfEntity(domaintest.InitPasswordAuthAccount()))
+	assert.NoError(t, err)
+
+	uc := core.UseCaseOfEntity(inserted.Account)
+	uc.AddEvent(uc.Entity.Lock(domain.AccountID("admin"), nil))
+	updated, err := repo.UpdateWithEvents(ctx, uc)
+	assert.NoError(t, err)
+	assert.True(t, updated.Locked)
+
+	reloaded, err := repo.Get(ctx, inserted.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, updated, reloaded)
+}
+
+func TestAccountRepositoryUpdateWithEventsConflict(t *testing.T) {
+	ctx := t.Context()
+	repo := initRepository()
+
+	inserted, err := repo.Insert(ctx, core.UseCaseOfEntity(domaintest.InitPasswordAuthAccount()))
+	assert.NoError(t, err)
+	_, err = repo.Update(ctx, inserted.Account)
+	assert.NoError(t, err)
+
+	uc := core.UseCaseOfEntity(inserted.Account) // Stale revision
+	uc.AddEvent(uc.Entity.Lock(domain.AccountID("admin"), nil))
+	_, err = repo.UpdateWithEvents(ctx, uc)
+	assert.ErrorIs(t, err, corerepo.ErrConflict)
+
+	eventsDocID := "auth:account:" + string(inserted.ID) + ":events:" + string(uc.Events[0].ID)
+	_, err = repo.Connection.Get(ctx, eventsDocID, new(json.RawMessage))
+	assert.ErrorIs(t, err, corerepo.ErrNotFound, "Events document deleted")
+}
+
+func TestAccountRepositoryUpdatePassword(t *testing.T) {
+	ctx := t.Context()
+	repo := initRepository()
+
+	inserted, err := repo.Insert(ctx, core.UseCaseOfEntity(domaintest.InitPasswordAuthAccount()))
+	assert.NoError(t, err)
+	inserted.StartPasswordReset(nil)
+	code := inserted.PasswordReset.Code
+
+	newPassword := password.Parse("n3w s3cret")
+	hash, err := newPassword.Hash()
+	assert.NoError(t, err)
+	uc := core.UseCaseOfEntity(inserted)
+	event, err := uc.Entity.ResetPassword(code, hash, nil)
+	assert.NoError(t, err)
+	uc.AddEvent(event)
+	_, err = repo.UpdatePassword(ctx, uc)
+	assert.NoError(t, err)
+
+	reloaded, err := repo.FindPWAuthByEmail(ctx, inserted.Email.String())
+	assert.NoError(t, err)
+	assert.True(t, reloaded.Validate(newPassword), "New password valid")
+	assert.Nil(t, reloaded.PasswordReset, "Reset challenge cleared")
+
+	t.Run("Stale revision", func(t *testing.T) {
+		_, err := repo.UpdatePassword(ctx, uc)
+		assert.ErrorIs(t, err, corerepo.ErrConflict)
+	})
+}
+
+func TestAccountRepositoryDelete(t *testing.T) {
+	ctx := t.Context()
+	repo := initRepository()
+
+	acc := domaintest.InitPasswordAuthAccount()
+	inserted, err := repo.Insert(ctx, core.UseCaseOfEntity(acc))
+	assert.NoError(t, err)
+
+	tombstone, event := inserted.Account.Delete(nil)
+	uc := core.UseCaseOfEntity(tombstone)
+	uc.AddEvent(event)
+	assert.NoError(t, repo.Delete(ctx, inserted.Account, uc))
+
+	_, err = repo.Get(ctx, acc.ID)
+	assert.ErrorIs(t, err, corerepo.ErrNotFound, "Account document deleted")
+	_, err = repo.FindPWAuthByEmail(ctx, acc.Email.String())
+	assert.ErrorIs(t, err, corerepo.ErrNotFound, "Email document deleted")
+	for _, id := range []string{
+		"auth:account:" + string(acc.ID),
+		"auth:account:" + string(acc.ID) + ":password",
+		"auth:account:email:" + acc.Email.String(),
+	} {
+		// Without the deleted document, the revision history starts over
+		rev, err := repo.Connection.Insert(ctx, id, map[string]any{})
+		assert.NoError(t, err)
+		assert.True(t, strings.HasPrefix(rev, "1-"), "Document %s purged", id)
+		_, err = repo.Connection.Delete(ctx, id, rev)
+		assert.NoError(t, err)
+	}
+
+	t.Run("Registering email of deleted account", func(t *testing.T) {
+		newAcc := domaintest.InitPasswordAuthAccount(
+			domaintest.WithEmail(strings.ToUpper(acc.Email.String())),
+		)
+		_, err = repo.Insert(ctx, core.UseCaseOfEntity(newAcc))
+		assert.ErrorIs(t, err, domain.ErrEmailRecentlyDeleted)
+	})
+}
+
+func TestAccountRepositoryExportData(t *testing.T) {
+	ctx := t.Context()
+	repo := initRepository()
+	assert.NoError(t, repo.Bootstrap(ctx))
+
+	acc := domaintest.InitPasswordAuthAccount(domaintest.WithPassword("s3cret"))
+	validation := acc.StartEmailValidationChallenge(nil)
+	inserted, err := repo.Insert(ctx, auth.AccountUseCaseResult{
+		Entity: acc, Events: []core.DomainEvent{validation},
+	})
+	assert.NoError(t, err)
+	uc := core.UseCaseOfEntity(inserted.Account)
+	uc.AddEvent(uc.Entity.Lock(domain.AccountID("admin"), nil))
+	uc.AddEvent(uc.Entity.StartPasswordReset(nil))
+	_, err = repo.UpdateWithEvents(ctx, uc)
+	assert.NoError(t, err)
+	validationCode := string(acc.Email.Challenge.Code)
+	resetCode := string(uc.Entity.PasswordReset.Code)
+
+	data, err := repo.ExportData(ctx, acc.ID)
+	assert.NoError(t, err)
+	ids := make([]string, 0, len(data.Documents))
+	for id, doc := range data.Documents {
+		ids = append(ids, id)
+		for _, secret := range []string{"PasswordHash", validationCode, resetCode} {
+			assert.NotContains(t, string(doc), secret, "Document %s", id)
+		}
+	}
+	assert.ElementsMatch(t, []string{
+		"auth:account:" + string(acc.ID),
+		"auth:account:email:" + acc.Email.String(),
+		"auth:account:" + string(acc.ID) + ":password",
+		"auth:account:" + string(acc.ID) + ":events:" + string(uc.Events[0].ID),
+	}, ids)
+	assert.Contains(t, string(data.Documents["auth:account:"+string(acc.ID)]), acc.Name,
+		"Other fields are exported")
+}
+
+func TestAccountRepositoryEmailIsCaseInsensitive(t *testing.T) {
+	ctx := t.Context()
+	repo := initRepository()
+
+	address := "Mixed.Case." + domaintest.NewAddress()
+	acc := domaintest.InitPasswordAuthAccount(domaintest.WithEmail(address))
+	_, err := repo.Insert(ctx, core.UseCaseOfEntity(acc))
+	assert.NoError(t, err)
+
+	found, err := repo.FindByEmail(ctx, strings.ToLower(address))
+	assert.NoError(t, err)
+	assert.Equal(t, acc.ID, found.ID)
+
+	duplicate := domaintest.InitPasswordAuthAccount(
+		domaintest.WithEmail(strings.ToUpper(address)),
+	)
+	_, err = repo.Insert(ctx, core.UseCaseOfEntity(duplicate))
+	assert.ErrorIs(t, err, domain.ErrEmailAlreadyRegistered)
+	_, err = repo.Get(ctx, duplicate.ID)
+	assert.ErrorIs(t, err, corerepo.ErrNotFound, "Account document of duplicate")
+}
+
+func TestAccountRepositoryMigrateEmailDocuments(t *testing.T) {
+	ctx := t.Context()
+	repo := initRepository()
+
+	// Insert email documents as they were stored before normalization
+	legacy := "Legacy." + domaintest.NewAddress()
+	dup := "Dup." + domaintest.NewAddress()
+	legacyID := domain.AccountID(domain.NewID())
+	dupIDs := []domain.AccountID{domain.AccountID(domain.NewID()), domain.AccountID(domain.NewID())}
+	for id, addr := range map[domain.AccountID]string{
+		legacyID:  legacy,
+		dupIDs[0]: dup,
+		dupIDs[1]: strings.ToUpper(dup),
+	} {
+		_, err := repo.Connection.Insert(ctx, "auth:account:email:"+addr,
+			corerepo.DocumentWithEvents[struct{ domain.AccountID }]{
+				Document: struct{ domain.AccountID }{id},
+			})
+		assert.NoError(t, err)
+	}
+
+	report, err := repo.MigrateEmailDocuments(ctx, true)
+	assert.NoError(t, err)
+	assert.Contains(t, report.Migrated, strings.ToLower(legacy))
+	_, err = repo.Connection.Get(ctx, "auth:account:email:"+legacy, new(json.RawMessage))
+	assert.NoError(t, err, "Dry run doesn't modify the database")
+
+	report, err = repo.MigrateEmailDocuments(ctx, false)
+	assert.NoError(t, err)
+	assert.Contains(t, report.Migrated, strings.ToLower(legacy))
+	assert.Contains(t, report.Duplicates, DuplicateEmail{
+		Address:  strings.ToLower(dup),
+		Accounts: []domain.AccountID{dupIDs[1], dupIDs[0]},
+	})
+	_, err = repo.Connection.Get(ctx, "auth:account:email:"+legacy, new(json.RawMessage))
+	assert.ErrorIs(t, err, corerepo.ErrNotFound)
+	var doc corerepo.DocumentWithEvents[struct{ domain.AccountID }]
+	_, err = repo.Connection.Get(ctx, "auth:account:email:"+strings.ToLower(legacy), &doc)
+	assert.NoError(t, err)
+	assert.Equal(t, legacyID, doc.Document.AccountID)
+
+	report, err = repo.MigrateEmailDocuments(ctx, false)
+	assert.NoError(t, err)
+	assert.NotContains(t, report.Migrated, strings.ToLower(legacy), "Migration is idempotent")
+}
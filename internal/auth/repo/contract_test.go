@@ -0,0 +1,77 @@
+package repo_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"harmony/internal/auth"
+	"harmony/internal/auth/domain"
+	. "harmony/internal/auth/repo"
+	"harmony/internal/core"
+	"harmony/internal/core/corerepo"
+	"harmony/internal/testing/domaintest"
+	"harmony/internal/testing/repotest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// accountContractRepository adapts the AccountRepository to the repository
+// contract. Accounts are inserted with password authentication, so a password
+// is generated on insert.
+type accountContractRepository struct{ AccountRepository }
+
+func (r accountContractRepository) Insert(
+	ctx context.Context, uc core.UseCaseResult[domain.Account],
+) (domain.Account, error) {
+	pwAuth := domaintest.InitPasswordAuthAccount()
+	pwAuth.Account = uc.Entity
+	res, err := r.AccountRepository.Insert(ctx, auth.AccountUseCaseResult{
+		Entity: pwAuth,
+		Events: uc.Events,
+	})
+	return res.Account, err
+}
+
+func TestAccountRepositoryContract(t *testing.T) {
+	repo := initRepository()
+	repotest.RunRepositoryContract(t, repotest.RepositoryContract[domain.Account, domain.AccountID]{
+		Repository: accountContractRepository{repo},
+		NewEntity:  func() domain.Account { return domaintest.InitAccount() },
+		ID:         func(acc domain.Account) domain.AccountID { return acc.ID },
+		Modify:     func(acc *domain.Account) { acc.DisplayName = domain.NewID() },
+		StoredEvents: func(t testing.TB, id domain.AccountID) (res []core.DomainEvent) {
+			data, err := repo.ExportData(t.Context(), id)
+			assert.NoError(t, err)
+			for _, raw := range data.Documents {
+				var doc corerepo.DocumentWithEvents[json.RawMessage]
+				assert.NoError(t, json.Unmarshal(raw, &doc))
+				res = append(res, doc.Events...)
+			}
+			return res
+		},
+	})
+}
+
+func TestTombstoneRepositoryContract(t *testing.T) {
+	corerepo.AssertInitialized()
+	db := &corerepo.DefaultConnection
+	repotest.RunRepositoryContract(t, repotest.RepositoryContract[domain.Tombstone, string]{
+		Repository: NewTombstoneRepository(db),
+		NewEntity: func() domain.Tombstone {
+			return domain.Tombstone{
+				EmailHash: domain.HashEmail(domaintest.NewAddress()),
+				DeletedAt: time.Now().UTC(),
+			}
+		},
+		ID:     func(t domain.Tombstone) string { return t.EmailHash },
+		Modify: func(t *domain.Tombstone) { t.DeletedAt = t.DeletedAt.Add(time.Second) },
+		StoredEvents: func(t testing.TB, emailHash string) []core.DomainEvent {
+			var doc corerepo.DocumentWithEvents[json.RawMessage]
+			_, err := db.Get(t.Context(), "auth:tombstone:"+emailHash, &doc)
+			assert.NoError(t, err)
+			return doc.Events
+		},
+	})
+}
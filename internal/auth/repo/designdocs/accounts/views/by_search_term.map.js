@@ -0,0 +1,11 @@
+function(doc) {
+	if (doc._id.startsWith("auth:account:") && doc.Email && doc.Email.Address) {
+		emit(doc.Email.Address.Address.toLowerCase(), null)
+		if (doc.Name) {
+			emit(doc.Name.toLowerCase(), null)
+		}
+		if (doc.DisplayName) {
+			emit(doc.DisplayName.toLowerCase(), null)
+		}
+	}
+}
@@ -0,0 +1,10 @@
+function(doc) {
+	if (doc._id.startsWith("domain_event:") && doc.Body) {
+		var ids = [doc.Body.account_id, doc.Body.AccountID, doc.Body.admin_id]
+		ids.forEach(function(id, i) {
+			if (id && ids.indexOf(id) === i) {
+				emit(id, null)
+			}
+		})
+	}
+}
@@ -0,0 +1,5 @@
+function(doc) {
+	if (doc._id.startsWith("auth:account:") && doc.Email && !doc.Email.Validated && doc.RegisteredAt) {
+		emit(doc.RegisteredAt, null)
+	}
+}
@@ -0,0 +1,137 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"harmony/internal/auth/domain"
+	"harmony/internal/core/corerepo"
+	"slices"
+	"strings"
+)
+
+// DuplicateEmail describes multiple accounts registered with email addresses
+// that only differ by case.
+type DuplicateEmail struct {
+	Address  string
+	Accounts []domain.AccountID
+}
+
+// EmailMigrationReport describes the result of [AccountRepository.MigrateEmailDocuments].
+type EmailMigrationReport struct {
+	// Migrated contains the addresses of email documents moved to their
+	// canonical ID.
+	Migrated []string
+	// Duplicates contains addresses used by more than one account. These are
+	// not migrated, and must be resolved manually.
+	Duplicates []DuplicateEmail
+}
+
+type emailDocRow struct {
+	id  string
+	rev string
+	doc corerepo.DocumentWithEvents[accountEmailDoc]
+}
+
+// MigrateEmailDocuments moves email documents stored before email addresses
+// were normalized to their canonical ID, see [domain.CanonicalAddress].
+// Addresses registered by more than one account are reported as duplicates.
+//
+// If dryRun is true, the database is not modified, and the report describes
+// the changes that would be made.
+func (r AccountRepository) MigrateEmailDocuments(
+	ctx context.Context,
+	dryRun bool,
+) (report EmailMigrationReport, err error) {
+	rows, err := r.allEmailDocs(ctx)
+	if err != nil {
+		return report, fmt.Errorf("AccountRepository.MigrateEmailDocuments: %w", err)
+	}
+	groups := make(map[string][]emailDocRow)
+	var addresses []string
+	for _, row := range rows {
+		addr := domain.CanonicalAddress(strings.TrimPrefix(row.id, emailDocPrefix))
+		if _, found := groups[addr]; !found {
+			addresses = append(addresses, addr)
+		}
+		groups[addr] = append(groups[addr], row)
+	}
+	slices.Sort(addresses)
+
+	var errs []error
+	for _, addr := range addresses {
+		group := groups[addr]
+		if !sameAccount(group) {
+			dup := DuplicateEmail{Address: addr}
+			for _, row := range group {
+				dup.Accounts = append(dup.Accounts, row.doc.Document.AccountID)
+			}
+			report.Duplicates = append(report.Duplicates, dup)
+			continue
+		}
+		// More than one document of the same account is left by an interrupted
+		// run, after copying the document, but before deleting the original.
+		id := r.addrDocId(addr)
+		legacy := slices.DeleteFunc(group, func(row emailDocRow) bool { return row.id == id })
+		if len(legacy) == 0 {
+			continue
+		}
+		report.Migrated = append(report.Migrated, addr)
+		if dryRun {
+			continue
+		}
+		for _, row := range legacy {
+			errs = append(errs, r.moveEmailDoc(ctx, row, id))
+		}
+	}
+	if err = errors.Join(errs...); err != nil {
+		err = fmt.Errorf("AccountRepository.MigrateEmailDocuments: %w", err)
+	}
+	return report, err
+}
+
+func sameAccount(rows []emailDocRow) bool {
+	return !slices.ContainsFunc(rows, func(row emailDocRow) bool {
+		return row.doc.Document.AccountID != rows[0].doc.Document.AccountID
+	})
+}
+
+// moveEmailDoc inserts the document under the new ID before deleting the
+// original, so the account can always be found by email. If a document with
+// the new ID exists, e.g., moved by another instance of the server, the
+// original is only deleted if the existing document belongs to the same
+// account.
+func (r AccountRepository) moveEmailDoc(ctx context.Context, row emailDocRow, id string) error {
+	doc := row.doc
+	doc.ID = ""
+	doc.Rev = ""
+	_, err := r.Connection.Insert(ctx, id, doc)
+	if errors.Is(err, corerepo.ErrConflict) {
+		var existing corerepo.DocumentWithEvents[accountEmailDoc]
+		if _, err = r.Connection.Get(ctx, id, &existing); err == nil &&
+			existing.Document.AccountID != doc.Document.AccountID {
+			err = errEmailConflict
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("move %s: %w", row.id, err)
+	}
+	if _, err := r.Connection.Delete(ctx, row.id, row.rev); err != nil {
+		return fmt.Errorf("delete %s: %w", row.id, err)
+	}
+	return nil
+}
+
+func (r AccountRepository) allEmailDocs(ctx context.Context) ([]emailDocRow, error) {
+	res, err := corerepo.AllDocs[corerepo.DocumentWithEvents[accountEmailDoc]](
+		ctx, r.Connection, corerepo.PrefixQuery(emailDocPrefix),
+	)
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]emailDocRow, len(res.Rows))
+	for i, row := range res.Rows {
+		rows[i] = emailDocRow{id: row.ID, rev: row.Doc.Rev, doc: row.Doc}
+	}
+	return rows, nil
+}
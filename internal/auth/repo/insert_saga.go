@@ -0,0 +1,46 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"harmony/internal/core/corerepo"
+	"harmony/internal/infrastructure/log"
+	"slices"
+)
+
+// insertSaga inserts multiple documents as a single operation. CouchDB doesn't
+// support transactions, so if an insert fails, the documents already inserted
+// are deleted by calling compensate.
+type insertSaga struct {
+	conn corerepo.Connection
+	ids  []string
+}
+
+// insert inserts the document, recording the ID before the request, as a
+// failed request may still have created the document, e.g., if the response
+// was lost. A conflict means the document was created by someone else, so it
+// is not compensated.
+func (s *insertSaga) insert(ctx context.Context, id string, doc any) (string, error) {
+	s.ids = append(s.ids, id)
+	rev, err := s.conn.Insert(ctx, id, doc)
+	if errors.Is(err, corerepo.ErrConflict) {
+		s.ids = s.ids[:len(s.ids)-1]
+	}
+	return rev, err
+}
+
+// compensate deletes the inserted documents in reverse order. The deletes
+// are not cancelled with the context, as that would leave orphaned documents
+// behind.
+func (s *insertSaga) compensate(ctx context.Context) error {
+	ctx = context.WithoutCancel(ctx)
+	var errs []error
+	for _, id := range slices.Backward(s.ids) {
+		if err := s.conn.DeleteIfExists(ctx, id); err != nil {
+			log.LogError(ctx, "insertSaga: compensating delete failed", err, "id", id)
+			errs = append(errs, err)
+		}
+	}
+	s.ids = nil
+	return errors.Join(errs...)
+}
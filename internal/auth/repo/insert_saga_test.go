@@ -0,0 +1,89 @@
+package repo_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"harmony/internal/auth/domain"
+	. "harmony/internal/auth/repo"
+	"harmony/internal/core"
+	"harmony/internal/core/corerepo"
+	"harmony/internal/testing/domaintest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var errInjected = errors.New("injected fault")
+
+// faultyTransport fails requests creating documents where the ID matches
+// failDoc. Updates, e.g., deleting documents, are not affected. If lostResponse
+// is set, the request reaches the database, and the response is lost.
+type faultyTransport struct {
+	failDoc      func(id string) bool
+	lostResponse bool
+}
+
+func (t faultyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	id := req.URL.Path[strings.LastIndex(req.URL.Path, "/")+1:]
+	if req.Method == "PUT" && req.Header.Get("If-Match") == "" && t.failDoc(id) {
+		if t.lostResponse {
+			if resp, err := http.DefaultTransport.RoundTrip(req); err == nil {
+				resp.Body.Close()
+			}
+		}
+		return nil, errInjected
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestAccountRepositoryInsertCompensation(t *testing.T) {
+	failurePoints := map[string]func(acc domain.PasswordAuthentication) func(string) bool{
+		"Account document": func(acc domain.PasswordAuthentication) func(string) bool {
+			return func(id string) bool { return id == "auth:account:"+string(acc.ID) }
+		},
+		"Password document": func(acc domain.PasswordAuthentication) func(string) bool {
+			return func(id string) bool { return strings.HasSuffix(id, ":password") }
+		},
+		"Email document": func(acc domain.PasswordAuthentication) func(string) bool {
+			return func(id string) bool { return strings.HasPrefix(id, "auth:account:email:") }
+		},
+	}
+	for name, failDoc := range failurePoints {
+		for _, lostResponse := range []bool{false, true} {
+			name := name
+			if lostResponse {
+				name += " with lost response"
+			}
+			t.Run(name, func(t *testing.T) {
+				ctx := t.Context()
+				repo := initRepository()
+				acc := domaintest.InitPasswordAuthAccount()
+				uc := core.UseCaseOfEntity(acc)
+				uc.AddEvent(domain.CreateAccountRegisteredEvent(acc.Account, nil))
+
+				faultyRepo := AccountRepository{
+					Connection: repo.Connection.WithHTTPClient(&http.Client{
+						Transport: faultyTransport{failDoc(acc), lostResponse},
+					}),
+				}
+				_, err := faultyRepo.Insert(ctx, uc)
+				assert.ErrorIs(t, err, corerepo.ErrConn)
+
+				_, err = repo.Get(ctx, acc.ID)
+				assert.ErrorIs(t, err, corerepo.ErrNotFound, "Account document")
+				_, err = repo.Connection.Get(ctx,
+					"auth:account:"+string(acc.ID)+":password", new(json.RawMessage))
+				assert.ErrorIs(t, err, corerepo.ErrNotFound, "Password document")
+				_, err = repo.Connection.Get(ctx,
+					"auth:account:email:"+acc.Email.String(), new(json.RawMessage))
+				assert.ErrorIs(t, err, corerepo.ErrNotFound, "Email document")
+
+				_, err = repo.Insert(ctx, uc)
+				assert.NoError(t, err, "Inserting the account after the failure")
+			})
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package repo
+
+import (
+	"context"
+	"fmt"
+	"harmony/internal/auth/domain"
+	"harmony/internal/core"
+	"harmony/internal/core/corerepo"
+	"harmony/internal/infrastructure/log"
+	"time"
+)
+
+// FixPasswordPrefix moves password documents stored with the misspelled
+// "auth:accunt:" prefix to "auth:account:".
+var FixPasswordPrefix = corerepo.Migration{
+	Version: 2026_10_19_01,
+	Name:    "auth: fix misspelled prefix of password documents",
+	Run: func(ctx context.Context, m *corerepo.Migrator) error {
+		return m.RenamePrefix(ctx, "auth:accunt:", "auth:account:")
+	},
+}
+
+// BackfillRegisteredAt sets the registration time of accounts registered
+// before it was stored, from the AccountRegistered event. Accounts without the
+// event get the time of the migration, so they are not removed as abandoned
+// registrations before the full age has passed.
+var BackfillRegisteredAt = corerepo.Migration{
+	Version: 2026_10_19_02,
+	Name:    "auth: set registration time of accounts",
+	Run: func(ctx context.Context, m *corerepo.Migrator) error {
+		now := core.Now(m.Clock).Truncate(time.Second)
+		return m.RewriteDocs(ctx, "auth:account:", func(doc map[string]any) (bool, error) {
+			_, isAccount := doc["Email"]
+			if !isAccount || doc["RegisteredAt"] != nil {
+				return false, nil
+			}
+			id, _ := doc["ID"].(string)
+			registeredAt, err := registrationTime(ctx, m.Connection, domain.AccountID(id))
+			if err != nil {
+				return false, err
+			}
+			if registeredAt.IsZero() {
+				registeredAt = now
+			}
+			doc["RegisteredAt"] = registeredAt.UTC().Truncate(time.Second)
+			return true, nil
+		})
+	},
+}
+
+// CanonicalEmailDocuments moves email documents stored before email addresses
+// were normalized to their canonical ID; see
+// [AccountRepository.MigrateEmailDocuments]. Addresses used by more than one
+// account are logged, and must be resolved manually, e.g., using the report
+// of cmd/migrateemails.
+var CanonicalEmailDocuments = corerepo.Migration{
+	Version: 2026_10_19_03,
+	Name:    "auth: move email documents to canonical addresses",
+	Run: func(ctx context.Context, m *corerepo.Migrator) error {
+		repo := AccountRepository{Connection: m.Connection}
+		report, err := repo.MigrateEmailDocuments(ctx, m.DryRun)
+		m.Changed(len(report.Migrated))
+		for _, dup := range report.Duplicates {
+			log.Warn(ctx, "auth: email address used by more than one account",
+				"accounts", dup.Accounts)
+		}
+		return err
+	},
+}
+
+// registrationTime returns the time of the AccountRegistered event of the
+// account, or the zero time if not found.
+func registrationTime(
+	ctx context.Context, conn corerepo.Connection, id domain.AccountID,
+) (time.Time, error) {
+	res, err := corerepo.QueryViewDocs[core.DomainEvent](
+		ctx, conn, designDocID, "domain_events_by_account", corerepo.KeyQuery(id),
+	)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("registration time of %s: %w", id, err)
+	}
+	for _, e := range res.Docs() {
+		if _, ok := e.Body.(domain.AccountRegistered); ok {
+			return e.CreatedAt, nil
+		}
+	}
+	return time.Time{}, nil
+}
+
+func init() {
+	corerepo.RegisterMigration(FixPasswordPrefix)
+	corerepo.RegisterMigration(BackfillRegisteredAt)
+	corerepo.RegisterMigration(CanonicalEmailDocuments)
+}
@@ -0,0 +1,123 @@
+package repo_test
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"harmony/internal/auth"
+	"harmony/internal/auth/domain"
+	"harmony/internal/auth/domain/password"
+	. "harmony/internal/auth/repo"
+	"harmony/internal/core"
+	"harmony/internal/core/corerepo"
+	"harmony/internal/testing/clocktest"
+	"harmony/internal/testing/domaintest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFixPasswordPrefix(t *testing.T) {
+	ctx := t.Context()
+	repo := initRepository()
+	conn := repo.Connection
+	acc := domaintest.InitPasswordAuthAccount(domaintest.WithPassword("foobar"))
+	assert.NoError(t, insertAccount(ctx, repo, auth.AccountUseCaseResult{Entity: acc}))
+
+	// Store the password document under the legacy ID
+	id := "auth:account:" + string(acc.ID) + ":password"
+	legacyID := "auth:accunt:" + string(acc.ID) + ":password"
+	var doc map[string]any
+	rev, err := conn.Get(ctx, id, &doc)
+	assert.NoError(t, err)
+	delete(doc, "_id")
+	delete(doc, "_rev")
+	_, err = conn.Insert(ctx, legacyID, doc)
+	assert.NoError(t, err)
+	_, err = conn.Delete(ctx, id, rev)
+	assert.NoError(t, err)
+
+	assert.NoError(t, FixPasswordPrefix.Run(ctx, &corerepo.Migrator{Connection: conn, BatchSize: 10}))
+
+	_, err = conn.Get(ctx, legacyID, new(json.RawMessage))
+	assert.ErrorIs(t, err, corerepo.ErrNotFound, "Legacy document deleted")
+	found, err := repo.FindPWAuthByEmail(ctx, acc.Email.String())
+	assert.NoError(t, err)
+	assert.Equal(t, acc.ID, found.ID)
+	assert.True(t, found.Validate(password.Parse("foobar")))
+}
+
+func TestBackfillRegisteredAt(t *testing.T) {
+	ctx := t.Context()
+	repo := initRepository()
+	assert.NoError(t, repo.Bootstrap(ctx))
+	conn := repo.Connection
+
+	withEvent := domaintest.InitPasswordAuthAccount()
+	assert.NoError(t, insertAccount(ctx, repo, core.UseCaseOfEntity(withEvent)))
+	// Events are moved to the event store when processed by the message source
+	registered := domain.CreateAccountRegisteredEvent(
+		withEvent.Account, clocktest.At(time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)),
+	)
+	_, err := corerepo.DefaultDomainEventRepo.Insert(ctx, registered)
+	assert.NoError(t, err)
+	withoutEvent := domaintest.InitPasswordAuthAccount()
+	assert.NoError(t, insertAccount(ctx, repo, core.UseCaseOfEntity(withoutEvent)))
+
+	clock := clocktest.At(time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC))
+	assert.NoError(t, BackfillRegisteredAt.Run(ctx,
+		&corerepo.Migrator{Connection: conn, Clock: clock, BatchSize: 10}))
+
+	acc, err := repo.Get(ctx, withEvent.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, registered.CreatedAt, acc.RegisteredAt, "Time of registration event")
+	acc, err = repo.Get(ctx, withoutEvent.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, clock.Now(), acc.RegisteredAt, "Time of migration without event")
+}
+
+func TestCanonicalEmailDocuments(t *testing.T) {
+	ctx := t.Context()
+	repo := initRepository()
+	conn := repo.Connection
+	legacyID := func(acc domain.PasswordAuthentication) string {
+		return "auth:account:email:" + strings.ToUpper(acc.Email.String())
+	}
+	insert := func(acc domain.PasswordAuthentication) {
+		t.Helper()
+		assert.NoError(t, insertAccount(ctx, repo, auth.AccountUseCaseResult{Entity: acc}))
+		_, err := conn.Insert(ctx, legacyID(acc),
+			corerepo.DocumentWithEvents[struct{ domain.AccountID }]{
+				Document: struct{ domain.AccountID }{acc.ID},
+			})
+		assert.NoError(t, err)
+	}
+
+	// Stored before email addresses were normalized
+	legacy := domaintest.InitPasswordAuthAccount()
+	insert(legacy)
+	var doc json.RawMessage
+	id := "auth:account:email:" + legacy.Email.Canonical()
+	rev, err := conn.Get(ctx, id, &doc)
+	assert.NoError(t, err)
+	_, err = conn.Delete(ctx, id, rev)
+	assert.NoError(t, err)
+	// Left by an interrupted migration, after copying the document
+	interrupted := domaintest.InitPasswordAuthAccount()
+	insert(interrupted)
+
+	assert.NoError(t, CanonicalEmailDocuments.Run(ctx, &corerepo.Migrator{Connection: conn}))
+
+	for _, acc := range []domain.PasswordAuthentication{legacy, interrupted} {
+		found, err := repo.FindByEmail(ctx, acc.Email.String())
+		assert.NoError(t, err)
+		assert.Equal(t, acc.ID, found.ID)
+		_, err = conn.Get(ctx, legacyID(acc), &doc)
+		assert.ErrorIs(t, err, corerepo.ErrNotFound, "Legacy document deleted")
+	}
+	assert.True(t, slices.ContainsFunc(corerepo.RegisteredMigrations(),
+		func(m corerepo.Migration) bool { return m.Version == CanonicalEmailDocuments.Version },
+	), "Applied by bootstrap")
+}
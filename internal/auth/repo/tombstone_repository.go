@@ -0,0 +1,23 @@
+package repo
+
+import (
+	"harmony/internal/auth/domain"
+	"harmony/internal/core/corerepo"
+)
+
+// TombstoneRepository stores the tombstones of deleted accounts, identified by
+// the hash of the email address.
+type TombstoneRepository = corerepo.Repository[domain.Tombstone, string]
+
+// NewTombstoneRepository creates a TombstoneRepository storing tombstones in the
+// database.
+func NewTombstoneRepository(db *corerepo.Connection) TombstoneRepository {
+	return corerepo.NewRepository(db, tombstoneMapper{})
+}
+
+type tombstoneMapper struct{}
+
+func (tombstoneMapper) ID(t domain.Tombstone) string           { return t.EmailHash }
+func (tombstoneMapper) DocID(emailHash string) string          { return tombstoneDocID(emailHash) }
+func (tombstoneMapper) Rev(t domain.Tombstone) string          { return t.Rev }
+func (tombstoneMapper) SetRev(t *domain.Tombstone, rev string) { t.Rev = rev }
@@ -4,7 +4,9 @@ import (
 	"context"
 	"harmony/internal/auth"
 	domain "harmony/internal/auth/domain"
+	"harmony/internal/core"
 	"harmony/internal/testing/repotest"
+	"strings"
 	"testing"
 )
 
@@ -14,6 +16,11 @@ func (t PWAuthTranslator) ID(e domain.PasswordAuthentication) domain.AccountID {
 	return e.Account.ID
 }
 
+func (t PWAuthTranslator) Rev(e domain.PasswordAuthentication) string { return e.Rev }
+func (t PWAuthTranslator) SetRev(e *domain.PasswordAuthentication, rev string) {
+	e.Rev = rev
+}
+
 type PWAuthRepositoryStub struct {
 	repotest.RepositoryStub[domain.PasswordAuthentication, domain.AccountID]
 }
@@ -22,6 +29,16 @@ func NewPWAuthRepositoryStub(t testing.TB) *PWAuthRepositoryStub {
 	return &PWAuthRepositoryStub{repotest.NewRepositoryStub(t, PWAuthTranslator{})}
 }
 
+// Insert inserts the account, unless the email address is already registered.
+func (i *PWAuthRepositoryStub) Insert(
+	ctx context.Context, acc auth.AccountUseCaseResult,
+) (domain.PasswordAuthentication, error) {
+	if _, err := i.FindPWAuthByEmail(ctx, acc.Entity.Email.String()); err == nil {
+		return acc.Entity, auth.ErrEmailAlreadyRegistered
+	}
+	return i.RepositoryStub.Insert(ctx, acc)
+}
+
 func (i PWAuthRepositoryStub) FindPWAuthByEmail(
 	ctx context.Context, email string,
 ) (domain.PasswordAuthentication, error) {
@@ -33,12 +50,49 @@ func (i PWAuthRepositoryStub) FindPWAuthByEmail(
 	return domain.PasswordAuthentication{}, auth.ErrNotFound
 }
 
+// UpdateWithEvents updates the account of the stored password authentication.
+func (i *PWAuthRepositoryStub) UpdateWithEvents(
+	ctx context.Context, uc core.UseCaseResult[domain.Account],
+) (domain.Account, error) {
+	existing, err := i.Get(ctx, uc.Entity.ID)
+	if err != nil {
+		return uc.Entity, err
+	}
+	existing.Account = uc.Entity
+	res, err := i.RepositoryStub.UpdateWithEvents(
+		ctx, core.UseCaseResult[domain.PasswordAuthentication]{Entity: existing, Events: uc.Events},
+	)
+	return res.Account, err
+}
+
+func (i *PWAuthRepositoryStub) UpdatePassword(
+	ctx context.Context, uc core.UseCaseResult[domain.PasswordAuthentication],
+) (domain.PasswordAuthentication, error) {
+	return i.RepositoryStub.UpdateWithEvents(ctx, uc)
+}
+
 type AccountTranslator struct{}
 
 func (t AccountTranslator) ID(e domain.Account) domain.AccountID {
 	return e.ID
 }
 
+func (t AccountTranslator) Rev(e domain.Account) string          { return e.Rev }
+func (t AccountTranslator) SetRev(e *domain.Account, rev string) { e.Rev = rev }
+
+// pwAuthAccounts exposes the accounts of the stored password authentications,
+// e.g., to the [auth.EmailValidator].
+type pwAuthAccounts struct{ *PWAuthRepositoryStub }
+
+func (r pwAuthAccounts) Get(ctx context.Context, id domain.AccountID) (domain.Account, error) {
+	res, err := r.PWAuthRepositoryStub.Get(ctx, id)
+	return res.Account, err
+}
+
+func (r pwAuthAccounts) Update(ctx context.Context, acc domain.Account) (domain.Account, error) {
+	return r.UpdateWithEvents(ctx, core.UseCaseOfEntity(acc))
+}
+
 type AccountRepositoryStub struct {
 	repotest.RepositoryStub[domain.Account, domain.AccountID]
 }
@@ -57,3 +111,21 @@ func (i AccountRepositoryStub) FindByEmail(
 	}
 	return domain.Account{}, auth.ErrNotFound
 }
+
+func (i AccountRepositoryStub) Search(
+	ctx context.Context, query string, limit int,
+) (res []domain.Account, err error) {
+	query = strings.ToLower(query)
+	for _, v := range i.Entities {
+		if len(res) == limit {
+			break
+		}
+		for _, field := range []string{v.Email.String(), v.Name, v.DisplayName} {
+			if strings.HasPrefix(strings.ToLower(field), query) {
+				res = append(res, *v)
+				break
+			}
+		}
+	}
+	return res, nil
+}
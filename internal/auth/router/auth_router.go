@@ -2,9 +2,13 @@ package router
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"net"
 	"net/http"
 	"net/mail"
+	"net/url"
 
 	"harmony/internal/auth"
 	"harmony/internal/auth/domain"
@@ -40,12 +44,24 @@ type EmailValidator interface {
 	) (domain.AuthenticatedAccount, error)
 }
 
+type AccountSelfService interface {
+	DeleteAccount(context.Context, domain.AuthenticatedAccount, password.Password) error
+	ExportData(context.Context, domain.AuthenticatedAccount) (auth.AccountExport, error)
+}
+
+type PasswordResetter interface {
+	RequestReset(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, input auth.ResetPasswordInput) error
+}
+
 type AuthRouter struct {
 	*http.ServeMux
-	Authenticator  Authenticator
-	Registrator    Registrator
-	SessionManager SessionManager
-	EmailValidator EmailValidator
+	Authenticator    Authenticator
+	Registrator      Registrator
+	SessionManager   SessionManager
+	EmailValidator   EmailValidator
+	SelfService      AccountSelfService
+	PasswordResetter PasswordResetter
 }
 
 func (s *AuthRouter) PostRegister(w http.ResponseWriter, r *http.Request) {
@@ -85,6 +101,16 @@ func (s *AuthRouter) PostRegister(w http.ResponseWriter, r *http.Request) {
 		registerInput.NewsletterSignup = data.NewsletterSignup
 		err = s.Registrator.Register(r.Context(), registerInput)
 	}
+	if errors.Is(err, auth.ErrEmailAlreadyRegistered) {
+		formData.Email.Value = data.Email
+		formData.Email.Errors = []string{"This email address is already registered"}
+	}
+	if errors.Is(err, auth.ErrEmailRecentlyDeleted) {
+		formData.Email.Value = data.Email
+		formData.Email.Errors = []string{
+			"The email address belongs to a recently deleted account",
+		}
+	}
 	if err != nil {
 		log.Error(r.Context(), "error", "err", err)
 		formData.Fullname = data.Fullname
@@ -122,12 +148,16 @@ func (s *AuthRouter) PostAuthLogin(w http.ResponseWriter, r *http.Request) {
 		w.Header().Add("hx-retarget", "body")
 		rewrite(w, r, redirectUrl, "")
 	} else {
-		authError := errors.Is(err, auth.ErrBadCredentials)
+		badCredentials := errors.Is(err, auth.ErrBadCredentials)
+		locked := errors.Is(err, auth.ErrAccountLocked)
+		resetRequired := errors.Is(err, auth.ErrPasswordResetRequired)
 		data := views.LoginFormData{
-			Email:              email,
-			Password:           "",
-			InvalidCredentials: authError,
-			UnexpectedError:    !authError,
+			Email:                 email,
+			Password:              "",
+			InvalidCredentials:    badCredentials,
+			AccountLocked:         locked,
+			PasswordResetRequired: resetRequired,
+			UnexpectedError:       !badCredentials && !locked && !resetRequired,
 		}
 		if r.FormValue("email") == "" {
 			data.EmailMissing = true
@@ -160,6 +190,131 @@ func (r *AuthRouter) Init() {
 		},
 	)
 	r.HandleFunc("POST /validate-email", r.postValidateEmail)
+	r.HandleFunc("GET /reset-password", func(w http.ResponseWriter, r *http.Request) {
+		views.ResetPasswordPage(views.ResetPasswordForm{}).Render(r.Context(), w)
+	})
+	r.HandleFunc("POST /reset-password", r.postResetPassword)
+	r.HandleFunc("GET /reset-password/confirm",
+		func(w http.ResponseWriter, r *http.Request) {
+			views.ConfirmPasswordResetPage(views.ConfirmPasswordResetForm{
+				EmailAddress: r.URL.Query().Get("email"),
+			}).Render(r.Context(), w)
+		},
+	)
+	r.HandleFunc("POST /reset-password/confirm", r.postConfirmPasswordReset)
+	r.Handle("GET /account", RequireAuth(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			views.AccountPage(views.DeleteAccountFormData{}).Render(r.Context(), w)
+		},
+	)))
+	r.Handle("GET /account/export", RequireAuth(http.HandlerFunc(r.getAccountExport)))
+	r.Handle("POST /account/delete", RequireAuth(http.HandlerFunc(r.postDeleteAccount)))
+}
+
+func (router *AuthRouter) getAccountExport(w http.ResponseWriter, r *http.Request) {
+	acc, _ := auth.AuthenticatedUser(r.Context())
+	export, err := router.SelfService.ExportData(r.Context(), acc)
+	if err != nil {
+		log.LogError(r.Context(), "AuthRouter: export account data", err)
+		http.Error(w, "Unexpected error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition",
+		fmt.Sprintf(`attachment; filename="harmony-account-%s.json"`, acc.ID))
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(export); err != nil {
+		log.LogError(r.Context(), "AuthRouter: write account data", err)
+	}
+}
+
+func (router *AuthRouter) postDeleteAccount(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	acc, _ := auth.AuthenticatedUser(r.Context())
+	err := router.SelfService.DeleteAccount(
+		r.Context(), acc, password.Parse(r.FormValue("password")),
+	)
+	if err != nil {
+		data := views.DeleteAccountFormData{
+			InvalidPassword: errors.Is(err, auth.ErrBadCredentials),
+		}
+		data.UnexpectedError = !data.InvalidPassword
+		if data.UnexpectedError {
+			log.LogError(r.Context(), "AuthRouter: delete account", err)
+		}
+		views.AccountPage(data).Render(r.Context(), w)
+		return
+	}
+	if err := router.SessionManager.Logout(w, r); err != nil {
+		log.LogError(r.Context(), "AuthRouter: logout deleted account", err)
+	}
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func (router *AuthRouter) postResetPassword(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	email := r.FormValue("email")
+	if err := router.PasswordResetter.RequestReset(r.Context(), email); err != nil {
+		data := views.ResetPasswordForm{
+			EmailAddress:    email,
+			TooManyAttempts: errors.Is(err, auth.ErrTooManyAttempts),
+		}
+		data.UnexpectedError = !data.TooManyAttempts
+		if data.UnexpectedError {
+			log.LogError(r.Context(), "AuthRouter: request password reset", err)
+		}
+		views.ResetPasswordFormContent(data).Render(r.Context(), w)
+		return
+	}
+	w.Header().Add("hx-push-url", "./reset-password/confirm?email="+url.QueryEscape(email))
+	w.Header().Add("hx-retarget", "body")
+	views.ConfirmPasswordResetPage(views.ConfirmPasswordResetForm{
+		EmailAddress: email,
+	}).Render(r.Context(), w)
+}
+
+func (router *AuthRouter) postConfirmPasswordReset(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	data := views.ConfirmPasswordResetForm{
+		EmailAddress:    r.FormValue("email"),
+		Code:            r.FormValue("reset-code"),
+		PasswordMissing: r.FormValue("password") == "",
+	}
+	if data.PasswordMissing {
+		views.ConfirmPasswordResetFormContent(data).Render(r.Context(), w)
+		return
+	}
+	err := router.PasswordResetter.ResetPassword(r.Context(), auth.ResetPasswordInput{
+		Email:      data.EmailAddress,
+		Code:       domain.EmailValidationCode(data.Code),
+		Password:   password.Parse(r.FormValue("password")),
+		RemoteAddr: remoteHost(r),
+	})
+	if err != nil {
+		data.InvalidCode = errors.Is(err, auth.ErrBadChallengeResponse)
+		data.CodeExpired = errors.Is(err, domain.ErrEmailChallengeExpired)
+		data.TooManyAttempts = errors.Is(err, auth.ErrTooManyAttempts)
+		data.UnexpectedError = !data.InvalidCode && !data.CodeExpired && !data.TooManyAttempts
+		if data.UnexpectedError {
+			log.LogError(r.Context(), "AuthRouter: reset password", err)
+		}
+		views.ConfirmPasswordResetFormContent(data).Render(r.Context(), w)
+		return
+	}
+	w.Header().Add("hx-push-url", "/auth/login")
+	w.Header().Add("hx-retarget", "body")
+	views.Login("/", views.LoginFormData{Email: data.EmailAddress}).Render(r.Context(), w)
+}
+
+// remoteHost returns the address of the client without the port, which
+// changes with each connection.
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
 }
 
 func (router *AuthRouter) postLogout(w http.ResponseWriter, r *http.Request) {
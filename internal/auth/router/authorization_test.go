@@ -0,0 +1,111 @@
+package router_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"harmony/internal/auth"
+	"harmony/internal/auth/domain"
+	"harmony/internal/auth/router"
+	"harmony/internal/testing/domaintest"
+	"harmony/internal/testing/htest"
+
+	"github.com/gost-dom/browser"
+	"github.com/gost-dom/browser/html"
+	. "github.com/gost-dom/browser/testing/gomega-matchers"
+	"github.com/gost-dom/browser/testing/gosttest"
+	"github.com/gost-dom/shaman"
+	"github.com/gost-dom/shaman/ariarole"
+	. "github.com/gost-dom/shaman/predicates"
+	"github.com/onsi/gomega"
+	"github.com/stretchr/testify/suite"
+)
+
+// protectedHandler creates a handler serving a page at /protected, requiring
+// the PermManageAccounts permission. If acc is not nil, requests are made
+// on behalf of the account.
+func protectedHandler(acc *domain.AuthenticatedAccount) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("GET /protected", router.RequirePermission(domain.PermManageAccounts)(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, "<html><body><main><h1>Protected</h1></main></body></html>")
+		}),
+	))
+	mux.Handle("GET /static/", http.StripPrefix("/static",
+		http.FileServer(http.Dir("../../../static"))))
+	return router.RewriterMiddleware(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if acc != nil {
+				auth.SetAuthenticatedUser(&r, *acc)
+			}
+			mux.ServeHTTP(w, r)
+		}),
+	)
+}
+
+type RequirePermissionSuite struct {
+	htest.GomegaSuite
+	shaman.Scope
+	Win html.Window
+}
+
+func TestRequirePermission(t *testing.T) {
+	t.Parallel()
+	suite.Run(t, new(RequirePermissionSuite))
+}
+
+func (s *RequirePermissionSuite) openWindow(acc domain.AuthenticatedAccount) {
+	b := browser.New(
+		browser.WithHandler(protectedHandler(&acc)),
+		browser.WithLogger(gosttest.NewTestingLogger(s.T(), gosttest.AllowErrors())),
+	)
+	win, err := b.Open("https://example.com/protected")
+	s.Assert().NoError(err)
+	s.Win = win
+	s.Scope = shaman.WindowScope(s.T(), win)
+}
+
+func (s *RequirePermissionSuite) TestUserWithoutPermission() {
+	s.openWindow(domaintest.InitAuthenticatedAccount())
+
+	s.Expect(s.Get(ByH1)).To(HaveTextContent(gomega.ContainSubstring("Access denied")))
+	s.Expect(s.Get(ByRole(ariarole.Alert))).To(HaveTextContent(
+		gomega.ContainSubstring("You do not have permission to view this page")))
+}
+
+func (s *RequirePermissionSuite) TestUserWithPermission() {
+	s.openWindow(domaintest.InitAuthenticatedAccount(domaintest.WithRole(domain.RoleAdmin)))
+
+	s.Expect(s.Get(ByH1)).To(HaveTextContent("Protected"))
+	s.Expect(s.Find(ByRole(ariarole.Alert))).To(gomega.BeNil())
+}
+
+func (s *RequirePermissionSuite) TestStatusCodes() {
+	acc := domaintest.InitAuthenticatedAccount()
+
+	s.Run("Unauthenticated user is redirected to login", func() {
+		rec := httptest.NewRecorder()
+		protectedHandler(nil).ServeHTTP(rec, httptest.NewRequest("GET", "/protected", nil))
+		s.Expect(rec.Code).To(gomega.Equal(http.StatusSeeOther))
+		s.Expect(rec.Header().Get("Location")).To(gomega.HavePrefix(router.PathAuthLogin))
+	})
+
+	s.Run("Non-HTMX request", func() {
+		rec := httptest.NewRecorder()
+		protectedHandler(&acc).ServeHTTP(rec, httptest.NewRequest("GET", "/protected", nil))
+		s.Expect(rec.Code).To(gomega.Equal(http.StatusForbidden))
+	})
+
+	s.Run("HTMX request", func() {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/protected", nil)
+		req.Header.Set("HX-Request", "true")
+		protectedHandler(&acc).ServeHTTP(rec, req)
+		s.Expect(rec.Code).To(gomega.Equal(http.StatusOK),
+			"HTMX doesn't swap content of error responses")
+		s.Expect(rec.Header().Get("HX-Retarget")).To(gomega.Equal("body"))
+		s.Expect(rec.Body.String()).To(gomega.ContainSubstring("Access denied"))
+	})
+}
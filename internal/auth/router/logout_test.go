@@ -1,14 +1,22 @@
 package router_test
 
 import (
+	"net/http"
+	"net/http/httptest"
 	"testing"
 
+	"harmony/internal/auth/router"
+	"harmony/internal/auth/sessionstore"
+	"harmony/internal/core/corerepo"
 	"harmony/internal/testing/browsertest"
+	_ "harmony/internal/testing/couchtest" // clear database before tests
+	"harmony/internal/testing/domaintest"
 	"harmony/internal/testing/servertest"
 
 	"github.com/gost-dom/shaman"
 	"github.com/gost-dom/shaman/ariarole"
 	. "github.com/gost-dom/shaman/predicates"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestPOSTLogout(t *testing.T) {
@@ -20,3 +28,41 @@ func TestPOSTLogout(t *testing.T) {
 
 	browsertest.AssertUnauthenticated(t, win)
 }
+
+func TestPOSTLogoutDeletesSessionDocument(t *testing.T) {
+	corerepo.AssertInitialized()
+	store := sessionstore.NewCouchDBStore(
+		&corerepo.DefaultConnection,
+		sessionstore.KeyRing{sessionstore.GenerateKeyPair()},
+	)
+	acc := domaintest.InitAuthenticatedAccount()
+	r := &router.AuthRouter{
+		SessionManager: router.SessionManager{
+			SessionStore: store,
+			Repo:         repo{*acc.Account},
+		},
+	}
+	r.Init()
+
+	login := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/", nil)
+	assert.NoError(t, r.SessionManager.SetAccount(login, req, acc))
+	sessions, err := store.SessionsForAccount(t.Context(), acc.ID)
+	assert.NoError(t, err)
+	if !assert.Len(t, sessions, 1) {
+		return
+	}
+
+	req = httptest.NewRequest("POST", "/logout", nil)
+	for _, c := range login.Result().Cookies() {
+		req.AddCookie(c)
+	}
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusSeeOther, rec.Code)
+
+	_, err = corerepo.DefaultConnection.Get(
+		t.Context(), "auth:sessions:"+sessions[0].ID, new(sessionstore.SessionDoc),
+	)
+	assert.ErrorIs(t, err, corerepo.ErrNotFound, "Session document deleted")
+}
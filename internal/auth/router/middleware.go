@@ -6,12 +6,19 @@ import (
 	"net/url"
 
 	"harmony/internal/auth"
+	"harmony/internal/auth/domain"
+	"harmony/internal/auth/router/views"
+	"harmony/internal/web"
 )
 
 const (
 	PathAuthLogin = "/auth/login"
 )
 
+// isHTMXRequest returns whether the request was made by HTMX, in which case
+// the response is processed by HTMX rather than the browser.
+func isHTMXRequest(r *http.Request) bool { return r.Header.Get("HX-Request") != "" }
+
 // RequireAuth is a middleware that will only render the inner handler if the
 // user has been authenticated. Otherwise, it sends the user to the login page.
 // The original RequestURI is passed as a query parameter, allowing a successful
@@ -31,7 +38,7 @@ func RequireAuth(h http.Handler) http.Handler {
 
 		query := fmt.Sprintf("redirectUrl=%s", url.QueryEscape(r.URL.RequestURI()))
 		newURL := fmt.Sprintf("%s?%s", PathAuthLogin, query)
-		if r.Header.Get("HX-Request") == "" {
+		if !isHTMXRequest(r) {
 			http.Redirect(w, r, newURL, 303)
 		} else {
 			w.Header().Add("hx-replace-url", newURL)
@@ -39,3 +46,30 @@ func RequireAuth(h http.Handler) http.Handler {
 		}
 	})
 }
+
+// RequirePermission creates a middleware that will only render the inner
+// handler if the authenticated user has been granted the permission by
+// [domain.DefaultPolicy]. Unauthenticated users are sent to the login page, as
+// with [RequireAuth]. Authenticated users without the permission receive an
+// "Access denied" page.
+//
+// For non-HTMX requests, the page is sent with a 403 status code. HTMX doesn't
+// swap the contents of error responses, so HTMX requests receive a 200 status
+// code, and the page replaces the body.
+func RequirePermission(perm domain.Permission) web.MiddlewareFunc {
+	return func(h http.Handler) http.Handler {
+		return RequireAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if auth.UserHasPermission(r.Context(), perm) {
+				h.ServeHTTP(w, r)
+				return
+			}
+			if isHTMXRequest(r) {
+				w.Header().Add("hx-retarget", "body")
+				w.Header().Add("hx-reswap", "innerHTML")
+			} else {
+				w.WriteHeader(http.StatusForbidden)
+			}
+			views.Forbidden().Render(r.Context(), w)
+		}))
+	}
+}
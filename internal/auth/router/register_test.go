@@ -147,6 +147,27 @@ func (s *RegisterTestSuite) TestInvalidEmail() {
 	s.Expect(form.Email()).To(HaveARIADescription("Must be a valid email address"))
 }
 
+func (s *RegisterTestSuite) TestEmailAlreadyRegistered() {
+	s.AllowErrorLogs()
+	s.registrator.EXPECT().
+		Register(mock.Anything, mock.Anything).
+		Return(auth.ErrEmailAlreadyRegistered).
+		Once()
+	s.Expect(s.Get(ByH1)).To(HaveTextContent("Register Account"))
+
+	form := RegisterForm{s.Subscope(ByRole(ariarole.Form))}
+	form.FillWithValidValues()
+	form.Submit().Click()
+
+	s.Expect(s.Win.Location().Pathname()).To(Equal("/auth/register"),
+		"The browser should stay on the registration page when email is registered")
+
+	form = RegisterForm{s.Subscope(ByRole(ariarole.Form))}
+	s.Expect(form.FullName()).To(HaveAttribute("value", "John Smith"))
+	s.Expect(form.Email()).To(HaveAttribute("value", "john.smith@example.com"))
+	s.Expect(form.Email()).To(HaveARIADescription("This email address is already registered"))
+}
+
 func (s *RegisterTestSuite) TestMissingAccept() {
 	s.registrator.EXPECT().Register(mock.Anything, mock.Anything).Return(nil).Maybe()
 	s.Expect(s.Get(ByH1)).To(HaveTextContent("Register Account"))
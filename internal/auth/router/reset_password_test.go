@@ -0,0 +1,114 @@
+package router_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"harmony/internal/auth"
+	"harmony/internal/auth/domain"
+	"harmony/internal/auth/domain/password"
+	"harmony/internal/auth/router"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// passwordResetterFake accepts the code "123456" for any email address, and
+// rejects the code "000001" as attempted too many times.
+type passwordResetterFake struct {
+	requested []string
+	reset     []auth.ResetPasswordInput
+}
+
+func (f *passwordResetterFake) RequestReset(_ context.Context, email string) error {
+	f.requested = append(f.requested, email)
+	return nil
+}
+
+func (f *passwordResetterFake) ResetPassword(
+	_ context.Context, input auth.ResetPasswordInput,
+) error {
+	if input.Code == "000001" {
+		return auth.ErrTooManyAttempts
+	}
+	if input.Code != "123456" {
+		return auth.ErrBadChallengeResponse
+	}
+	f.reset = append(f.reset, input)
+	return nil
+}
+
+func initPasswordResetRouter() (*router.AuthRouter, *passwordResetterFake) {
+	fake := &passwordResetterFake{}
+	r := &router.AuthRouter{PasswordResetter: fake}
+	r.Init()
+	return r, fake
+}
+
+func postForm(r http.Handler, path string, values url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", path, strings.NewReader(values.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestPOSTResetPasswordShowsConfirmPage(t *testing.T) {
+	r, fake := initPasswordResetRouter()
+	rec := postForm(r, "/reset-password", url.Values{"email": {"j+d@example.com"}})
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, []string{"j+d@example.com"}, fake.requested)
+	assert.Equal(t,
+		"./reset-password/confirm?email=j%2Bd%40example.com",
+		rec.Header().Get("hx-push-url"))
+	assert.Contains(t, rec.Body.String(), "Choose new password")
+}
+
+func TestPOSTConfirmPasswordReset(t *testing.T) {
+	t.Run("Valid code", func(t *testing.T) {
+		r, fake := initPasswordResetRouter()
+		rec := postForm(r, "/reset-password/confirm", url.Values{
+			"email":      {"jd@example.com"},
+			"reset-code": {"123456"},
+			"password":   {"n3w s3cret"},
+		})
+
+		assert.Equal(t, "/auth/login", rec.Header().Get("hx-push-url"))
+		if assert.Len(t, fake.reset, 1) {
+			assert.Equal(t, "jd@example.com", fake.reset[0].Email)
+			assert.Equal(t, domain.EmailValidationCode("123456"), fake.reset[0].Code)
+			assert.True(t, fake.reset[0].Password.Equals(password.Parse("n3w s3cret")))
+			assert.Equal(t, "192.0.2.1", fake.reset[0].RemoteAddr, "Address without port")
+		}
+	})
+
+	t.Run("Bad code", func(t *testing.T) {
+		r, fake := initPasswordResetRouter()
+		rec := postForm(r, "/reset-password/confirm", url.Values{
+			"email":      {"jd@example.com"},
+			"reset-code": {"000000"},
+			"password":   {"n3w s3cret"},
+		})
+
+		assert.Empty(t, rec.Header().Get("hx-push-url"))
+		assert.Empty(t, fake.reset)
+		assert.Contains(t, rec.Body.String(), "Wrong email or validation code")
+	})
+
+	t.Run("Too many attempts", func(t *testing.T) {
+		r, _ := initPasswordResetRouter()
+		rec := postForm(r, "/reset-password/confirm", url.Values{
+			"email":      {"jd@example.com"},
+			"reset-code": {"000001"},
+			"password":   {"n3w s3cret"},
+		})
+
+		assert.Empty(t, rec.Header().Get("hx-push-url"))
+		assert.Contains(t, rec.Body.String(), "Too many attempts")
+		assert.NotContains(t, rec.Body.String(), "Unexpected error")
+	})
+}
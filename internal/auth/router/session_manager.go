@@ -3,6 +3,7 @@ package router
 import (
 	"context"
 	"harmony/internal/auth/domain"
+	"harmony/internal/auth/sessionstore"
 	"harmony/internal/infrastructure/log"
 	"net/http"
 
@@ -11,7 +12,7 @@ import (
 
 const (
 	sessionNameAuth   = "auth"
-	sessionAccountKey = "accountId"
+	sessionAccountKey = sessionstore.AccountIDKey
 )
 
 type AccountGetter interface {
@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"harmony/internal/auth/domain"
+	"harmony/internal/auth/domain/password"
+	"harmony/internal/auth/sessionstore"
+	"harmony/internal/core"
+	"time"
+)
+
+// AccountData contains the stored documents and domain events referring to an
+// account.
+type AccountData struct {
+	// Documents contains the stored documents by document ID.
+	Documents map[string]json.RawMessage `json:"documents"`
+	Events    []json.RawMessage          `json:"events"`
+}
+
+// AccountExport is the archive of all data about an account, which users can
+// download as JSON.
+type AccountExport struct {
+	AccountID  domain.AccountID `json:"account_id"`
+	ExportedAt time.Time        `json:"exported_at"`
+	AccountData
+	Sessions []sessionstore.SessionDoc `json:"sessions"`
+}
+
+type AccountDataRepository interface {
+	FindPWAuthByEmail(ctx context.Context, email string) (domain.PasswordAuthentication, error)
+	Delete(context.Context, domain.Account, core.UseCaseResult[domain.Tombstone]) error
+	ExportData(context.Context, domain.AccountID) (AccountData, error)
+}
+
+// AccountSelfService implements operations users perform on their own account.
+type AccountSelfService struct {
+	Repository AccountDataRepository
+	Sessions   AccountSessions
+	Clock      core.Clock
+}
+
+// DeleteAccount deletes the account, and ends all sessions. The user must
+// confirm the operation with the password.
+//
+// Returns [ErrBadCredentials] if the password is wrong.
+func (s AccountSelfService) DeleteAccount(
+	ctx context.Context,
+	acc domain.AuthenticatedAccount,
+	pw password.Password,
+) error {
+	pwAuth, err := s.Repository.FindPWAuthByEmail(ctx, acc.Email.String())
+	if err != nil {
+		return err
+	}
+	if pwAuth.ID != acc.ID || !pwAuth.Validate(pw) {
+		return ErrBadCredentials
+	}
+	tombstone, event := pwAuth.Account.Delete(s.Clock)
+	res := core.UseCaseOfEntity(tombstone)
+	res.AddEvent(event)
+	if err = s.Repository.Delete(ctx, pwAuth.Account, res); err != nil {
+		return err
+	}
+	return s.Sessions.RevokeAccountSessions(ctx, acc.ID)
+}
+
+// ExportData returns all data stored about the account.
+func (s AccountSelfService) ExportData(
+	ctx context.Context,
+	acc domain.AuthenticatedAccount,
+) (res AccountExport, err error) {
+	res.AccountID = acc.ID
+	res.ExportedAt = core.Now(s.Clock)
+	if res.AccountData, err = s.Repository.ExportData(ctx, acc.ID); err != nil {
+		return
+	}
+	res.Sessions, err = s.Sessions.SessionsForAccount(ctx, acc.ID)
+	return
+}
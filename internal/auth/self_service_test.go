@@ -0,0 +1,85 @@
+package auth_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"harmony/internal/auth"
+	"harmony/internal/auth/domain"
+	"harmony/internal/auth/domain/password"
+	"harmony/internal/core"
+	"harmony/internal/testing/domaintest"
+	"harmony/internal/testing/repotest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type AccountDataRepositoryStub struct {
+	*PWAuthRepositoryStub
+	Tombstones []domain.Tombstone
+}
+
+func (s *AccountDataRepositoryStub) Delete(
+	_ context.Context,
+	acc domain.Account,
+	tombstone core.UseCaseResult[domain.Tombstone],
+) error {
+	delete(s.Entities, acc.ID)
+	s.Tombstones = append(s.Tombstones, tombstone.Entity)
+	s.Events = append(s.Events, tombstone.Events...)
+	return nil
+}
+
+func (s *AccountDataRepositoryStub) ExportData(
+	_ context.Context, id domain.AccountID,
+) (res auth.AccountData, err error) {
+	acc, err := json.Marshal(s.GetTestInstance(id).Account)
+	res.Documents = map[string]json.RawMessage{string(id): acc}
+	return
+}
+
+func initSelfService(
+	t *testing.T,
+) (auth.AccountSelfService, *AccountDataRepositoryStub, *sessionsFake, domain.PasswordAuthentication) {
+	acc := domaintest.InitPasswordAuthAccount(
+		domaintest.WithPassword("s3cret"),
+		domaintest.WithEmailValidation(),
+	)
+	repo := &AccountDataRepositoryStub{PWAuthRepositoryStub: NewPWAuthRepositoryStub(t)}
+	repo.Entities[acc.ID] = &acc
+	sessions := &sessionsFake{}
+	return auth.AccountSelfService{Repository: repo, Sessions: sessions}, repo, sessions, acc
+}
+
+func TestAccountSelfServiceDeleteAccount(t *testing.T) {
+	service, repo, sessions, acc := initSelfService(t)
+	authAcc, err := acc.Authenticated()
+	assert.NoError(t, err)
+
+	err = service.DeleteAccount(t.Context(), authAcc, password.Parse("wrong"))
+	assert.ErrorIs(t, err, auth.ErrBadCredentials)
+	assert.False(t, repo.Empty(), "Account deleted with wrong password")
+
+	assert.NoError(t, service.DeleteAccount(t.Context(), authAcc, password.Parse("s3cret")))
+	assert.True(t, repo.Empty(), "Account deleted")
+	assert.Equal(t, []domain.AccountID{acc.ID}, sessions.revoked, "Sessions revoked")
+	event := repotest.SingleEventOfType[domain.AccountDeleted](repo)
+	assert.Equal(t, domain.AccountDeleted{AccountID: acc.ID}, event)
+	if assert.Len(t, repo.Tombstones, 1) {
+		tombstone := repo.Tombstones[0]
+		assert.Equal(t, domain.HashEmail(acc.Email.String()), tombstone.EmailHash)
+		assert.True(t, tombstone.Active(nil))
+	}
+}
+
+func TestAccountSelfServiceExportData(t *testing.T) {
+	service, _, _, acc := initSelfService(t)
+	authAcc, err := acc.Authenticated()
+	assert.NoError(t, err)
+
+	export, err := service.ExportData(t.Context(), authAcc)
+	assert.NoError(t, err)
+	assert.Equal(t, acc.ID, export.AccountID)
+	assert.Contains(t, export.Documents, string(acc.ID))
+}
@@ -2,32 +2,73 @@ package sessionstore
 
 import (
 	"context"
+	"embed"
 	"errors"
 	"fmt"
+	"harmony/internal/auth/domain"
 	"harmony/internal/core"
 	"harmony/internal/core/corerepo"
+	"harmony/internal/infrastructure/log"
 	"net/http"
+	"time"
 
 	"github.com/gorilla/securecookie"
 	"github.com/gorilla/sessions"
 )
 
+// AccountIDKey is the session value key holding the authenticated
+// [domain.AccountID]. The value is stored in the dedicated account_id field of
+// the session document, making sessions queryable by account.
+const AccountIDKey = "accountId"
+
+// Session values used internally by the store to keep track of the stored
+// document.
+const (
+	valueKeyRev       = "_rev"
+	valueKeyCreatedAt = "_created_at"
+)
+
+const designDocID = "sessions"
+
+//go:embed designdocs
+var designDocFS embed.FS
+
+// designDoc contains the "by_account" view, indexing sessions by account ID.
+var designDoc = corerepo.MustLoadDesignDoc(designDocFS, "designdocs/sessions")
+
+func init() { corerepo.RegisterDesignDoc(designDocID, designDoc) }
+
+// CouchDBStore is a [sessions.Store] keeping session data in CouchDB. The
+// cookie only contains the signed and encrypted session ID. Session values are
+// stored in [SessionDoc] documents.
 type CouchDBStore struct {
-	db       *corerepo.Connection
-	keyPairs [][]byte
-	codecs   []securecookie.Codec
+	// Clock decides when sessions expire. The system clock is used if nil.
+	Clock core.Clock
+
+	db     *corerepo.Connection
+	keys   KeyRing
+	codecs []securecookie.Codec
 }
 
-func NewCouchDBStore(db *corerepo.Connection, keyPairs [][]byte) CouchDBStore {
+// NewCouchDBStore creates a session store using the key ring for encoding
+// cookies. The first key pair is used for encoding, and all key pairs are tried
+// when decoding, allowing keys to be rotated without invalidating existing
+// sessions.
+func NewCouchDBStore(db *corerepo.Connection, keys KeyRing) CouchDBStore {
 	return CouchDBStore{
-		db,
-		keyPairs,
-		securecookie.CodecsFromPairs(keyPairs...),
+		db:     db,
+		keys:   keys,
+		codecs: keys.Codecs(),
 	}
 }
 
 var _ sessions.Store = CouchDBStore{}
 
+// Bootstrap installs the design document with views used to query sessions.
+func (store CouchDBStore) Bootstrap(ctx context.Context) error {
+	return store.db.SetDesignDoc(ctx, designDocID, designDoc)
+}
+
 func (store CouchDBStore) Get(r *http.Request, name string) (s *sessions.Session, err error) {
 	return sessions.GetRegistry(r).Get(store, name)
 }
@@ -60,15 +101,21 @@ func (store CouchDBStore) New(r *http.Request, name string) (session *sessions.S
 	rev, err := store.db.Get(r.Context(), store.docID(id), &doc)
 	if err != nil {
 		if errors.Is(err, corerepo.ErrNotFound) {
-			session.IsNew = true
+			// The session doesn't exist, e.g., it was revoked.
 			return session, nil
 		}
 		return
 	}
-	if err = store.decodeValues(doc.Values, session); err != nil {
-		return
+	if doc.Expired(store.Clock) {
+		// Expired sessions are removed when found, as nothing else cleans them up.
+		if _, err := store.db.Delete(r.Context(), store.docID(id), rev); err != nil {
+			log.LogError(r.Context(), "CouchDBStore.New: delete expired session", err)
+		}
+		return session, nil
 	}
-	session.Values["_rev"] = rev
+	doc.copyToSession(session)
+	session.Values[valueKeyRev] = rev
+	session.Values[valueKeyCreatedAt] = doc.CreatedAt
 	session.ID = id
 	session.IsNew = false
 	return session, err
@@ -87,30 +134,33 @@ func (store CouchDBStore) Save(
 	r *http.Request,
 	w http.ResponseWriter,
 	session *sessions.Session,
-) (e error) {
-	v, err := store.encodeValues(session)
-	if err != nil {
-		return fmt.Errorf("CouchDBStore.Save: encodeValues: %w", err)
-	}
+) (err error) {
 	// Set delete if max-age is < 0
 	if session.Options.MaxAge <= 0 {
-		// TODO: Delete in DB
+		if err = store.delete(r.Context(), session); err != nil {
+			return fmt.Errorf("CouchDBStore.Save: %w", err)
+		}
 		http.SetCookie(w, sessions.NewCookie(session.Name(), "", session.Options))
 		return nil
 	}
 
-	doc := SessionDoc{
-		ID:     session.ID,
-		Values: v,
+	doc, err := newSessionDoc(r, session, store.Clock)
+	if err != nil {
+		return fmt.Errorf("CouchDBStore.Save: %w", err)
 	}
 
-	if session.ID == "" {
+	if _, hasRev := session.Values[valueKeyRev]; session.ID == "" || !hasRev {
+		// A session without a revision was either never stored, or had its
+		// values cleared, e.g., on login. Always generating a new ID in this
+		// case prevents session fixation. The document of the previous ID is
+		// deleted, so the old ID cannot be used.
+		if err = store.delete(r.Context(), session); err != nil {
+			return fmt.Errorf("CouchDBStore.Save: %w", err)
+		}
 		session.ID = core.NewID()
+		doc.ID = session.ID
 		err = store.insert(r.Context(), session, doc)
 	} else {
-		if session.ID == "" {
-			return fmt.Errorf("CouchDBStore.Save: session has no ID")
-		}
 		err = store.update(r.Context(), session, doc)
 	}
 	if err != nil {
@@ -128,12 +178,11 @@ func (store CouchDBStore) Save(
 func (store CouchDBStore) update(
 	ctx context.Context, s *sessions.Session, doc SessionDoc,
 ) (err error) {
-	rev, ok := s.Values["_rev"].(string)
+	rev, ok := s.Values[valueKeyRev].(string)
 	if !ok || rev == "" {
 		return fmt.Errorf("CouchDBStore.update: session has no _rev value")
 	}
-	delete(s.Values, "_rev")
-	s.Values["_rev"], err = store.db.Update(ctx, store.docID(s.ID), rev, doc)
+	s.Values[valueKeyRev], err = store.db.Update(ctx, store.docID(s.ID), rev, doc)
 	if err != nil {
 		return fmt.Errorf("CouchDBStore.update: %w", err)
 	}
@@ -145,10 +194,66 @@ func (store CouchDBStore) insert(ctx context.Context, s *sessions.Session, doc S
 	if err != nil {
 		return fmt.Errorf("CouchDBStore.insert: %w", err)
 	}
-	s.Values["_rev"] = rev
+	s.Values[valueKeyRev] = rev
+	s.Values[valueKeyCreatedAt] = doc.CreatedAt
 	return nil
 }
 
+// delete removes the session document from the database, if it was stored.
+// The document is deleted by the session ID, as the session values, including
+// the revision, may have been cleared, e.g., on logout.
+func (store CouchDBStore) delete(ctx context.Context, s *sessions.Session) error {
+	if s.ID == "" {
+		return nil
+	}
+	return store.db.DeleteIfExists(ctx, store.docID(s.ID))
+}
+
+// SessionsForAccount returns all stored sessions for the account, including
+// expired sessions that haven't been cleaned up.
+func (store CouchDBStore) SessionsForAccount(
+	ctx context.Context,
+	id domain.AccountID,
+) ([]SessionDoc, error) {
+	res, err := corerepo.QueryViewDocs[SessionDoc](
+		ctx, *store.db, designDocID, "by_account", corerepo.KeyQuery(id),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("CouchDBStore.SessionsForAccount: %w", err)
+	}
+	return res.Docs(), nil
+}
+
+// maxRevokeAttempts is the number of times RevokeAccountSessions tries to
+// delete sessions that are updated concurrently.
+const maxRevokeAttempts = 3
+
+// RevokeAccountSessions deletes all sessions for the account, effectively
+// logging out the user on all devices. Sessions are deleted by ID, as a session
+// may be updated after it was read, e.g., by a request of the user; sessions
+// that fail to be deleted are tried again.
+func (store CouchDBStore) RevokeAccountSessions(
+	ctx context.Context,
+	id domain.AccountID,
+) (err error) {
+	for range maxRevokeAttempts {
+		var docs []SessionDoc
+		if docs, err = store.SessionsForAccount(ctx, id); err != nil {
+			return err
+		}
+		var errs []error
+		for _, doc := range docs {
+			if err := store.db.DeleteIfExists(ctx, store.docID(doc.ID)); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if err = errors.Join(errs...); err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("CouchDBStore.RevokeAccountSessions: %w", err)
+}
+
 func (store CouchDBStore) decodeIDCookie(name, c string) (res string, err error) {
 	err = securecookie.DecodeMulti(name, c, &res, store.codecs...)
 	return
@@ -158,15 +263,79 @@ func (store CouchDBStore) encodeIDCookie(s *sessions.Session) (string, error) {
 	return securecookie.EncodeMulti(s.Name(), s.ID, store.codecs...)
 }
 
-func (store CouchDBStore) encodeValues(s *sessions.Session) (string, error) {
-	return securecookie.EncodeMulti(s.Name(), s.Values, store.codecs...)
+// SessionMetadata contains information about the client that created the
+// session, helping users and administrators recognise sessions.
+type SessionMetadata struct {
+	UserAgent  string `json:"user_agent,omitempty"`
+	RemoteAddr string `json:"remote_addr,omitempty"`
+}
+
+// SessionDoc is the document stored for a session.
+//
+// Only the account ID is stored as a typed field. Other session values must be
+// strings, and are stored in Values.
+type SessionDoc struct {
+	ID        string            `json:"session_id"`
+	AccountID domain.AccountID  `json:"account_id,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+	ExpiresAt time.Time         `json:"expires_at"`
+	Metadata  SessionMetadata   `json:"metadata"`
+	Values    map[string]string `json:"values,omitempty"`
 }
 
-func (store CouchDBStore) decodeValues(v string, s *sessions.Session) error {
-	return securecookie.DecodeMulti(s.Name(), v, &s.Values, store.codecs...)
+// Expired returns whether the session has expired at the time of the clock.
+// Documents created without an expiry time never expire.
+func (d SessionDoc) Expired(clock core.Clock) bool {
+	return !d.ExpiresAt.IsZero() && core.Now(clock).After(d.ExpiresAt)
 }
 
-type SessionDoc struct {
-	ID     string
-	Values string
+func newSessionDoc(r *http.Request, s *sessions.Session, clock core.Clock) (SessionDoc, error) {
+	now := core.Now(clock).UTC()
+	createdAt, ok := s.Values[valueKeyCreatedAt].(time.Time)
+	if !ok {
+		createdAt = now
+	}
+	doc := SessionDoc{
+		ID:        s.ID,
+		CreatedAt: createdAt,
+		UpdatedAt: now,
+		ExpiresAt: now.Add(time.Duration(s.Options.MaxAge) * time.Second),
+		Metadata: SessionMetadata{
+			UserAgent:  r.UserAgent(),
+			RemoteAddr: r.RemoteAddr,
+		},
+	}
+	for k, v := range s.Values {
+		switch k {
+		case valueKeyRev, valueKeyCreatedAt:
+			continue
+		case AccountIDKey:
+			id, ok := v.(domain.AccountID)
+			if !ok {
+				return doc, fmt.Errorf("session value %s: expected AccountID, got %T", k, v)
+			}
+			doc.AccountID = id
+		default:
+			key, keyOk := k.(string)
+			val, valOk := v.(string)
+			if !keyOk || !valOk {
+				return doc, fmt.Errorf("unsupported session value: %v (%T)", k, v)
+			}
+			if doc.Values == nil {
+				doc.Values = make(map[string]string)
+			}
+			doc.Values[key] = val
+		}
+	}
+	return doc, nil
+}
+
+func (d SessionDoc) copyToSession(s *sessions.Session) {
+	if d.AccountID != "" {
+		s.Values[AccountIDKey] = d.AccountID
+	}
+	for k, v := range d.Values {
+		s.Values[k] = v
+	}
 }
@@ -0,0 +1,183 @@
+package sessionstore_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"harmony/internal/auth/domain"
+	"harmony/internal/auth/sessionstore"
+	"harmony/internal/core/corerepo"
+	"harmony/internal/testing/clocktest"
+
+	"github.com/gorilla/sessions"
+	"github.com/stretchr/testify/assert"
+)
+
+func initStore(t testing.TB) sessionstore.CouchDBStore {
+	corerepo.AssertInitialized()
+	store := sessionstore.NewCouchDBStore(
+		&corerepo.DefaultConnection,
+		sessionstore.KeyRing{sessionstore.GenerateKeyPair()},
+	)
+	assert.NoError(t, store.Bootstrap(t.Context()))
+	return store
+}
+
+// saveSession creates a new session for the account, and returns a request
+// carrying the session cookie
+func saveSession(t testing.TB, store sessions.Store, id domain.AccountID) *http.Request {
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("User-Agent", "test-agent")
+	w := httptest.NewRecorder()
+	s, err := store.Get(r, "auth")
+	assert.NoError(t, err)
+	s.Values[sessionstore.AccountIDKey] = id
+	assert.NoError(t, s.Save(r, w))
+
+	res := httptest.NewRequest("GET", "/", nil)
+	for _, c := range w.Result().Cookies() {
+		res.AddCookie(c)
+	}
+	return res
+}
+
+func TestCouchDBStoreRoundtrip(t *testing.T) {
+	store := initStore(t)
+	accountID := domain.AccountID(domain.NewID())
+	r := saveSession(t, store, accountID)
+
+	s, err := store.New(r, "auth")
+	assert.NoError(t, err)
+	assert.False(t, s.IsNew)
+	assert.Equal(t, accountID, s.Values[sessionstore.AccountIDKey])
+
+	docs, err := store.SessionsForAccount(t.Context(), accountID)
+	assert.NoError(t, err)
+	if assert.Len(t, docs, 1) {
+		assert.Equal(t, s.ID, docs[0].ID)
+		assert.Equal(t, accountID, docs[0].AccountID)
+		assert.Equal(t, "test-agent", docs[0].Metadata.UserAgent)
+		assert.False(t, docs[0].Expired(nil))
+	}
+}
+
+func TestCouchDBStoreRevokeAccountSessions(t *testing.T) {
+	store := initStore(t)
+	accountID := domain.AccountID(domain.NewID())
+	r1 := saveSession(t, store, accountID)
+	r2 := saveSession(t, store, accountID)
+
+	assert.NoError(t, store.RevokeAccountSessions(t.Context(), accountID))
+
+	for _, r := range []*http.Request{r1, r2} {
+		s, err := store.New(r, "auth")
+		assert.NoError(t, err)
+		assert.NotContains(t, s.Values, sessionstore.AccountIDKey, "Session was revoked")
+	}
+	docs, err := store.SessionsForAccount(t.Context(), accountID)
+	assert.NoError(t, err)
+	assert.Empty(t, docs)
+}
+
+func TestCouchDBStoreRevokeAccountSessionsWhileUpdated(t *testing.T) {
+	store := initStore(t)
+	accountID := domain.AccountID(domain.NewID())
+	revoked := make(chan struct{})
+	var wg sync.WaitGroup
+	for range 3 {
+		r := saveSession(t, store, accountID)
+		updated := make(chan struct{})
+		notify := sync.OnceFunc(func() { close(updated) })
+		wg.Go(func() {
+			defer notify()
+			// Updates the session, like requests of the user, until revoked
+			for i := 0; ; i++ {
+				s, err := store.New(r, "auth")
+				if err != nil || s.ID == "" {
+					return
+				}
+				s.Values["counter"] = strconv.Itoa(i)
+				if err := s.Save(r, httptest.NewRecorder()); err != nil {
+					return
+				}
+				notify()
+				select {
+				case <-revoked:
+					return
+				case <-time.After(time.Millisecond):
+				}
+			}
+		})
+		<-updated
+	}
+
+	err := store.RevokeAccountSessions(t.Context(), accountID)
+	close(revoked)
+	wg.Wait()
+	assert.NoError(t, err)
+	docs, err := store.SessionsForAccount(t.Context(), accountID)
+	assert.NoError(t, err)
+	assert.Empty(t, docs)
+}
+
+func TestCouchDBStoreSessionExpiry(t *testing.T) {
+	clock := clocktest.New()
+	store := initStore(t)
+	store.Clock = clock
+	accountID := domain.AccountID(domain.NewID())
+	r := saveSession(t, store, accountID)
+
+	clock.Advance(30*24*time.Hour - time.Second)
+	s, err := store.New(r, "auth")
+	assert.NoError(t, err)
+	assert.Equal(t, accountID, s.Values[sessionstore.AccountIDKey], "Session before expiry")
+
+	clock.Advance(time.Second + time.Nanosecond)
+	s, err = store.New(r, "auth")
+	assert.NoError(t, err)
+	assert.Empty(t, s.ID, "Expired session is replaced")
+	assert.NotContains(t, s.Values, sessionstore.AccountIDKey)
+}
+
+// sessionDocExists returns whether the document of the session is stored.
+func sessionDocExists(t testing.TB, id string) bool {
+	t.Helper()
+	_, err := corerepo.DefaultConnection.Get(
+		t.Context(), "auth:sessions:"+id, new(sessionstore.SessionDoc),
+	)
+	if errors.Is(err, corerepo.ErrNotFound) {
+		return false
+	}
+	assert.NoError(t, err)
+	return true
+}
+
+func TestCouchDBStoreClearedSessionIsDeleted(t *testing.T) {
+	store := initStore(t)
+	r := saveSession(t, store, domain.AccountID(domain.NewID()))
+	s, err := store.New(r, "auth")
+	assert.NoError(t, err)
+	oldID := s.ID
+
+	t.Run("New ID after clearing values", func(t *testing.T) {
+		clear(s.Values)
+		s.Values[sessionstore.AccountIDKey] = domain.AccountID(domain.NewID())
+		assert.NoError(t, s.Save(r, httptest.NewRecorder()))
+		assert.NotEqual(t, oldID, s.ID)
+		assert.False(t, sessionDocExists(t, oldID), "Document of old ID deleted")
+		assert.True(t, sessionDocExists(t, s.ID), "Document of new ID stored")
+	})
+
+	t.Run("Expired after clearing values", func(t *testing.T) {
+		id := s.ID
+		clear(s.Values)
+		s.Options.MaxAge = -1
+		assert.NoError(t, s.Save(r, httptest.NewRecorder()))
+		assert.False(t, sessionDocExists(t, id), "Document deleted")
+	})
+}
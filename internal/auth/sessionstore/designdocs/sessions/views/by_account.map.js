@@ -0,0 +1,5 @@
+function(doc) {
+	if (doc._id.startsWith("auth:sessions:") && doc.account_id) {
+		emit(doc.account_id, null)
+	}
+}
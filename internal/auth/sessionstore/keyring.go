@@ -0,0 +1,140 @@
+package sessionstore
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gorilla/securecookie"
+	gonanoid "github.com/matoous/go-nanoid/v2"
+)
+
+// ErrNoKeys is returned when parsing an empty key ring.
+var ErrNoKeys = errors.New("sessionstore: no session keys")
+
+// ErrKeyMismatch is returned when the number of authentication keys doesn't
+// match the number of encryption keys.
+var ErrKeyMismatch = errors.New("sessionstore: authentication and encryption keys don't match")
+
+// ErrBadEncKey is returned when an encryption key doesn't have a length
+// supported by AES.
+var ErrBadEncKey = errors.New("sessionstore: encryption key must be 16, 24, or 32 bytes")
+
+// keyAlphabet is used for generating keys. Generated keys are plain text, so
+// they can be used directly as environment variable values.
+const keyAlphabet = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ-_"
+
+const (
+	authKeyLength = 64
+	encKeyLength  = 32
+)
+
+// KeyPair is a pair of keys used for signing and encrypting cookie values.
+type KeyPair struct {
+	AuthKey []byte
+	EncKey  []byte
+}
+
+// GenerateKeyPair creates a new random KeyPair with a 64 byte authentication
+// key, and a 32 byte encryption key, selecting AES-256.
+func GenerateKeyPair() KeyPair {
+	return KeyPair{
+		AuthKey: []byte(gonanoid.MustGenerate(keyAlphabet, authKeyLength)),
+		EncKey:  []byte(gonanoid.MustGenerate(keyAlphabet, encKeyLength)),
+	}
+}
+
+func (p KeyPair) validate() error {
+	if len(p.AuthKey) == 0 {
+		return errors.New("sessionstore: empty authentication key")
+	}
+	switch len(p.EncKey) {
+	case 16, 24, 32:
+		return nil
+	default:
+		return fmt.Errorf("%w: got %d bytes", ErrBadEncKey, len(p.EncKey))
+	}
+}
+
+// KeyRing is an ordered list of key pairs. The first pair is used to encode new
+// values, while all pairs are tried when decoding. This allows rotating keys;
+// add a new pair in front, and remove the old pair when no cookies encoded with
+// it are in circulation anymore.
+type KeyRing []KeyPair
+
+// ParseKeyRing creates a KeyRing from comma separated lists of authentication
+// and encryption keys, e.g., the values of the SESSION_AUTH_KEY and
+// SESSION_ENC_KEY environment variables. The n'th authentication key is paired
+// with the n'th encryption key, and the newest keys go first.
+func ParseKeyRing(authKeys, encKeys string) (KeyRing, error) {
+	if authKeys == "" && encKeys == "" {
+		return nil, ErrNoKeys
+	}
+	auths := strings.Split(authKeys, ",")
+	encs := strings.Split(encKeys, ",")
+	if len(auths) != len(encs) {
+		return nil, fmt.Errorf(
+			"%w: %d authentication keys, %d encryption keys",
+			ErrKeyMismatch, len(auths), len(encs),
+		)
+	}
+	res := make(KeyRing, len(auths))
+	for i := range auths {
+		res[i] = KeyPair{
+			AuthKey: []byte(strings.TrimSpace(auths[i])),
+			EncKey:  []byte(strings.TrimSpace(encs[i])),
+		}
+	}
+	return res, res.Validate()
+}
+
+// Validate returns an error if the key ring is empty, or contains invalid keys.
+func (r KeyRing) Validate() error {
+	if len(r) == 0 {
+		return ErrNoKeys
+	}
+	for i, p := range r {
+		if err := p.validate(); err != nil {
+			return fmt.Errorf("key pair %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// Rotate returns a new KeyRing with p as the current key pair, keeping the
+// existing pairs for decoding.
+func (r KeyRing) Rotate(p KeyPair) KeyRing {
+	return append(KeyRing{p}, r...)
+}
+
+// Codecs return a securecookie codec for each key pair, in the same order.
+func (r KeyRing) Codecs() []securecookie.Codec {
+	return securecookie.CodecsFromPairs(r.pairs()...)
+}
+
+// Env returns the key ring formatted as values for the SESSION_AUTH_KEY and
+// SESSION_ENC_KEY environment variables; the inverse of [ParseKeyRing].
+func (r KeyRing) Env() (authKeys, encKeys string) {
+	auths := make([]string, len(r))
+	encs := make([]string, len(r))
+	for i, p := range r {
+		auths[i] = string(p.AuthKey)
+		encs[i] = string(p.EncKey)
+	}
+	return strings.Join(auths, ","), strings.Join(encs, ",")
+}
+
+func (r KeyRing) pairs() [][]byte {
+	res := make([][]byte, 0, 2*len(r))
+	for _, p := range r {
+		res = append(res, p.AuthKey, p.EncKey)
+	}
+	return res
+}
+
+// DevelopmentKeys is a hardcoded key ring used when no keys are configured. It
+// must not be used in production.
+var DevelopmentKeys = KeyRing{{
+	AuthKey: []byte("authkey1234"),
+	EncKey:  []byte("enckey12341234567890123456789012"),
+}}
@@ -0,0 +1,55 @@
+package sessionstore_test
+
+import (
+	"testing"
+
+	"harmony/internal/auth/sessionstore"
+
+	"github.com/gorilla/securecookie"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseKeyRing(t *testing.T) {
+	t.Run("Empty values", func(t *testing.T) {
+		_, err := sessionstore.ParseKeyRing("", "")
+		assert.ErrorIs(t, err, sessionstore.ErrNoKeys)
+	})
+
+	t.Run("Mismatched number of keys", func(t *testing.T) {
+		_, err := sessionstore.ParseKeyRing("auth1,auth2", "enc4567890123456")
+		assert.ErrorIs(t, err, sessionstore.ErrKeyMismatch)
+	})
+
+	t.Run("Invalid encryption key length", func(t *testing.T) {
+		_, err := sessionstore.ParseKeyRing("auth1", "too-short")
+		assert.ErrorIs(t, err, sessionstore.ErrBadEncKey)
+	})
+
+	t.Run("Roundtrip generated keys", func(t *testing.T) {
+		ring := sessionstore.KeyRing{
+			sessionstore.GenerateKeyPair(),
+			sessionstore.GenerateKeyPair(),
+		}
+		got, err := sessionstore.ParseKeyRing(ring.Env())
+		assert.NoError(t, err)
+		assert.Equal(t, ring, got)
+	})
+}
+
+func TestKeyRotation(t *testing.T) {
+	oldRing := sessionstore.KeyRing{sessionstore.GenerateKeyPair()}
+	newRing := oldRing.Rotate(sessionstore.GenerateKeyPair())
+
+	encoded, err := securecookie.EncodeMulti("auth", "session-id", oldRing.Codecs()...)
+	assert.NoError(t, err)
+
+	var decoded string
+	err = securecookie.DecodeMulti("auth", encoded, &decoded, newRing.Codecs()...)
+	assert.NoError(t, err, "Values encoded with an old key can be decoded after rotation")
+	assert.Equal(t, "session-id", decoded)
+
+	encoded, err = securecookie.EncodeMulti("auth", "session-id", newRing.Codecs()...)
+	assert.NoError(t, err)
+	err = securecookie.DecodeMulti("auth", encoded, &decoded, oldRing.Codecs()...)
+	assert.Error(t, err, "Values are encoded with the newest key")
+}
@@ -0,0 +1,23 @@
+package core
+
+import "time"
+
+// Clock provides the current time. Code depending on time, e.g., expiry,
+// takes a Clock, so tests can control time; see the clocktest package.
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is the [Clock] of the system, returning the current time in UTC.
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time { return time.Now().UTC() }
+
+// Now returns the current time of the clock, or of the [SystemClock] if the
+// clock is nil, letting Clock fields be optional.
+func Now(c Clock) time.Time {
+	if c == nil {
+		return SystemClock{}.Now()
+	}
+	return c.Now()
+}
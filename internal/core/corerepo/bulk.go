@@ -0,0 +1,82 @@
+package corerepo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// BulkResult is the outcome of writing a single document with
+// [Connection.BulkDocs].
+type BulkResult struct {
+	ID     string `json:"id"`
+	Rev    string `json:"rev"`
+	Error  string `json:"error,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// Err returns nil if the document was written. If the document was modified by
+// another process, an [ErrConflict] is returned.
+func (r BulkResult) Err() error {
+	switch r.Error {
+	case "":
+		return nil
+	case "conflict":
+		return fmt.Errorf("%w: %s", ErrConflict, r.ID)
+	default:
+		return fmt.Errorf("couchdb: write %s: %s: %s", r.ID, r.Error, r.Reason)
+	}
+}
+
+// BulkErr returns the errors of all documents that failed, or nil if all
+// documents were written.
+func BulkErr(results []BulkResult) error {
+	var errs []error
+	for _, r := range results {
+		errs = append(errs, r.Err())
+	}
+	return errors.Join(errs...)
+}
+
+// BulkDocs writes multiple documents in a single request. Each document must
+// have an "_id" field, and a "_rev" field to update an existing document, e.g.,
+// a [DocumentWithEvents].
+//
+// The documents are not written in a transaction; some documents may be
+// written while others fail. The results are in the same order as docs, and a
+// nil error only indicates that CouchDB processed the request. Use [BulkErr] to
+// check if all documents were written.
+func (c Connection) BulkDocs(ctx context.Context, docs ...any) ([]BulkResult, error) {
+	body := struct {
+		Docs []any `json:"docs"`
+	}{docs}
+	var res []BulkResult
+	if err := c.post(ctx, "_bulk_docs", body, &res); err != nil {
+		return nil, fmt.Errorf("couchdb: BulkDocs: %w", err)
+	}
+	return res, nil
+}
+
+// post sends body as JSON to the path relative to the database, and decodes the
+// response into res.
+func (c Connection) post(ctx context.Context, path string, body any, res any) error {
+	resp, err := c.RawPost(ctx, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrConn, err)
+	}
+	switch resp.StatusCode {
+	case 200, 201:
+		return json.Unmarshal(respBody, res)
+	default:
+		return fmt.Errorf(
+			"%s: %w: %s", path, errUnexpectedStatusCode(resp), string(respBody),
+		)
+	}
+}
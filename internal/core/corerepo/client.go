@@ -0,0 +1,236 @@
+package corerepo
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"harmony/internal/core"
+)
+
+// Default values for connections created by [NewCouchConnection].
+const (
+	DefaultTimeout             = 30 * time.Second
+	DefaultReadRetries         = 2
+	DefaultRetryBackoff        = 100 * time.Millisecond
+	DefaultMaxIdleConnsPerHost = 16
+)
+
+// connConfig contains the configuration of a [Connection].
+type connConfig struct {
+	client              *http.Client
+	timeout             time.Duration
+	readRetries         int
+	retryBackoff        time.Duration
+	maxIdleConnsPerHost int
+	maxConnsPerHost     int
+	rootCAs             *x509.CertPool
+	clock               core.Clock
+}
+
+// ConnOption configures a connection created by [NewCouchConnection].
+type ConnOption func(*connConfig)
+
+// ConnOptHTTPClient makes the connection use the client. Pool and TLS options
+// are ignored when a client is specified.
+func ConnOptHTTPClient(client *http.Client) ConnOption {
+	return func(c *connConfig) { c.client = client }
+}
+
+// ConnOptTimeout sets the timeout of a single request to CouchDB. Zero means
+// no timeout. The changes feed is not affected.
+func ConnOptTimeout(timeout time.Duration) ConnOption {
+	return func(c *connConfig) { c.timeout = timeout }
+}
+
+// ConnOptReadRetries sets how many times a read is retried when CouchDB cannot
+// be reached, i.e., the request fails with an [ErrConn]. The wait between
+// attempts increases linearly by backoff. Writes are never retried, as the
+// write may have succeeded.
+func ConnOptReadRetries(retries int, backoff time.Duration) ConnOption {
+	return func(c *connConfig) {
+		c.readRetries = retries
+		c.retryBackoff = backoff
+	}
+}
+
+// ConnOptPool sets the size of the connection pool; the number of idle
+// connections kept open, and the maximum number of connections. Zero means no
+// limit on the number of connections.
+func ConnOptPool(maxIdleConns, maxConns int) ConnOption {
+	return func(c *connConfig) {
+		c.maxIdleConnsPerHost = maxIdleConns
+		c.maxConnsPerHost = maxConns
+	}
+}
+
+// ConnOptRootCAs sets the certificate authorities used to verify the
+// certificate of a CouchDB server using TLS, e.g., a self-signed certificate.
+func ConnOptRootCAs(pool *x509.CertPool) ConnOption {
+	return func(c *connConfig) { c.rootCAs = pool }
+}
+
+// ConnOptClock sets the clock deciding the time recorded by the connection,
+// e.g., when migrations are applied. The system clock is used by default.
+func ConnOptClock(clock core.Clock) ConnOption {
+	return func(c *connConfig) { c.clock = clock }
+}
+
+// LoadCAFile reads PEM encoded certificates to use with [ConnOptRootCAs].
+func LoadCAFile(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("couchdb: LoadCAFile: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("couchdb: LoadCAFile: no certificates in %s", path)
+	}
+	return pool, nil
+}
+
+// ConnOptionsFromEnv returns connection options configured by environment
+// variables:
+//
+//   - COUCHDB_TIMEOUT: Request timeout, e.g., "10s"
+//   - COUCHDB_READ_RETRIES: Number of retries for reads
+//   - COUCHDB_MAX_CONNS: Maximum number of connections
+//   - COUCHDB_CA_FILE: PEM file with the CA certificates of the server
+func ConnOptionsFromEnv() ([]ConnOption, error) {
+	var (
+		opts []ConnOption
+		errs []error
+	)
+	if v := os.Getenv("COUCHDB_TIMEOUT"); v != "" {
+		d, err := time.ParseDuration(v)
+		errs = append(errs, err)
+		opts = append(opts, ConnOptTimeout(d))
+	}
+	if v := os.Getenv("COUCHDB_READ_RETRIES"); v != "" {
+		n, err := strconv.Atoi(v)
+		errs = append(errs, err)
+		opts = append(opts, ConnOptReadRetries(n, DefaultRetryBackoff))
+	}
+	if v := os.Getenv("COUCHDB_MAX_CONNS"); v != "" {
+		n, err := strconv.Atoi(v)
+		errs = append(errs, err)
+		opts = append(opts, ConnOptPool(min(n, DefaultMaxIdleConnsPerHost), n))
+	}
+	if v := os.Getenv("COUCHDB_CA_FILE"); v != "" {
+		pool, err := LoadCAFile(v)
+		errs = append(errs, err)
+		opts = append(opts, ConnOptRootCAs(pool))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, fmt.Errorf("couchdb: invalid configuration: %w", err)
+	}
+	return opts, nil
+}
+
+func newConnConfig(opts []ConnOption) connConfig {
+	cfg := connConfig{
+		timeout:             DefaultTimeout,
+		readRetries:         DefaultReadRetries,
+		retryBackoff:        DefaultRetryBackoff,
+		maxIdleConnsPerHost: DefaultMaxIdleConnsPerHost,
+	}
+	for _, o := range opts {
+		o(&cfg)
+	}
+	if cfg.client == nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.MaxIdleConnsPerHost = cfg.maxIdleConnsPerHost
+		transport.MaxConnsPerHost = cfg.maxConnsPerHost
+		if cfg.rootCAs != nil {
+			transport.TLSClientConfig = &tls.Config{RootCAs: cfg.rootCAs}
+		}
+		// No client timeout, as it would also apply to the changes feed.
+		cfg.client = &http.Client{Transport: transport}
+	}
+	return cfg
+}
+
+// idempotent returns whether a request with the method can safely be retried.
+func idempotent(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead
+}
+
+// req wraps http NewRequest and Client.Do method, but converts the error to an ErrConn. This
+// makes the caller able to distinguish between:
+//   - The couchdb server responded with an unexpected status code.
+//   - The call failed, and no response could be retrieved from couch DB
+//
+// Reads that fail with an ErrConn are retried. Requests are instrumented with
+// metrics and trace spans.
+func (c Connection) req(
+	ctx context.Context,
+	method, url string,
+	headers http.Header,
+	body io.Reader,
+) (*http.Response, error) {
+	return c.instrument(ctx, method, url, headers, func(ctx context.Context) (*http.Response, error) {
+		retries := 0
+		if idempotent(method) {
+			retries = c.cfg.readRetries
+		}
+		for attempt := 0; ; attempt++ {
+			resp, err := c.reqOnce(ctx, method, url, headers, body)
+			if err == nil || errors.Is(err, ErrRequest) || attempt >= retries || ctx.Err() != nil {
+				return resp, err
+			}
+			select {
+			case <-time.After(c.cfg.retryBackoff * time.Duration(attempt+1)):
+			case <-ctx.Done():
+				return resp, err
+			}
+		}
+	})
+}
+
+// reqOnce sends a single request, applying the request timeout. The timeout
+// covers reading the response body, so it is cancelled when the body is
+// closed.
+func (c Connection) reqOnce(
+	ctx context.Context,
+	method, url string,
+	headers http.Header,
+	body io.Reader,
+) (*http.Response, error) {
+	cancel := context.CancelFunc(func() {})
+	if c.cfg.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, c.cfg.timeout)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("%w: %w: %v", ErrConn, ErrRequest, err)
+	}
+	if headers != nil {
+		maps.Copy(req.Header, headers)
+	}
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("%w: %v", ErrConn, err)
+	}
+	resp.Body = cancelOnClose{resp.Body, cancel}
+	return resp, nil
+}
+
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b cancelOnClose) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
@@ -0,0 +1,110 @@
+package corerepo_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"harmony/internal/core/corerepo"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeCouch is a minimal CouchDB server, that can bootstrap a database. GET
+// requests for "/db/doc" return a document after delay. All other documents
+// don't exist, and all writes succeed.
+func fakeCouch(t *testing.T, delay time.Duration) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"1-a"`)
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/db/doc":
+			select {
+			case <-time.After(delay):
+			case <-r.Context().Done():
+				return
+			}
+			w.Write([]byte(`{"_id":"doc","_rev":"1-a","Foo":"Bar"}`))
+		case r.Method == "GET":
+			w.WriteHeader(404)
+		default:
+			w.WriteHeader(201)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// flakyTransport fails the first requests with the method, like a dropped
+// connection would.
+type flakyTransport struct {
+	method   string
+	failures atomic.Int32
+	attempts atomic.Int32
+}
+
+func (t *flakyTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.Method == t.method {
+		t.attempts.Add(1)
+		if t.failures.Add(-1) >= 0 {
+			return nil, errors.New("connection reset")
+		}
+	}
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func TestConnectionRetriesReads(t *testing.T) {
+	server := fakeCouch(t, 0)
+	transport := &flakyTransport{method: "GET"}
+	conn, err := corerepo.NewCouchConnection(server.URL+"/db",
+		corerepo.ConnOptHTTPClient(&http.Client{Transport: transport}),
+		corerepo.ConnOptReadRetries(2, time.Millisecond),
+	)
+	assert.NoError(t, err)
+
+	transport.failures.Store(2)
+	transport.attempts.Store(0)
+	var doc Doc
+	_, err = conn.Get(t.Context(), "doc", &doc)
+	assert.NoError(t, err)
+	assert.Equal(t, "Bar", doc.Foo)
+	assert.Equal(t, int32(3), transport.attempts.Load())
+
+	transport.failures.Store(3)
+	transport.attempts.Store(0)
+	_, err = conn.Get(t.Context(), "doc", &doc)
+	assert.ErrorIs(t, err, corerepo.ErrConn, "Error when retries are exhausted")
+	assert.Equal(t, int32(3), transport.attempts.Load())
+}
+
+func TestConnectionDoesNotRetryWrites(t *testing.T) {
+	server := fakeCouch(t, 0)
+	transport := &flakyTransport{method: "PUT"}
+	conn, err := corerepo.NewCouchConnection(server.URL+"/db",
+		corerepo.ConnOptHTTPClient(&http.Client{Transport: transport}),
+		corerepo.ConnOptReadRetries(2, time.Millisecond),
+	)
+	assert.NoError(t, err)
+
+	transport.failures.Store(1)
+	transport.attempts.Store(0)
+	_, err = conn.Insert(t.Context(), "new-doc", Doc{Foo: "Bar"})
+	assert.ErrorIs(t, err, corerepo.ErrConn)
+	assert.Equal(t, int32(1), transport.attempts.Load())
+}
+
+func TestConnectionTimeout(t *testing.T) {
+	server := fakeCouch(t, time.Second)
+	conn, err := corerepo.NewCouchConnection(server.URL+"/db",
+		corerepo.ConnOptTimeout(20*time.Millisecond),
+		corerepo.ConnOptReadRetries(0, 0),
+	)
+	assert.NoError(t, err)
+
+	start := time.Now()
+	_, err = conn.Get(t.Context(), "doc", &Doc{})
+	assert.ErrorIs(t, err, corerepo.ErrConn)
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+}
@@ -1,6 +1,7 @@
 package corerepo
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"harmony/internal/core"
@@ -35,23 +36,26 @@ func errUnexpectedStatusCode(resp *http.Response) error {
 }
 
 type ViewRow[T any] struct {
-	ID    string `json:"id"`
-	Key   string `json:"key"`
-	Rev   string `json:"rev"`
-	Value T      `json:"value"`
+	ID    string          `json:"id"`
+	Key   json.RawMessage `json:"key"`
+	Rev   string          `json:"rev"`
+	Value T               `json:"value"`
 }
 
 type DocViewRow[T any] struct {
-	ID  string `json:"id"`
-	Key string `json:"key"`
-	Rev string `json:"rev"`
-	Doc T      `json:"doc"`
+	ID  string          `json:"id"`
+	Key json.RawMessage `json:"key"`
+	Rev string          `json:"rev"`
+	Doc T               `json:"doc"`
 }
 
 type DocsViewResult[T any] struct {
 	Offset    int             `json:"offset"`
 	Rows      []DocViewRow[T] `json:"rows"`
 	TotalRows int             `json:"total_rows"`
+	// Next is the query for the next page of a paginated query, or nil if
+	// there are no more rows.
+	Next *ViewQuery `json:"-"`
 }
 
 // Values return a []T containing the Value fields of each row.
@@ -67,6 +71,9 @@ type ViewResult[T any] struct {
 	Offset    int          `json:"offset"`
 	Rows      []ViewRow[T] `json:"rows"`
 	TotalRows int          `json:"total_rows"`
+	// Next is the query for the next page of a paginated query, or nil if
+	// there are no more rows.
+	Next *ViewQuery `json:"-"`
 }
 
 // Values return a []T containing the Value fields of each row.
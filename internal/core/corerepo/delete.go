@@ -0,0 +1,171 @@
+package corerepo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// maxDeleteAttempts is the number of times DeleteIfExists tries to delete a
+// document that is modified concurrently.
+const maxDeleteAttempts = 3
+
+// deletedDocument is the body of a document deleted using [Connection.BulkDocs]
+type deletedDocument struct {
+	ID      string `json:"_id"`
+	Rev     string `json:"_rev"`
+	Deleted bool   `json:"_deleted"`
+}
+
+// DeletedDocument returns a document that deletes the revision of the document
+// with the id when written using [Connection.BulkDocs].
+func DeletedDocument(id, rev string) any {
+	return deletedDocument{ID: id, Rev: rev, Deleted: true}
+}
+
+// Delete deletes the revision rev of the document with the id, returning the
+// revision of the deleted document. Returns [ErrConflict] if the document has
+// been modified, and [ErrNotFound] if it doesn't exist.
+//
+// CouchDB keeps a tombstone of deleted documents, which is replicated, and
+// emitted on the changes feed. Use [Connection.PurgeDocument] to remove all
+// traces of a document.
+func (c Connection) Delete(ctx context.Context, id, rev string) (newRev string, err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("couchdb: Delete: %w", err)
+		}
+	}()
+	var header = make(http.Header)
+	header.Add("If-Match", rev)
+	resp, err := c.req(ctx, "DELETE", c.docURL(id), header, nil)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case 200, 202:
+		newRev, err = getRevision(resp)
+	case 404:
+		err = fmt.Errorf("%w: %s", ErrNotFound, id)
+	case 409:
+		err = ErrConflict
+	default:
+		err = fmt.Errorf("id(%s): %w", id, errUnexpectedStatusCode(resp))
+	}
+	return
+}
+
+// DeleteIfExists deletes the current revision of the document with the id.
+// Deleting a document that doesn't exist, or is already deleted, is not an
+// error.
+func (c Connection) DeleteIfExists(ctx context.Context, id string) (err error) {
+	for range maxDeleteAttempts {
+		var rev string
+		if rev, err = c.rev(ctx, id); err == nil {
+			_, err = c.Delete(ctx, id, rev)
+		}
+		if errors.Is(err, ErrNotFound) {
+			return nil
+		}
+		if !errors.Is(err, ErrConflict) {
+			break
+		}
+	}
+	return err
+}
+
+// rev returns the current revision of the document with the id, without
+// reading the document body.
+func (c Connection) rev(ctx context.Context, id string) (rev string, err error) {
+	resp, err := c.req(ctx, "HEAD", c.docURL(id), nil, nil)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case 200:
+		rev, err = getRevision(resp)
+	case 404:
+		err = fmt.Errorf("%w: %s", ErrNotFound, id)
+	default:
+		err = fmt.Errorf("couchdb: head(%s): %w", id, errUnexpectedStatusCode(resp))
+	}
+	return
+}
+
+// Purge permanently removes document revisions, including deleted revisions,
+// from the database. Purged revisions are not replicated, and not emitted on
+// the changes feed. The revs map document IDs to revisions to purge.
+//
+// Purging requires CouchDB admin privileges. It exists for hard deletion of
+// personal data, e.g., when the document ID contains an email address. Normal
+// application code should use [Connection.Delete].
+func (c Connection) Purge(
+	ctx context.Context,
+	revs map[string][]string,
+) (purged map[string][]string, err error) {
+	var res struct {
+		Purged map[string][]string `json:"purged"`
+	}
+	if err = c.post(ctx, "_purge", revs, &res); err != nil {
+		return nil, fmt.Errorf("couchdb: Purge: %w", err)
+	}
+	return res.Purged, nil
+}
+
+// PurgeDocument purges all leaf revisions of the document with the id,
+// including the tombstone of a deleted document. Purging a document that
+// doesn't exist is not an error. See [Connection.Purge].
+func (c Connection) PurgeDocument(ctx context.Context, id string) error {
+	revs, err := c.leafRevs(ctx, id)
+	if err == nil && len(revs) > 0 {
+		_, err = c.Purge(ctx, map[string][]string{id: revs})
+	}
+	if err != nil {
+		return fmt.Errorf("couchdb: PurgeDocument: %w", err)
+	}
+	return nil
+}
+
+// leafRevs returns the revisions of all leaves in the revision tree of the
+// document, including deleted leaves and conflicts.
+func (c Connection) leafRevs(ctx context.Context, id string) ([]string, error) {
+	u := c.dbURL.JoinPath(id)
+	u.RawQuery = url.Values{"open_revs": {"all"}}.Encode()
+	header := make(http.Header)
+	header.Set("Accept", "application/json")
+	resp, err := c.req(ctx, "GET", u.String(), header, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case 200:
+	case 404:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("get(%s): %w", id, errUnexpectedStatusCode(resp))
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrConn, err)
+	}
+	var leaves []struct {
+		OK *couchDoc `json:"ok"`
+	}
+	if err := json.Unmarshal(body, &leaves); err != nil {
+		return nil, err
+	}
+	revs := make([]string, 0, len(leaves))
+	for _, l := range leaves {
+		if l.OK != nil {
+			revs = append(revs, l.OK.Rev)
+		}
+	}
+	return revs, nil
+}
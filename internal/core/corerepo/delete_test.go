@@ -0,0 +1,100 @@
+package corerepo_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"harmony/internal/core/corerepo"
+
+	gonanoid "github.com/matoous/go-nanoid/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDelete(t *testing.T) {
+	conn := corerepo.DefaultConnection
+	ctx := t.Context()
+	id := "corerepo_test:delete:" + gonanoid.Must()
+	rev, err := conn.Insert(ctx, id, Doc{Foo: "Bar"})
+	assert.NoError(t, err)
+	newRev, err := conn.Update(ctx, id, rev, Doc{Foo: "Baz"})
+	assert.NoError(t, err)
+
+	_, err = conn.Delete(ctx, id, rev)
+	assert.ErrorIs(t, err, corerepo.ErrConflict, "Deleting stale revision")
+
+	_, err = conn.Delete(ctx, id, newRev)
+	assert.NoError(t, err)
+
+	_, err = conn.Get(ctx, id, &Doc{})
+	assert.ErrorIs(t, err, corerepo.ErrNotFound, "Get deleted document")
+	_, err = conn.Delete(ctx, id, newRev)
+	assert.ErrorIs(t, err, corerepo.ErrNotFound, "Deleting deleted document")
+	assert.NoError(t, conn.DeleteIfExists(ctx, id), "DeleteIfExists on deleted document")
+}
+
+func TestDeleteIfExists(t *testing.T) {
+	conn := corerepo.DefaultConnection
+	ctx := t.Context()
+	id := "corerepo_test:delete:" + gonanoid.Must()
+	assert.NoError(t, conn.DeleteIfExists(ctx, id), "DeleteIfExists on missing document")
+
+	_, err := conn.Insert(ctx, id, Doc{Foo: "Bar"})
+	assert.NoError(t, err)
+	assert.NoError(t, conn.DeleteIfExists(ctx, id))
+	_, err = conn.Get(ctx, id, &Doc{})
+	assert.ErrorIs(t, err, corerepo.ErrNotFound)
+}
+
+func TestDeleteEmitsTombstoneOnChangesFeed(t *testing.T) {
+	conn := corerepo.DefaultConnection
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	id := "corerepo_test:delete:" + gonanoid.Must()
+	rev, err := conn.Insert(ctx, id, Doc{Foo: "Bar"})
+	assert.NoError(t, err)
+
+	ch, err := conn.Changes(ctx)
+	assert.NoError(t, err)
+	// The feed starts "now", but the subscription may not be established when
+	// Changes returns. Keep deleting until the change is seen; only the first
+	// delete succeeds.
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case e, ok := <-ch:
+			if !ok {
+				t.Fatal("Changes feed closed before tombstone was received")
+			}
+			if e.ID == id {
+				assert.True(t, e.Deleted, "Change is a tombstone")
+				return
+			}
+		case <-ticker.C:
+			conn.Delete(ctx, id, rev)
+			conn.Insert(ctx, "corerepo_test:ping:"+gonanoid.Must(), Doc{})
+		case <-ctx.Done():
+			t.Fatal("Timeout waiting for tombstone on changes feed")
+		}
+	}
+}
+
+func TestPurgeDocument(t *testing.T) {
+	conn := corerepo.DefaultConnection
+	ctx := t.Context()
+	id := "corerepo_test:purge:" + gonanoid.Must()
+	rev, err := conn.Insert(ctx, id, Doc{Foo: "Bar"})
+	assert.NoError(t, err)
+	_, err = conn.Delete(ctx, id, rev)
+	assert.NoError(t, err)
+
+	assert.NoError(t, conn.PurgeDocument(ctx, id))
+	assert.NoError(t, conn.PurgeDocument(ctx, id), "Purging a purged document")
+
+	// Without the tombstone, the revision history starts over
+	rev, err = conn.Insert(ctx, id, Doc{Foo: "Bar"})
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(rev, "1-"), "Revision of recreated doc: %s", rev)
+}
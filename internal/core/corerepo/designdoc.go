@@ -0,0 +1,332 @@
+package corerepo
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"path"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+
+	"harmony/internal/infrastructure/log"
+)
+
+type View struct {
+	Map    string `json:"map,omitempty"`
+	Reduce string `json:"reduce,omitempty"`
+}
+
+type Views map[string]View
+type Filters map[string]string
+
+// Lib contains CommonJS modules shared by the views of a design document, by
+// name. CouchDB keeps them in the "lib" property of the views, and a map
+// function loads a module with require("views/lib/<name>").
+type Lib map[string]string
+
+// Index is a Mango index, used by [Find] to query documents by the fields.
+type Index struct {
+	Fields []string `json:"fields"`
+}
+
+type Indexes map[string]Index
+
+// DesignDoc represents a CouchDB design document, containing views, filter
+// functions, and an optional validation function. Modules shared by the views
+// are in Lib.
+//
+// CouchDB keeps Mango indexes in design documents of their own, so Indexes are
+// created in a separate design document; use [IndexRef] to refer to them.
+type DesignDoc struct {
+	Views             Views   `json:"views,omitempty"`
+	Lib               Lib     `json:"-"`
+	Filters           Filters `json:"filters"`
+	ValidateDocUpdate string  `json:"validate_doc_update,omitempty"`
+	Indexes           Indexes `json:"-"`
+}
+
+// designDocJSON is the JSON representation of a [DesignDoc], with Lib stored
+// in the views. V is the type of the views.
+type designDocJSON[V any] struct {
+	Views             map[string]V `json:"views,omitempty"`
+	Filters           Filters      `json:"filters"`
+	ValidateDocUpdate string       `json:"validate_doc_update,omitempty"`
+}
+
+// libView is the name of the property of the views containing Lib.
+const libView = "lib"
+
+func (d DesignDoc) MarshalJSON() ([]byte, error) {
+	views := make(map[string]any, len(d.Views)+1)
+	for name, view := range d.Views {
+		views[name] = view
+	}
+	if len(d.Lib) > 0 {
+		views[libView] = d.Lib
+	}
+	return json.Marshal(designDocJSON[any]{views, d.Filters, d.ValidateDocUpdate})
+}
+
+func (d *DesignDoc) UnmarshalJSON(data []byte) error {
+	var doc designDocJSON[json.RawMessage]
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	*d = DesignDoc{Filters: doc.Filters, ValidateDocUpdate: doc.ValidateDocUpdate}
+	for name, data := range doc.Views {
+		if name == libView {
+			if err := json.Unmarshal(data, &d.Lib); err != nil {
+				return err
+			}
+			continue
+		}
+		var view View
+		if err := json.Unmarshal(data, &view); err != nil {
+			return err
+		}
+		if d.Views == nil {
+			d.Views = make(Views)
+		}
+		d.Views[name] = view
+	}
+	return nil
+}
+
+// indexDesignDocSuffix is appended to the design document id to get the id of
+// the design document containing Mango indexes.
+const indexDesignDocSuffix = "-query"
+
+// IndexRef returns the reference to an index in a design document, to be used
+// in [FindQuery.UseIndex].
+func IndexRef(designDoc, index string) []string {
+	return []string{designDoc + indexDesignDocSuffix, index}
+}
+
+//go:embed designdocs
+var designDocFS embed.FS
+
+// designDocs contains the registered design documents, initially the "events"
+// design document used by the message source. It is initialized as a package
+// variable, so it is installed when the [DefaultConnection] bootstraps.
+var (
+	designDocsMu sync.Mutex
+	designDocs   = map[string]DesignDoc{
+		"events": MustLoadDesignDoc(designDocFS, "designdocs/events"),
+	}
+)
+
+// RegisterDesignDoc registers a design document to be installed by
+// [Connection.Bootstrap]. Bounded contexts register their design documents in
+// an init function, typically loaded with [MustLoadDesignDoc]. Panics if
+// another design document has the same id.
+func RegisterDesignDoc(id string, doc DesignDoc) {
+	designDocsMu.Lock()
+	defer designDocsMu.Unlock()
+	if _, ok := designDocs[id]; ok {
+		panic(fmt.Sprintf("corerepo: duplicate design document: %s", id))
+	}
+	designDocs[id] = doc
+}
+
+// RegisteredDesignDocs returns the registered design documents by id.
+func RegisteredDesignDocs() map[string]DesignDoc {
+	designDocsMu.Lock()
+	defer designDocsMu.Unlock()
+	return maps.Clone(designDocs)
+}
+
+// LoadDesignDoc reads a design document from JavaScript files in the
+// directory dir of fsys, typically an [embed.FS]. The directory has the layout
+//
+//	views/<name>.map.js
+//	views/<name>.reduce.js   (optional)
+//	views/lib/<name>.js      (optional, modules shared by the views)
+//	filters/<name>.js
+//	validate_doc_update.js   (optional)
+//
+// A reduce file may contain the name of a built-in reduce function, e.g.,
+// _count.
+func LoadDesignDoc(fsys fs.FS, dir string) (DesignDoc, error) {
+	var doc DesignDoc
+	read := func(name string) (string, error) {
+		b, err := fs.ReadFile(fsys, path.Join(dir, name))
+		return strings.TrimSpace(string(b)), err
+	}
+
+	views, err := fs.Glob(fsys, path.Join(dir, "views", "*.map.js"))
+	if err != nil {
+		return doc, err
+	}
+	for _, file := range views {
+		name := strings.TrimSuffix(path.Base(file), ".map.js")
+		var view View
+		if view.Map, err = read(path.Join("views", name+".map.js")); err != nil {
+			return doc, err
+		}
+		view.Reduce, err = read(path.Join("views", name+".reduce.js"))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return doc, err
+		}
+		if doc.Views == nil {
+			doc.Views = make(Views)
+		}
+		doc.Views[name] = view
+	}
+
+	libs, err := fs.Glob(fsys, path.Join(dir, "views", libView, "*.js"))
+	if err != nil {
+		return doc, err
+	}
+	for _, file := range libs {
+		name := strings.TrimSuffix(path.Base(file), ".js")
+		if doc.Lib == nil {
+			doc.Lib = make(Lib)
+		}
+		if doc.Lib[name], err = read(path.Join("views", libView, name+".js")); err != nil {
+			return doc, err
+		}
+	}
+
+	filters, err := fs.Glob(fsys, path.Join(dir, "filters", "*.js"))
+	if err != nil {
+		return doc, err
+	}
+	for _, file := range filters {
+		name := strings.TrimSuffix(path.Base(file), ".js")
+		if doc.Filters == nil {
+			doc.Filters = make(Filters)
+		}
+		if doc.Filters[name], err = read(path.Join("filters", name+".js")); err != nil {
+			return doc, err
+		}
+	}
+
+	doc.ValidateDocUpdate, err = read("validate_doc_update.js")
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return doc, err
+	}
+	if len(doc.Views) == 0 && len(doc.Filters) == 0 && doc.ValidateDocUpdate == "" {
+		return doc, fmt.Errorf("corerepo: LoadDesignDoc %s: no design functions found", dir)
+	}
+	return doc, nil
+}
+
+// MustLoadDesignDoc is like [LoadDesignDoc], but panics on errors. Intended for
+// design documents embedded in the binary.
+func MustLoadDesignDoc(fsys fs.FS, dir string) DesignDoc {
+	doc, err := LoadDesignDoc(fsys, dir)
+	if err != nil {
+		panic(err)
+	}
+	return doc
+}
+
+// installDesignDocs installs all registered design documents.
+func (c Connection) installDesignDocs(ctx context.Context) error {
+	docs := RegisteredDesignDocs()
+	for _, id := range slices.Sorted(maps.Keys(docs)) {
+		if err := c.SetDesignDoc(ctx, id, docs[id]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// diffDesignDocs describes the differences between two design documents, e.g.,
+// "views.by_account changed".
+func diffDesignDocs(old, new DesignDoc) []string {
+	var res []string
+	diff := func(kind string, old, new map[string]any) {
+		for _, name := range slices.Sorted(maps.Keys(new)) {
+			prev, ok := old[name]
+			switch {
+			case !ok:
+				res = append(res, kind+"."+name+" added")
+			case !reflect.DeepEqual(prev, new[name]):
+				res = append(res, kind+"."+name+" changed")
+			}
+		}
+		for _, name := range slices.Sorted(maps.Keys(old)) {
+			if _, ok := new[name]; !ok {
+				res = append(res, kind+"."+name+" removed")
+			}
+		}
+	}
+	diff("views", anyMap(old.Views), anyMap(new.Views))
+	diff("views.lib", anyMap(old.Lib), anyMap(new.Lib))
+	diff("filters", anyMap(old.Filters), anyMap(new.Filters))
+	if old.ValidateDocUpdate != new.ValidateDocUpdate {
+		res = append(res, "validate_doc_update changed")
+	}
+	return res
+}
+
+func anyMap[V any](m map[string]V) map[string]any {
+	res := make(map[string]any, len(m))
+	for k, v := range m {
+		res[k] = v
+	}
+	return res
+}
+
+// SetDesignDoc creates or updates the design document with the specified id.
+// The document is only updated if it differs from the existing version, and
+// the changes are logged.
+//
+// Mango indexes are created if they don't exist. Indexes removed from the
+// design document are not deleted.
+func (c Connection) SetDesignDoc(ctx context.Context, id string, doc DesignDoc) error {
+	for name, index := range doc.Indexes {
+		if err := c.setIndex(ctx, id+indexDesignDocSuffix, name, index); err != nil {
+			return err
+		}
+	}
+	doc.Indexes = nil
+	if len(doc.Views) == 0 && len(doc.Filters) == 0 && doc.ValidateDocUpdate == "" {
+		return nil
+	}
+	var existing DesignDoc
+	path := fmt.Sprintf("_design/%s", id)
+	rev, err := c.Get(ctx, path, &existing)
+	if errors.Is(err, ErrNotFound) {
+		if _, err = c.Insert(ctx, path, doc); err == nil {
+			log.Info(ctx, "couchdb: design document created", "id", id)
+		}
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	changes := diffDesignDocs(existing, doc)
+	if len(changes) == 0 {
+		return nil
+	}
+	if _, err = c.Update(ctx, path, rev, doc); err == nil {
+		log.Info(ctx, "couchdb: design document updated", "id", id, "changes", changes)
+	}
+	return err
+}
+
+// setIndex creates a Mango index. CouchDB doesn't create an index if an
+// identical index exists.
+func (c Connection) setIndex(ctx context.Context, ddoc, name string, index Index) error {
+	body := struct {
+		Index Index  `json:"index"`
+		DDoc  string `json:"ddoc"`
+		Name  string `json:"name"`
+		Type  string `json:"type"`
+	}{index, ddoc, name, "json"}
+	var res struct {
+		Result string `json:"result"`
+	}
+	if err := c.post(ctx, "_index", body, &res); err != nil {
+		return fmt.Errorf("couchdb: create index %s/%s: %w", ddoc, name, err)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package corerepo_test
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"harmony/internal/core/corerepo"
+
+	gonanoid "github.com/matoous/go-nanoid/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadDesignDoc(t *testing.T) {
+	fsys := fstest.MapFS{
+		"ddocs/test/views/by_name.map.js":    {Data: []byte("function(doc) { emit(doc.name) }\n")},
+		"ddocs/test/views/by_name.reduce.js": {Data: []byte("_count\n")},
+		"ddocs/test/views/all.map.js":        {Data: []byte("function(doc) { emit(doc._id) }")},
+		"ddocs/test/views/lib/keys.js":       {Data: []byte("exports.key = function(doc) { return doc.name }")},
+		"ddocs/test/filters/named.js":        {Data: []byte("function(doc, req) { return doc.name }")},
+		"ddocs/test/validate_doc_update.js":  {Data: []byte("function(newDoc, oldDoc, userCtx) {}")},
+		"ddocs/empty/README":                 {Data: []byte("Nothing here")},
+	}
+
+	doc, err := corerepo.LoadDesignDoc(fsys, "ddocs/test")
+	assert.NoError(t, err)
+	assert.Equal(t, corerepo.DesignDoc{
+		Views: corerepo.Views{
+			"by_name": {Map: "function(doc) { emit(doc.name) }", Reduce: "_count"},
+			"all":     {Map: "function(doc) { emit(doc._id) }"},
+		},
+		Lib:               corerepo.Lib{"keys": "exports.key = function(doc) { return doc.name }"},
+		Filters:           corerepo.Filters{"named": "function(doc, req) { return doc.name }"},
+		ValidateDocUpdate: "function(newDoc, oldDoc, userCtx) {}",
+	}, doc)
+
+	_, err = corerepo.LoadDesignDoc(fsys, "ddocs/empty")
+	assert.Error(t, err, "Directory without design functions")
+}
+
+func TestRegisteredDesignDocs(t *testing.T) {
+	docs := corerepo.RegisteredDesignDocs()
+	assert.Contains(t, docs["events"].Filters, "aggregate_events")
+	assert.Contains(t, docs["events"].Views, "unpublished_domain_events")
+	assert.Contains(t, docs["events"].Lib, "time", "Module shared by the views")
+
+	assert.Panics(t, func() {
+		corerepo.RegisterDesignDoc("events", corerepo.DesignDoc{})
+	}, "Registering duplicate design document")
+}
+
+func TestSetDesignDocUpdatesChangedDocument(t *testing.T) {
+	conn := corerepo.DefaultConnection
+	ctx := t.Context()
+	id := "corerepo_test-" + gonanoid.Must()
+	doc := corerepo.DesignDoc{
+		Views: corerepo.Views{"all": {Map: "function(doc) { emit(doc._id) }"}},
+	}
+	assert.NoError(t, conn.SetDesignDoc(ctx, id, doc))
+	var stored map[string]any
+	rev, err := conn.Get(ctx, "_design/"+id, &stored)
+	assert.NoError(t, err)
+
+	assert.NoError(t, conn.SetDesignDoc(ctx, id, doc))
+	unchangedRev, err := conn.Get(ctx, "_design/"+id, &stored)
+	assert.NoError(t, err)
+	assert.Equal(t, rev, unchangedRev, "Unchanged design document is not updated")
+
+	doc.Filters = corerepo.Filters{"all": "function(doc, req) { return true }"}
+	assert.NoError(t, conn.SetDesignDoc(ctx, id, doc))
+	var updated corerepo.DesignDoc
+	newRev, err := conn.Get(ctx, "_design/"+id, &updated)
+	assert.NoError(t, err)
+	assert.NotEqual(t, rev, newRev)
+	assert.Equal(t, doc, updated)
+
+	doc.Lib = corerepo.Lib{"keys": "exports.key = function(doc) { return doc._id }"}
+	assert.NoError(t, conn.SetDesignDoc(ctx, id, doc))
+	libRev, err := conn.Get(ctx, "_design/"+id, &stored)
+	assert.NoError(t, err)
+	assert.NotEqual(t, newRev, libRev, "Design document updated with changed lib")
+	assert.Equal(t, map[string]any{"keys": "exports.key = function(doc) { return doc._id }"},
+		stored["views"].(map[string]any)["lib"], "Lib stored in the views")
+	assert.NoError(t, conn.SetDesignDoc(ctx, id, doc))
+	unchangedRev, err = conn.Get(ctx, "_design/"+id, &updated)
+	assert.NoError(t, err)
+	assert.Equal(t, libRev, unchangedRev, "Unchanged lib is not updated")
+	assert.Equal(t, doc, updated)
+}
@@ -0,0 +1,3 @@
+function(doc, req) {
+	return doc.events && doc.events.length
+}
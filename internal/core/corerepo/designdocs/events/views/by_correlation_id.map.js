@@ -0,0 +1,6 @@
+function(doc) {
+	var timeKey = require("views/lib/time").timeKey
+	if (doc._id.startsWith("domain_event:") && doc.metadata && doc.metadata.correlation_id) {
+		emit([doc.metadata.correlation_id, timeKey(doc.created_at)], null)
+	}
+}
@@ -0,0 +1,6 @@
+function(doc) {
+	var timeKey = require("views/lib/time").timeKey
+	if (doc._id.startsWith("domain_event:")) {
+		emit(timeKey(doc.created_at), null)
+	}
+}
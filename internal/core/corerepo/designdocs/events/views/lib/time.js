@@ -0,0 +1,10 @@
+// timeKey formats the time like timeKey in event_query.go, with a fixed
+// number of fractional digits, so keys sort in time order.
+exports.timeKey = function(t) {
+	var m = /^(.{19})(?:\.(\d+))?(Z|[+-]\d\d:\d\d)$/.exec(t || "")
+	if (!m) {
+		return t
+	}
+	var secs = m[3] === "Z" ? m[1] : new Date(m[1] + m[3]).toISOString().substring(0, 19)
+	return secs + "." + ((m[2] || "") + "000000000").substring(0, 9) + "Z"
+}
@@ -0,0 +1,5 @@
+function(doc) {
+	if (doc._id.startsWith("domain_event:") && !doc.published_at) {
+		emit(doc._id, doc._id)
+	}
+}
@@ -3,9 +3,9 @@ package corerepo
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"harmony/internal/core"
 	"harmony/internal/infrastructure/log"
-	"net/url"
 )
 
 type DomainEventRepository struct {
@@ -21,6 +21,7 @@ func (r DomainEventRepository) Insert(
 	e core.DomainEvent,
 ) (core.DomainEvent, error) {
 	var err error
+	e = core.WithEventMetadata(ctx, []core.DomainEvent{e})[0]
 	e.Rev, err = r.DB.Insert(ctx, r.docID(e), e)
 	return e, err
 }
@@ -34,6 +35,26 @@ func (r DomainEventRepository) Update(
 	return e, err
 }
 
+// EventsByCorrelationID returns the domain events with the correlation ID,
+// e.g., caused by the same HTTP request, ordered by creation time. Only events
+// that have been extracted from entity documents by the [MessageSource] are
+// returned.
+func (r DomainEventRepository) EventsByCorrelationID(
+	ctx context.Context,
+	id string,
+) ([]core.DomainEvent, error) {
+	res, err := QueryViewDocs[core.DomainEvent](
+		ctx, *r.DB, "events", "by_correlation_id", ViewQuery{
+			StartKey: []any{id},
+			EndKey:   []any{id, map[string]any{}},
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("DomainEventRepository.EventsByCorrelationID: %w", err)
+	}
+	return res.Docs(), nil
+}
+
 // StreamOfEvents returns a channel of domain events. New events stored in the
 // database will automatically be sent to the channel
 func (r DomainEventRepository) StreamOfEvents(
@@ -50,11 +71,12 @@ func (r DomainEventRepository) StreamOfEvents(
 	return r.domainEventsOfChangeEvents(ctx, ch)
 }
 
-func (r DomainEventRepository) getCurrentDomainEvents() ([]core.DomainEvent, error) {
-	v := make(url.Values)
-	v.Add("include_docs", "true")
-	var res DocsViewResult[core.DomainEvent]
-	_, err := r.DB.GetPath("_design/events/_view/unpublished_domain_events", v, &res)
+func (r DomainEventRepository) getCurrentDomainEvents(
+	ctx context.Context,
+) ([]core.DomainEvent, error) {
+	res, err := QueryViewDocs[core.DomainEvent](
+		ctx, *r.DB, "events", "unpublished_domain_events", ViewQuery{},
+	)
 	return res.Docs(), err
 }
 
@@ -65,7 +87,7 @@ func (r DomainEventRepository) domainEventsOfChangeEvents(
 	ctx context.Context,
 	ch <-chan ChangeEvent,
 ) (<-chan core.DomainEvent, error) {
-	events, err := r.getCurrentDomainEvents()
+	events, err := r.getCurrentDomainEvents(ctx)
 	if err != nil {
 		return nil, err
 	}
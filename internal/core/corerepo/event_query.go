@@ -0,0 +1,168 @@
+package corerepo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"harmony/internal/core"
+	"harmony/internal/infrastructure/log"
+)
+
+// DefaultEventBatchSize is the number of events read in a single request when
+// iterating over events.
+const DefaultEventBatchSize = 100
+
+// ErrStopIteration can be returned from the callback of
+// [DomainEventRepository.EachEvent] to stop without an error.
+var ErrStopIteration = errors.New("corerepo: stop iteration")
+
+// EventQuery selects domain events from the event store. Zero values are not
+// used as criteria, so the zero query selects all events.
+//
+// Only events extracted from entity documents by the [MessageSource] are
+// included. AggregateID only matches events stored with the aggregate ID in
+// the metadata.
+type EventQuery struct {
+	// Type is the registered name of the event type, e.g.,
+	// "auth.AccountRegistered".
+	Type        string
+	AggregateID string
+	// From and To select events created in the interval; To is exclusive.
+	From time.Time
+	To   time.Time
+}
+
+// timeKeyLayout formats times in view keys with a fixed number of fractional
+// digits, so keys sort in time order; [time.RFC3339Nano], used for the
+// created_at field of stored events, removes trailing zeros.
+const timeKeyLayout = "2006-01-02T15:04:05.000000000Z"
+
+// timeKey formats t like the views of the "events" design document format the
+// created_at field of stored events.
+func timeKey(t time.Time) string { return t.UTC().Format(timeKeyLayout) }
+
+// view returns the view and view query selecting the events. Criteria not
+// covered by the view are checked by [EventQuery.matches].
+func (q EventQuery) view() (string, ViewQuery) {
+	var vq ViewQuery
+	rangeQuery := func(key string) ViewQuery {
+		vq.StartKey = []any{key}
+		vq.EndKey = []any{key, map[string]any{}}
+		if !q.From.IsZero() {
+			vq.StartKey = []any{key, timeKey(q.From)}
+		}
+		if !q.To.IsZero() {
+			vq.EndKey = []any{key, timeKey(q.To)}
+		}
+		return vq
+	}
+	switch {
+	case q.AggregateID != "":
+		return "by_aggregate", rangeQuery(q.AggregateID)
+	case q.Type != "":
+		return "by_type", rangeQuery(q.Type)
+	}
+	if !q.From.IsZero() {
+		vq.StartKey = timeKey(q.From)
+	}
+	if !q.To.IsZero() {
+		vq.EndKey = timeKey(q.To)
+	}
+	return "by_time", vq
+}
+
+func (q EventQuery) matches(e core.DomainEvent) bool {
+	if q.Type != "" && core.EventTypeName(e) != q.Type {
+		return false
+	}
+	return q.To.IsZero() || e.CreatedAt.Before(q.To)
+}
+
+// EachEvent calls fn for each event selected by the query, ordered by creation
+// time. The events are read in batches of [DefaultEventBatchSize]. Iteration
+// stops if fn returns an error, which is returned, unless it is
+// [ErrStopIteration].
+func (r DomainEventRepository) EachEvent(
+	ctx context.Context,
+	q EventQuery,
+	fn func(core.DomainEvent) error,
+) error {
+	view, vq := q.view()
+	vq.Limit = DefaultEventBatchSize
+	for {
+		res, err := QueryViewDocs[core.DomainEvent](ctx, *r.DB, "events", view, vq)
+		if err != nil {
+			return fmt.Errorf("DomainEventRepository.EachEvent: %w", err)
+		}
+		for _, e := range res.Docs() {
+			if !q.matches(e) {
+				continue
+			}
+			if err := fn(e); err != nil {
+				if errors.Is(err, ErrStopIteration) {
+					return nil
+				}
+				return err
+			}
+		}
+		if res.Next == nil {
+			return nil
+		}
+		vq = *res.Next
+	}
+}
+
+// Events returns the events selected by the query, ordered by creation time.
+// At most limit events are returned, if limit is positive.
+func (r DomainEventRepository) Events(
+	ctx context.Context,
+	q EventQuery,
+	limit int,
+) ([]core.DomainEvent, error) {
+	var res []core.DomainEvent
+	err := r.EachEvent(ctx, q, func(e core.DomainEvent) error {
+		res = append(res, e)
+		if limit > 0 && len(res) >= limit {
+			return ErrStopIteration
+		}
+		return nil
+	})
+	return res, err
+}
+
+// ReplayResult describes the outcome of replaying events.
+type ReplayResult struct {
+	Delivered int
+	Failed    int
+}
+
+// Replay delivers historical events selected by the query to the handler,
+// e.g., to rebuild a read model, or to resend emails after an outage. Events
+// are delivered in order of creation time, whether or not they have been
+// published. Events the handler fails to process are logged, and replay
+// continues with the next event.
+//
+// New events created by the handler are caused by the replayed event.
+func (r DomainEventRepository) Replay(
+	ctx context.Context,
+	q EventQuery,
+	handler core.EventHandler,
+) (ReplayResult, error) {
+	var res ReplayResult
+	err := r.EachEvent(ctx, q, func(e core.DomainEvent) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := handler.ProcessDomainEvent(core.WithCausingEvent(ctx, e), e); err != nil {
+			log.LogError(ctx, "corerepo: replay event", err,
+				"eventID", e.ID, "type", core.EventTypeName(e))
+			res.Failed++
+			return nil
+		}
+		res.Delivered++
+		return nil
+	})
+	return res, err
+}
@@ -0,0 +1,190 @@
+package corerepo_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"harmony/internal/core"
+	"harmony/internal/core/corerepo"
+
+	gonanoid "github.com/matoous/go-nanoid/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+type TestEvent struct {
+	EntityID string `json:"entity_id"`
+}
+
+func init() {
+	core.RegisterEventType(reflect.TypeFor[TestEvent](), "corerepo_test.TestEvent")
+}
+
+// insertEvents inserts an event of the aggregate for each entity ID, one
+// millisecond apart.
+func insertEvents(t *testing.T, aggregateID string, entityIDs ...string) []core.DomainEvent {
+	t.Helper()
+	ctx := core.WithAggregateID(t.Context(), aggregateID)
+	res := make([]core.DomainEvent, len(entityIDs))
+	start := time.Now().UTC()
+	for i, id := range entityIDs {
+		e := core.NewDomainEvent(TestEvent{id})
+		e.CreatedAt = start.Add(time.Duration(i) * time.Millisecond)
+		var err error
+		res[i], err = corerepo.DefaultDomainEventRepo.Insert(ctx, e)
+		assert.NoError(t, err)
+	}
+	return res
+}
+
+func TestEventsOrderedByTimeWithVaryingFractionalDigits(t *testing.T) {
+	repo := corerepo.DefaultDomainEventRepo
+	aggregateID := gonanoid.Must()
+	ctx := core.WithAggregateID(t.Context(), aggregateID)
+	// Formatted as RFC 3339 with 0, 1, 2, and 9 fractional digits, which don't
+	// sort in time order as strings.
+	start := time.Now().UTC().Truncate(time.Second)
+	offsets := []time.Duration{
+		0, 100 * time.Millisecond, 120 * time.Millisecond, 123456789 * time.Nanosecond,
+	}
+	events := make([]core.DomainEvent, len(offsets))
+	for i, offset := range offsets {
+		e := core.NewDomainEvent(TestEvent{gonanoid.Must()})
+		e.CreatedAt = start.Add(offset)
+		var err error
+		events[i], err = repo.Insert(ctx, e)
+		assert.NoError(t, err)
+	}
+
+	res, err := repo.Events(t.Context(), corerepo.EventQuery{AggregateID: aggregateID}, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, eventIDs(events), eventIDs(res), "By aggregate")
+
+	res, err = repo.Events(t.Context(), corerepo.EventQuery{
+		Type: "corerepo_test.TestEvent",
+		From: events[1].CreatedAt,
+		To:   events[3].CreatedAt,
+	}, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, eventIDs(events[1:3]), eventIDs(res), "By type and time")
+
+	res, err = repo.Events(t.Context(), corerepo.EventQuery{
+		From: events[0].CreatedAt,
+		To:   events[2].CreatedAt,
+	}, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, eventIDs(events[0:2]), eventIDs(res), "By time")
+}
+
+func eventIDs(events []core.DomainEvent) []core.EventID {
+	res := make([]core.EventID, len(events))
+	for i, e := range events {
+		res[i] = e.ID
+	}
+	return res
+}
+
+func TestEventsByAggregate(t *testing.T) {
+	repo := corerepo.DefaultDomainEventRepo
+	aggregateID := gonanoid.Must()
+	events := insertEvents(t, aggregateID, "1", "2", "3")
+	insertEvents(t, gonanoid.Must(), "other")
+
+	res, err := repo.Events(t.Context(), corerepo.EventQuery{AggregateID: aggregateID}, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, eventIDs(events), eventIDs(res))
+
+	res, err = repo.Events(t.Context(), corerepo.EventQuery{
+		AggregateID: aggregateID,
+		From:        events[1].CreatedAt,
+		To:          events[2].CreatedAt,
+	}, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, eventIDs(events[1:2]), eventIDs(res), "Time range, excluding To")
+
+	res, err = repo.Events(t.Context(), corerepo.EventQuery{
+		AggregateID: aggregateID,
+		Type:        "corerepo_test.Unknown",
+	}, 0)
+	assert.NoError(t, err)
+	assert.Empty(t, res, "Filtered by type")
+}
+
+func TestEventsByTypeAndTime(t *testing.T) {
+	repo := corerepo.DefaultDomainEventRepo
+	ids := make([]string, corerepo.DefaultEventBatchSize+5)
+	for i := range ids {
+		ids[i] = gonanoid.Must()
+	}
+	events := insertEvents(t, gonanoid.Must(), ids...)
+	q := corerepo.EventQuery{
+		Type: "corerepo_test.TestEvent",
+		From: events[0].CreatedAt,
+		To:   events[len(events)-1].CreatedAt.Add(time.Millisecond),
+	}
+
+	res, err := repo.Events(t.Context(), q, 0)
+	assert.NoError(t, err)
+	assert.Subset(t, eventIDs(res), eventIDs(events), "Events of all pages")
+
+	q.Type = ""
+	res, err = repo.Events(t.Context(), q, 3)
+	assert.NoError(t, err)
+	assert.Len(t, res, 3)
+	assert.Equal(t, events[0].ID, res[0].ID, "Oldest event first")
+}
+
+func TestReplay(t *testing.T) {
+	repo := corerepo.DefaultDomainEventRepo
+	aggregateID := gonanoid.Must()
+	events := insertEvents(t, aggregateID, "1", "fail", "3")
+
+	var delivered []string
+	var metadata []core.EventMetadata
+	res, err := repo.Replay(t.Context(), corerepo.EventQuery{AggregateID: aggregateID},
+		core.EventHandlerFunc(func(ctx context.Context, e core.DomainEvent) error {
+			body := e.Body.(TestEvent)
+			if body.EntityID == "fail" {
+				return errors.New("Handler error")
+			}
+			delivered = append(delivered, body.EntityID)
+			metadata = append(metadata, core.MetadataFromContext(ctx))
+			return nil
+		}),
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, corerepo.ReplayResult{Delivered: 2, Failed: 1}, res)
+	assert.Equal(t, []string{"1", "3"}, delivered)
+	assert.Equal(t, string(events[0].ID), metadata[0].CausationID)
+}
+
+func TestEventsByCorrelationID(t *testing.T) {
+	repo := corerepo.DefaultDomainEventRepo
+	correlationID := gonanoid.Must()
+	ctx := core.WithCorrelationID(t.Context(), correlationID)
+
+	first, err := repo.Insert(ctx, core.NewDomainEvent(TestEvent{"1"}))
+	assert.NoError(t, err)
+	second, err := repo.Insert(
+		core.WithCausingEvent(t.Context(), first),
+		core.NewDomainEvent(TestEvent{"2"}),
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, core.EventMetadata{
+		CorrelationID: correlationID,
+		CausationID:   string(first.ID),
+		Actor:         core.ActorSystem,
+	}, second.Metadata)
+	_, err = repo.Insert(t.Context(), core.NewDomainEvent(TestEvent{"3"}))
+	assert.NoError(t, err)
+
+	events, err := repo.EventsByCorrelationID(t.Context(), correlationID)
+	assert.NoError(t, err)
+	ids := make([]core.EventID, len(events))
+	for i, e := range events {
+		ids[i] = e.ID
+	}
+	assert.Equal(t, []core.EventID{first.ID, second.ID}, ids)
+}
@@ -0,0 +1,141 @@
+package corerepo
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"harmony/internal/infrastructure/metrics"
+	"harmony/internal/infrastructure/trace"
+)
+
+var (
+	requestCount = metrics.NewCounterVec(
+		"couchdb_requests_total",
+		"Number of requests to CouchDB",
+		"operation", "prefix", "status",
+	)
+	changeEventCount = metrics.NewCounterVec(
+		"couchdb_change_events_total",
+		"Number of change events received from the CouchDB changes feed",
+		"status",
+	)
+	requestDuration = metrics.NewHistogramVec(
+		"couchdb_request_duration_seconds",
+		"Latency of requests to CouchDB, until the response headers are received",
+		metrics.DefaultBuckets,
+		"operation", "prefix",
+	)
+)
+
+// docPrefix returns the type of document from the document ID, i.e., the
+// first two components of the ID, e.g., "auth:account" for
+// "auth:account:email:jd@example.com". Design documents and special endpoints
+// use the path, e.g., "_design/events" or "_all_docs".
+func docPrefix(id string) string {
+	if strings.HasPrefix(id, "_design/") {
+		parts := strings.SplitN(id, "/", 3)
+		return strings.Join(parts[:2], "/")
+	}
+	if strings.HasPrefix(id, "_") {
+		first, _, _ := strings.Cut(id, "/")
+		return first
+	}
+	parts := strings.Split(id, ":")
+	switch len(parts) {
+	case 1:
+		return "other"
+	case 2:
+		return parts[0]
+	default:
+		return parts[0] + ":" + parts[1]
+	}
+}
+
+// operation describes the request, e.g., "get" or "insert".
+func operation(method, path string, headers http.Header) string {
+	switch {
+	case path == "":
+		return "create_db"
+	case strings.Contains(path, "/_view/"):
+		return "view"
+	case strings.HasPrefix(path, "_") && !strings.HasPrefix(path, "_design/"):
+		first, _, _ := strings.Cut(path, "/")
+		return strings.TrimPrefix(first, "_")
+	}
+	switch method {
+	case "PUT":
+		if headers.Get("If-Match") != "" {
+			return "update"
+		}
+		return "insert"
+	default:
+		return strings.ToLower(method)
+	}
+}
+
+// instrument records metrics and a trace span for the request sent by do.
+func (c Connection) instrument(
+	ctx context.Context,
+	method, u string,
+	headers http.Header,
+	do func(context.Context) (*http.Response, error),
+) (*http.Response, error) {
+	path := u
+	if parsed, err := url.Parse(u); err == nil {
+		path = strings.TrimPrefix(parsed.Path, c.dbURL.Path)
+		path = strings.TrimPrefix(path, "/")
+	}
+	op := operation(method, path, headers)
+	prefix := docPrefix(path)
+
+	ctx, span := trace.Start(ctx, "couchdb "+op)
+	defer span.End()
+	span.SetAttr("db.system", "couchdb")
+	span.SetAttr("db.operation", op)
+	span.SetAttr("db.doc_prefix", prefix)
+
+	start := time.Now()
+	resp, err := do(ctx)
+	requestDuration.Observe(time.Since(start).Seconds(), op, prefix)
+
+	status := "error"
+	if err == nil {
+		status = strconv.Itoa(resp.StatusCode)
+		span.SetAttr("http.status_code", status)
+	} else {
+		span.SetError(err)
+	}
+	requestCount.Inc(op, prefix, status)
+	return resp, err
+}
+
+// instrumentedTransport records metrics and a trace span for requests sent by
+// clients that don't send requests through the connection, e.g., the SSE
+// client of the changes feed, which reconnects by itself. The client doesn't
+// pass the context of the caller to the requests, so spans are started in ctx.
+type instrumentedTransport struct {
+	ctx  context.Context
+	conn Connection
+	base http.RoundTripper
+}
+
+func (t instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.conn.instrument(t.ctx, req.Method, req.URL.String(), req.Header,
+		func(context.Context) (*http.Response, error) { return t.base.RoundTrip(req) })
+}
+
+// instrumentedClient returns a copy of the HTTP client of the connection,
+// instrumenting each request, with spans started in ctx.
+func (c Connection) instrumentedClient(ctx context.Context) *http.Client {
+	client := *c.httpClient()
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	client.Transport = instrumentedTransport{ctx, c, base}
+	return &client
+}
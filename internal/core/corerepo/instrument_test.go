@@ -0,0 +1,98 @@
+package corerepo_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"harmony/internal/core/corerepo"
+	"harmony/internal/infrastructure/metrics"
+	"harmony/internal/infrastructure/trace"
+
+	gonanoid "github.com/matoous/go-nanoid/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+var recorder = &trace.Recorder{}
+
+func init() { trace.SetExporter(recorder) }
+
+func TestConnectionRecordsSpans(t *testing.T) {
+	conn := corerepo.DefaultConnection
+	ctx, root := trace.Start(t.Context(), "test")
+	id := "corerepo_test:instrument:" + gonanoid.Must()
+
+	_, err := conn.Insert(ctx, id, Doc{Foo: "Bar"})
+	assert.NoError(t, err)
+	_, err = conn.Get(ctx, id, &Doc{})
+	assert.NoError(t, err)
+	root.End()
+
+	spans := recorder.Spans(root.TraceID())
+	if assert.Len(t, spans, 3) {
+		insert, get := spans[0], spans[1]
+		assert.Equal(t, "couchdb insert", insert.Name)
+		assert.Equal(t, root.SpanID(), insert.ParentID)
+		assert.Equal(t, "corerepo_test:instrument", insert.Attrs["db.doc_prefix"])
+		assert.Equal(t, "201", insert.Attrs["http.status_code"])
+		assert.Equal(t, "couchdb get", get.Name)
+		assert.Equal(t, "200", get.Attrs["http.status_code"])
+	}
+}
+
+func TestConnectionRecordsMetrics(t *testing.T) {
+	conn := corerepo.DefaultConnection
+	_, err := conn.Get(t.Context(), "corerepo_test:instrument:"+gonanoid.Must(), &Doc{})
+	assert.ErrorIs(t, err, corerepo.ErrNotFound)
+
+	var b strings.Builder
+	metrics.Default.Write(&b)
+	assert.Contains(t, b.String(), fmt.Sprintf(
+		`couchdb_requests_total{operation=%q,prefix=%q,status=%q}`,
+		"get", "corerepo_test:instrument", "404",
+	))
+	assert.Contains(t, b.String(), fmt.Sprintf(
+		`couchdb_request_duration_seconds_count{operation=%q,prefix=%q}`,
+		"get", "corerepo_test:instrument",
+	))
+}
+
+func TestChangesFeedIsInstrumented(t *testing.T) {
+	conn := corerepo.DefaultConnection
+	ctx, cancel := context.WithTimeout(t.Context(), 5*time.Second)
+	defer cancel()
+	ctx, root := trace.Start(ctx, "test")
+	id := "corerepo_test:instrument:" + gonanoid.Must()
+
+	ch, err := conn.Changes(ctx)
+	assert.NoError(t, err)
+	// The subscription may not be established when Changes returns
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for received := false; !received; {
+		select {
+		case e := <-ch:
+			received = e.ID == id
+		case <-ticker.C:
+			conn.DeleteIfExists(t.Context(), id)
+			conn.Insert(t.Context(), id, Doc{Foo: "Bar"})
+		case <-ctx.Done():
+			t.Fatal("Timeout waiting for change")
+		}
+	}
+	root.End()
+
+	var names []string
+	for _, s := range recorder.Spans(root.TraceID()) {
+		names = append(names, s.Name)
+	}
+	assert.Contains(t, names, "couchdb changes", "Span of the changes feed request")
+	var b strings.Builder
+	metrics.Default.Write(&b)
+	assert.Contains(t, b.String(), fmt.Sprintf(
+		`couchdb_requests_total{operation=%q,prefix=%q,status=%q}`, "changes", "_changes", "200",
+	))
+	assert.Contains(t, b.String(), `couchdb_change_events_total{status="ok"}`)
+}
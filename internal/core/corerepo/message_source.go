@@ -59,6 +59,11 @@ func (c MessageSource) processNewEntity(
 	}
 	doc.Events = nil
 	_, err := c.DB.Update(ctx, doc.ID, doc.Rev, doc)
+	if errors.Is(err, ErrConflict) {
+		// Processed by the listener of another instance, or the entity was
+		// updated, and the change will be received again.
+		return
+	}
 	if err != nil {
 		log.Error(ctx, "corerepo: process event", "err", err)
 		return
@@ -0,0 +1,302 @@
+package corerepo
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"harmony/internal/core"
+	"harmony/internal/infrastructure/log"
+)
+
+// migrationsDocID is the ID of the document keeping track of applied
+// migrations. Local documents are not replicated, so each database keeps its
+// own migration state.
+const migrationsDocID = "_local/migrations"
+
+// DefaultMigrationBatchSize is the number of documents read and written in a
+// single request by [Migrator] helpers.
+const DefaultMigrationBatchSize = 100
+
+// Migration changes the shape of stored documents, e.g., renaming ID prefixes
+// or fields. Migrations are applied in order of Version, and each migration is
+// only applied once.
+//
+// Migrations must be safe to run again if they fail halfway, as the migration
+// is only marked as applied when Run completes.
+//
+// Versions are shared by all bounded contexts. Use the date the migration was
+// written followed by a sequence number, e.g., 2026_10_19_01, to avoid
+// conflicts.
+type Migration struct {
+	Version int
+	Name    string
+	Run     func(context.Context, *Migrator) error
+}
+
+var (
+	migrationsMu sync.Mutex
+	migrations   []Migration
+)
+
+// RegisterMigration registers a migration to be applied by
+// [Connection.Bootstrap]. Bounded contexts register their migrations in an
+// init function. Panics if another migration has the same version.
+func RegisterMigration(m Migration) {
+	migrationsMu.Lock()
+	defer migrationsMu.Unlock()
+	for _, existing := range migrations {
+		if existing.Version == m.Version {
+			panic(fmt.Sprintf(
+				"corerepo: duplicate migration version %d: %s, %s",
+				m.Version, existing.Name, m.Name,
+			))
+		}
+	}
+	migrations = append(migrations, m)
+}
+
+// RegisteredMigrations returns the registered migrations, ordered by version.
+func RegisteredMigrations() []Migration {
+	migrationsMu.Lock()
+	defer migrationsMu.Unlock()
+	return slices.SortedFunc(slices.Values(migrations), func(a, b Migration) int {
+		return cmp.Compare(a.Version, b.Version)
+	})
+}
+
+type appliedMigration struct {
+	Version   int       `json:"version"`
+	Name      string    `json:"name"`
+	AppliedAt time.Time `json:"applied_at"`
+	Changed   int       `json:"changed"`
+}
+
+type migrationsDoc struct {
+	Applied []appliedMigration `json:"applied"`
+}
+
+func (d migrationsDoc) isApplied(version int) bool {
+	return slices.ContainsFunc(d.Applied, func(m appliedMigration) bool {
+		return m.Version == version
+	})
+}
+
+// MigrationResult describes the outcome of a single migration.
+type MigrationResult struct {
+	Version int
+	Name    string
+	// Changed is the number of documents written, or that would have been
+	// written in a dry run.
+	Changed int
+}
+
+// Migrate applies the migrations that haven't been applied to the database,
+// in order of version. If dryRun is true, nothing is written, and the result
+// contains the changes that would have been made.
+//
+// Instances of the server starting at the same time may apply the same
+// migration; migrations are safe to run again, and a migration recorded by
+// another instance meanwhile is treated as applied.
+func (c Connection) Migrate(
+	ctx context.Context,
+	dryRun bool,
+	migrations ...Migration,
+) ([]MigrationResult, error) {
+	var state migrationsDoc
+	rev, err := c.Get(ctx, migrationsDocID, &state)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, fmt.Errorf("couchdb: Migrate: %w", err)
+	}
+	migrations = slices.SortedFunc(slices.Values(migrations), func(a, b Migration) int {
+		return cmp.Compare(a.Version, b.Version)
+	})
+	var res []MigrationResult
+	for _, m := range migrations {
+		if state.isApplied(m.Version) {
+			continue
+		}
+		migrator := &Migrator{
+			Connection: c,
+			Clock:      c.cfg.clock,
+			DryRun:     dryRun,
+			BatchSize:  DefaultMigrationBatchSize,
+		}
+		if err := m.Run(ctx, migrator); err != nil {
+			return res, fmt.Errorf("couchdb: migration %d %s: %w", m.Version, m.Name, err)
+		}
+		res = append(res, MigrationResult{m.Version, m.Name, migrator.changed})
+		if dryRun {
+			continue
+		}
+		rev, err = c.recordMigration(ctx, rev, &state, appliedMigration{
+			Version:   m.Version,
+			Name:      m.Name,
+			AppliedAt: core.Now(c.cfg.clock),
+			Changed:   migrator.changed,
+		})
+		if err != nil {
+			return res, fmt.Errorf("couchdb: migration %d %s: %w", m.Version, m.Name, err)
+		}
+		log.Info(ctx, "couchdb: migration applied",
+			"version", m.Version, "name", m.Name, "changed", migrator.changed)
+	}
+	return res, nil
+}
+
+// maxMigrationStateAttempts is the number of times recordMigration tries to
+// write the state, when it is updated concurrently.
+const maxMigrationStateAttempts = 5
+
+// recordMigration adds the applied migration to the state, returning the new
+// revision. Instances starting at the same time apply migrations concurrently;
+// if the state was updated by another instance, it is read again, and a
+// migration already recorded by the other instance is treated as applied.
+func (c Connection) recordMigration(
+	ctx context.Context,
+	rev string,
+	state *migrationsDoc,
+	applied appliedMigration,
+) (string, error) {
+	for range maxMigrationStateAttempts {
+		if state.isApplied(applied.Version) {
+			return rev, nil
+		}
+		next := migrationsDoc{Applied: append(slices.Clone(state.Applied), applied)}
+		var newRev string
+		var err error
+		if rev == "" {
+			newRev, err = c.Insert(ctx, migrationsDocID, next)
+		} else {
+			newRev, err = c.Update(ctx, migrationsDocID, rev, next)
+		}
+		if err == nil {
+			*state = next
+			return newRev, nil
+		}
+		if !errors.Is(err, ErrConflict) {
+			return rev, err
+		}
+		*state = migrationsDoc{}
+		if rev, err = c.Get(ctx, migrationsDocID, state); err != nil &&
+			!errors.Is(err, ErrNotFound) {
+			return rev, err
+		}
+	}
+	return rev, ErrConflict
+}
+
+// Migrator is passed to [Migration.Run], providing helpers for common
+// changes. The helpers read and write documents in batches, and respect
+// DryRun.
+type Migrator struct {
+	Connection Connection
+	// Clock is the clock of the connection, see [ConnOptClock], for migrations
+	// setting the time of documents.
+	Clock     core.Clock
+	DryRun    bool
+	BatchSize int
+	changed   int
+}
+
+// Changed adds to the number of changed documents reported for the migration.
+// Only needed by migrations writing documents without the helpers.
+func (m *Migrator) Changed(count int) { m.changed += count }
+
+// eachBatch calls fn with each batch of documents with IDs starting with
+// prefix.
+func (m *Migrator) eachBatch(
+	ctx context.Context,
+	prefix string,
+	fn func([]map[string]any) error,
+) error {
+	q := PrefixQuery(prefix)
+	q.Limit = m.BatchSize
+	for {
+		res, err := AllDocs[map[string]any](ctx, m.Connection, q)
+		if err != nil {
+			return err
+		}
+		if err := fn(res.Docs()); err != nil {
+			return err
+		}
+		if res.Next == nil {
+			return nil
+		}
+		q = *res.Next
+	}
+}
+
+// RenamePrefix changes the ID of all documents with IDs starting with from,
+// replacing from with to. The documents are copied to the new ID, and the
+// original is deleted. A copy that already exists, e.g., from an interrupted
+// run, is kept.
+func (m *Migrator) RenamePrefix(ctx context.Context, from, to string) error {
+	return m.eachBatch(ctx, from, func(docs []map[string]any) error {
+		m.changed += len(docs)
+		if m.DryRun || len(docs) == 0 {
+			return nil
+		}
+		copies := make([]any, len(docs))
+		for i, doc := range docs {
+			id, _ := doc["_id"].(string)
+			cp := maps.Clone(doc)
+			delete(cp, "_rev")
+			cp["_id"] = to + strings.TrimPrefix(id, from)
+			copies[i] = cp
+		}
+		results, err := m.Connection.BulkDocs(ctx, copies...)
+		if err != nil {
+			return err
+		}
+		deleted := make([]any, 0, len(docs))
+		var errs []error
+		for i, r := range results {
+			if err := r.Err(); err != nil && !errors.Is(err, ErrConflict) {
+				errs = append(errs, err)
+				continue
+			}
+			id, _ := docs[i]["_id"].(string)
+			rev, _ := docs[i]["_rev"].(string)
+			deleted = append(deleted, DeletedDocument(id, rev))
+		}
+		results, err = m.Connection.BulkDocs(ctx, deleted...)
+		return errors.Join(append(errs, err, BulkErr(results))...)
+	})
+}
+
+// RewriteDocs calls rewrite for each document with an ID starting with
+// prefix. The document is stored if rewrite returns true.
+func (m *Migrator) RewriteDocs(
+	ctx context.Context,
+	prefix string,
+	rewrite func(doc map[string]any) (changed bool, err error),
+) error {
+	return m.eachBatch(ctx, prefix, func(docs []map[string]any) error {
+		var changed []any
+		for _, doc := range docs {
+			ok, err := rewrite(doc)
+			if err != nil {
+				return fmt.Errorf("rewrite %v: %w", doc["_id"], err)
+			}
+			if ok {
+				changed = append(changed, doc)
+			}
+		}
+		m.changed += len(changed)
+		if m.DryRun || len(changed) == 0 {
+			return nil
+		}
+		results, err := m.Connection.BulkDocs(ctx, changed...)
+		if err != nil {
+			return err
+		}
+		return BulkErr(results)
+	})
+}
@@ -0,0 +1,188 @@
+package corerepo_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"harmony/internal/core/corerepo"
+
+	gonanoid "github.com/matoous/go-nanoid/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+// Versions of the test migrations. Real migrations use the date they were
+// written, so the versions don't collide.
+const (
+	renameVersion = iota + 1
+	rewriteVersion
+	concurrentVersion
+)
+
+// appliedVersions returns the versions recorded in the migration state.
+func appliedVersions(t testing.TB) (res []int) {
+	t.Helper()
+	var state struct {
+		Applied []map[string]any `json:"applied"`
+	}
+	_, err := corerepo.DefaultConnection.Get(t.Context(), "_local/migrations", &state)
+	if !errors.Is(err, corerepo.ErrNotFound) {
+		assert.NoError(t, err)
+	}
+	for _, m := range state.Applied {
+		v, _ := m["version"].(float64)
+		res = append(res, int(v))
+	}
+	return res
+}
+
+// forgetMigration removes the version from the migration state before and
+// after the test, so the migration can be applied again by the next run.
+func forgetMigration(t *testing.T, version int) {
+	forget := func() {
+		conn := corerepo.DefaultConnection
+		ctx := context.WithoutCancel(t.Context())
+		for range 5 {
+			var state map[string]any
+			rev, err := conn.Get(ctx, "_local/migrations", &state)
+			if errors.Is(err, corerepo.ErrNotFound) {
+				return
+			}
+			assert.NoError(t, err)
+			applied, _ := state["applied"].([]any)
+			kept := slices.DeleteFunc(slices.Clone(applied), func(m any) bool {
+				v, _ := m.(map[string]any)["version"].(float64)
+				return int(v) == version
+			})
+			if len(kept) == len(applied) {
+				return
+			}
+			state["applied"] = kept
+			delete(state, "_id")
+			delete(state, "_rev")
+			_, err = conn.Update(ctx, "_local/migrations", rev, state)
+			if !errors.Is(err, corerepo.ErrConflict) {
+				assert.NoError(t, err)
+				return
+			}
+		}
+		t.Error("Migration state updated concurrently")
+	}
+	forget()
+	t.Cleanup(forget)
+}
+
+func TestMigrateRenamePrefix(t *testing.T) {
+	conn := corerepo.DefaultConnection
+	ctx := t.Context()
+	prefix := "corerepo_test:migration:" + gonanoid.Must()
+	ids := []string{"a", "b", "c"}
+	for _, id := range ids {
+		_, err := conn.Insert(ctx, prefix+":old:"+id, Doc{Foo: id})
+		assert.NoError(t, err)
+	}
+	forgetMigration(t, renameVersion)
+	migration := corerepo.Migration{
+		Version: renameVersion,
+		Name:    "test: rename",
+		Run: func(ctx context.Context, m *corerepo.Migrator) error {
+			m.BatchSize = 2
+			return m.RenamePrefix(ctx, prefix+":old:", prefix+":new:")
+		},
+	}
+
+	res, err := conn.Migrate(ctx, true, migration)
+	assert.NoError(t, err)
+	assert.Equal(t, []corerepo.MigrationResult{{migration.Version, "test: rename", 3}}, res)
+	_, err = conn.Get(ctx, prefix+":old:a", &Doc{})
+	assert.NoError(t, err, "Dry run doesn't change documents")
+
+	res, err = conn.Migrate(ctx, false, migration)
+	assert.NoError(t, err)
+	assert.Equal(t, []corerepo.MigrationResult{{migration.Version, "test: rename", 3}}, res)
+	for _, id := range ids {
+		var doc Doc
+		_, err = conn.Get(ctx, prefix+":new:"+id, &doc)
+		assert.NoError(t, err)
+		assert.Equal(t, id, doc.Foo)
+		_, err = conn.Get(ctx, prefix+":old:"+id, &doc)
+		assert.ErrorIs(t, err, corerepo.ErrNotFound)
+	}
+
+	res, err = conn.Migrate(ctx, false, migration)
+	assert.NoError(t, err)
+	assert.Empty(t, res, "Applied migrations are not run again")
+}
+
+func TestMigrateRewriteDocs(t *testing.T) {
+	conn := corerepo.DefaultConnection
+	ctx := t.Context()
+	prefix := "corerepo_test:migration:" + gonanoid.Must() + ":"
+	_, err := conn.Insert(ctx, prefix+"a", map[string]any{"Bar": "a"})
+	assert.NoError(t, err)
+	_, err = conn.Insert(ctx, prefix+"b", Doc{Foo: "b"})
+	assert.NoError(t, err)
+
+	forgetMigration(t, rewriteVersion)
+	migration := corerepo.Migration{
+		Version: rewriteVersion,
+		Name:    "test: rename field",
+		Run: func(ctx context.Context, m *corerepo.Migrator) error {
+			return m.RewriteDocs(ctx, prefix, func(doc map[string]any) (bool, error) {
+				v, ok := doc["Bar"]
+				if ok {
+					doc["Foo"] = v
+					delete(doc, "Bar")
+				}
+				return ok, nil
+			})
+		},
+	}
+	res, err := conn.Migrate(ctx, false, migration)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, res[0].Changed)
+
+	var doc Doc
+	_, err = conn.Get(ctx, prefix+"a", &doc)
+	assert.NoError(t, err)
+	assert.Equal(t, "a", doc.Foo)
+}
+
+func TestMigrateConcurrently(t *testing.T) {
+	conn := corerepo.DefaultConnection
+	forgetMigration(t, concurrentVersion)
+	var runs atomic.Int32
+	migration := corerepo.Migration{
+		Version: concurrentVersion,
+		Name:    "test: concurrent",
+		Run: func(context.Context, *corerepo.Migrator) error {
+			runs.Add(1)
+			return nil
+		},
+	}
+
+	// Like instances starting at the same time
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Go(func() {
+			_, err := conn.Migrate(t.Context(), false, migration)
+			assert.NoError(t, err)
+		})
+	}
+	wg.Wait()
+
+	assert.Positive(t, runs.Load())
+	assert.Equal(t, 1, countOf(appliedVersions(t), concurrentVersion), "Migration recorded once")
+}
+
+func countOf(s []int, v int) (res int) {
+	for _, e := range s {
+		if e == v {
+			res++
+		}
+	}
+	return
+}
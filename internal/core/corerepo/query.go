@@ -0,0 +1,214 @@
+package corerepo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+// ViewQuery contains the options for querying a view, or _all_docs. Zero
+// values are omitted from the query.
+//
+// Keys are JSON encoded, so they can be any value that CouchDB can compare,
+// e.g., strings, numbers, or arrays for composite keys.
+type ViewQuery struct {
+	Key           any
+	StartKey      any
+	StartKeyDocID string
+	EndKey        any
+	Limit         int
+	Skip          int
+	Descending    bool
+	IncludeDocs   bool
+}
+
+// PrefixQuery returns a query for rows with string keys starting with prefix.
+func PrefixQuery(prefix string) ViewQuery {
+	return ViewQuery{StartKey: prefix, EndKey: prefix + "\ufff0"}
+}
+
+// KeyQuery returns a query for the rows with the key.
+func KeyQuery(key any) ViewQuery { return ViewQuery{Key: key} }
+
+// values returns the URL query parameters for the query. If the query is
+// paginated, an extra row is requested to detect if more pages exist.
+func (q ViewQuery) values() (url.Values, error) {
+	res := make(url.Values)
+	setKey := func(name string, key any) error {
+		if key == nil {
+			return nil
+		}
+		k, err := json.Marshal(key)
+		if err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrRequest, name, err)
+		}
+		res.Set(name, string(k))
+		return nil
+	}
+	if err := setKey("key", q.Key); err != nil {
+		return nil, err
+	}
+	if err := setKey("startkey", q.StartKey); err != nil {
+		return nil, err
+	}
+	if err := setKey("endkey", q.EndKey); err != nil {
+		return nil, err
+	}
+	if q.StartKeyDocID != "" {
+		res.Set("startkey_docid", q.StartKeyDocID)
+	}
+	if q.Limit > 0 {
+		res.Set("limit", strconv.Itoa(q.Limit+1))
+	}
+	if q.Skip > 0 {
+		res.Set("skip", strconv.Itoa(q.Skip))
+	}
+	if q.Descending {
+		res.Set("descending", "true")
+	}
+	if q.IncludeDocs {
+		res.Set("include_docs", "true")
+	}
+	return res, nil
+}
+
+// next returns the query for the page following a page of limit rows, where
+// the extra row has the key and doc ID. Starting at the key and document ID
+// rather than skipping rows is what CouchDB recommends for pagination, as skip
+// becomes slow for large values.
+func (q ViewQuery) next(key json.RawMessage, id string) *ViewQuery {
+	q.StartKey = key
+	q.StartKeyDocID = id
+	q.Skip = 0
+	return &q
+}
+
+func viewPath(designDoc, view string) string {
+	return fmt.Sprintf("_design/%s/_view/%s", designDoc, view)
+}
+
+// QueryView returns the rows emitted by a view in a design document.
+//
+// If the query has a Limit, at most Limit rows are returned, and the Next
+// field of the result contains the query for the next page.
+func QueryView[T any](
+	ctx context.Context,
+	c Connection,
+	designDoc, view string,
+	q ViewQuery,
+) (res ViewResult[T], err error) {
+	q.IncludeDocs = false
+	if err = queryPath(ctx, c, viewPath(designDoc, view), q, &res); err != nil {
+		return res, fmt.Errorf("couchdb: QueryView: %w", err)
+	}
+	if q.Limit > 0 && len(res.Rows) > q.Limit {
+		last := res.Rows[q.Limit]
+		res.Rows = res.Rows[:q.Limit]
+		res.Next = q.next(last.Key, last.ID)
+	}
+	return res, nil
+}
+
+// QueryViewDocs returns the documents for the rows emitted by a view in a
+// design document.
+//
+// If the query has a Limit, at most Limit rows are returned, and the Next
+// field of the result contains the query for the next page.
+func QueryViewDocs[T any](
+	ctx context.Context,
+	c Connection,
+	designDoc, view string,
+	q ViewQuery,
+) (res DocsViewResult[T], err error) {
+	if res, err = queryDocs[T](ctx, c, viewPath(designDoc, view), q); err != nil {
+		err = fmt.Errorf("couchdb: QueryViewDocs: %w", err)
+	}
+	return
+}
+
+// AllDocs returns documents from the database by their ID, typically used with
+// a key range, e.g., [PrefixQuery].
+//
+// If the query has a Limit, at most Limit rows are returned, and the Next
+// field of the result contains the query for the next page.
+func AllDocs[T any](
+	ctx context.Context,
+	c Connection,
+	q ViewQuery,
+) (res DocsViewResult[T], err error) {
+	if res, err = queryDocs[T](ctx, c, "_all_docs", q); err != nil {
+		err = fmt.Errorf("couchdb: AllDocs: %w", err)
+	}
+	return
+}
+
+func queryDocs[T any](
+	ctx context.Context,
+	c Connection,
+	path string,
+	q ViewQuery,
+) (res DocsViewResult[T], err error) {
+	q.IncludeDocs = true
+	if err = queryPath(ctx, c, path, q, &res); err != nil {
+		return
+	}
+	if q.Limit > 0 && len(res.Rows) > q.Limit {
+		last := res.Rows[q.Limit]
+		res.Rows = res.Rows[:q.Limit]
+		res.Next = q.next(last.Key, last.ID)
+	}
+	return
+}
+
+func queryPath(ctx context.Context, c Connection, path string, q ViewQuery, res any) error {
+	values, err := q.values()
+	if err != nil {
+		return err
+	}
+	_, err = c.GetPath(ctx, path, values, res)
+	return err
+}
+
+// FindQuery is a Mango query, executed by [Find]. The Selector uses the
+// CouchDB query syntax, e.g.,
+//
+//	map[string]any{"type": "account", "created": map[string]any{"$gt": t}}
+type FindQuery struct {
+	Selector any      `json:"selector"`
+	Fields   []string `json:"fields,omitempty"`
+	// Sort contains field names, or objects of field name and direction, e.g.,
+	// map[string]string{"created": "desc"}.
+	Sort  []any `json:"sort,omitempty"`
+	Limit int   `json:"limit,omitempty"`
+	Skip  int   `json:"skip,omitempty"`
+	// Bookmark continues a previous query. Use the Bookmark of the previous
+	// [FindResult].
+	Bookmark string `json:"bookmark,omitempty"`
+	// UseIndex specifies the index to use, see [IndexRef].
+	UseIndex []string `json:"use_index,omitempty"`
+}
+
+// FindResult is the result of a Mango query.
+type FindResult[T any] struct {
+	Docs []T `json:"docs"`
+	// Bookmark is used to get the next page of results. Fewer documents than
+	// the Limit of the query indicates that there are no more results.
+	Bookmark string `json:"bookmark"`
+	// Warning is set by CouchDB, e.g., if no index could serve the query.
+	Warning string `json:"warning,omitempty"`
+}
+
+// Find executes a Mango query. Indexes supporting the query can be created by
+// adding them to a design document, see [DesignDoc].
+func Find[T any](ctx context.Context, c Connection, q FindQuery) (res FindResult[T], err error) {
+	if q.Selector == nil {
+		q.Selector = map[string]any{}
+	}
+	err = c.post(ctx, "_find", q, &res)
+	if err != nil {
+		err = fmt.Errorf("couchdb: Find: %w", err)
+	}
+	return
+}
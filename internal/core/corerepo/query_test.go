@@ -0,0 +1,109 @@
+package corerepo_test
+
+import (
+	"testing"
+
+	"harmony/internal/core/corerepo"
+
+	gonanoid "github.com/matoous/go-nanoid/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+type queryTestDoc struct {
+	ID    string `json:"_id"`
+	Rev   string `json:"_rev,omitempty"`
+	Group string `json:"group"`
+	Value int    `json:"value"`
+}
+
+// insertQueryTestDocs inserts count documents with IDs sharing a unique prefix
+// and group, returning the documents.
+func insertQueryTestDocs(t *testing.T, count int) []queryTestDoc {
+	t.Helper()
+	group := gonanoid.Must()
+	docs := make([]any, count)
+	res := make([]queryTestDoc, count)
+	for i := range count {
+		res[i] = queryTestDoc{
+			ID:    "corerepo_test:query:" + group + ":" + string(rune('a'+i)),
+			Group: group,
+			Value: i,
+		}
+		docs[i] = res[i]
+	}
+	results, err := corerepo.DefaultConnection.BulkDocs(t.Context(), docs...)
+	assert.NoError(t, err)
+	assert.NoError(t, corerepo.BulkErr(results))
+	for i, r := range results {
+		res[i].Rev = r.Rev
+	}
+	return res
+}
+
+func TestBulkDocs(t *testing.T) {
+	conn := corerepo.DefaultConnection
+	docs := insertQueryTestDocs(t, 2)
+
+	var reloaded queryTestDoc
+	_, err := conn.Get(t.Context(), docs[0].ID, &reloaded)
+	assert.NoError(t, err)
+	assert.Equal(t, docs[0], reloaded)
+
+	stale := docs[0]
+	stale.Rev = ""
+	docs[1].Value = 42
+	results, err := conn.BulkDocs(t.Context(), stale, docs[1])
+	assert.NoError(t, err, "Request error")
+	assert.ErrorIs(t, results[0].Err(), corerepo.ErrConflict)
+	assert.NoError(t, results[1].Err())
+	assert.ErrorIs(t, corerepo.BulkErr(results), corerepo.ErrConflict)
+}
+
+func TestAllDocsPagination(t *testing.T) {
+	docs := insertQueryTestDocs(t, 5)
+	q := corerepo.PrefixQuery("corerepo_test:query:" + docs[0].Group)
+	q.Limit = 2
+
+	var pages [][]queryTestDoc
+	for {
+		res, err := corerepo.AllDocs[queryTestDoc](t.Context(), corerepo.DefaultConnection, q)
+		if !assert.NoError(t, err) {
+			return
+		}
+		pages = append(pages, res.Docs())
+		if res.Next == nil {
+			break
+		}
+		q = *res.Next
+	}
+	assert.Equal(t, [][]queryTestDoc{docs[0:2], docs[2:4], docs[4:5]}, pages)
+}
+
+func TestFind(t *testing.T) {
+	conn := corerepo.DefaultConnection
+	assert.NoError(t, conn.SetDesignDoc(t.Context(), "corerepo_test", corerepo.DesignDoc{
+		Views: corerepo.Views{"by_value": corerepo.View{Map: testEntitiesByValue}},
+		Indexes: corerepo.Indexes{
+			"by_group_value": corerepo.Index{Fields: []string{"group", "value"}},
+		},
+	}))
+	docs := insertQueryTestDocs(t, 5)
+
+	q := corerepo.FindQuery{
+		Selector: map[string]any{
+			"group": docs[0].Group,
+			"value": map[string]any{"$gte": 1},
+		},
+		Sort:     []any{"group", "value"},
+		Limit:    3,
+		UseIndex: corerepo.IndexRef("corerepo_test", "by_group_value"),
+	}
+	res, err := corerepo.Find[queryTestDoc](t.Context(), conn, q)
+	assert.NoError(t, err)
+	assert.Equal(t, docs[1:4], res.Docs)
+
+	q.Bookmark = res.Bookmark
+	res, err = corerepo.Find[queryTestDoc](t.Context(), conn, q)
+	assert.NoError(t, err)
+	assert.Equal(t, docs[4:5], res.Docs)
+}
@@ -0,0 +1,157 @@
+package corerepo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"harmony/internal/core"
+)
+
+// DocumentMapper defines how entities of type T, identified by values of type
+// ID, are stored in CouchDB.
+//
+// The ID method matches repotest.EntityTranslator, so the same type can
+// identify entities in both real and fake repositories.
+type DocumentMapper[T, ID any] interface {
+	// ID returns the ID of the entity
+	ID(entity T) ID
+	// DocID returns the CouchDB document ID for the entity ID, typically the ID
+	// with a type specific prefix, e.g., "auth:account:".
+	DocID(id ID) string
+	// Rev returns the document revision stored on the entity, used for
+	// optimistic concurrency.
+	Rev(entity T) string
+	// SetRev stores the document revision on the entity.
+	SetRev(entity *T, rev string)
+}
+
+// Repository stores entities of type T in CouchDB. Each entity is stored as a
+// [DocumentWithEvents], i.e., domain events are stored in the same document as
+// the entity, and later published by the [MessageSource]. This implements
+// the "transactional outbox" pattern, as CouchDB only guarantees consistency for
+// a single document.
+//
+// Updates use optimistic concurrency. An entity can only be updated if the
+// revision of the entity matches the stored document, otherwise
+// [ErrConflict] is returned. Note that publishing domain events updates the
+// document, so an entity must be reloaded after events are published.
+type Repository[T any, ID comparable] struct {
+	DB     *Connection
+	Mapper DocumentMapper[T, ID]
+}
+
+// NewRepository creates a repository storing entities in the database using
+// the mapper.
+func NewRepository[T any, ID comparable](
+	db *Connection,
+	mapper DocumentMapper[T, ID],
+) Repository[T, ID] {
+	return Repository[T, ID]{DB: db, Mapper: mapper}
+}
+
+// Insert stores a new entity, and the domain events of the use case, with
+// metadata from the context; see [core.WithEventMetadata]. Returns
+// [ErrConflict] if an entity with the same ID exists.
+func (r Repository[T, ID]) Insert(ctx context.Context, uc core.UseCaseResult[T]) (T, error) {
+	entity := uc.Entity
+	doc := DocumentWithEvents[T]{
+		Document: entity,
+		Events:   core.WithEventMetadata(r.aggregateCtx(ctx, entity), uc.Events),
+	}
+	rev, err := r.DB.Insert(ctx, r.docID(entity), doc)
+	if err != nil {
+		return entity, fmt.Errorf("Repository.Insert: %w", err)
+	}
+	r.Mapper.SetRev(&entity, rev)
+	return entity, nil
+}
+
+// Get returns the entity with the ID. Returns [ErrNotFound] if the entity
+// doesn't exist.
+func (r Repository[T, ID]) Get(ctx context.Context, id ID) (res T, err error) {
+	var doc DocumentWithEvents[T]
+	rev, err := r.DB.Get(ctx, r.Mapper.DocID(id), &doc)
+	if err != nil {
+		return res, fmt.Errorf("Repository.Get: %w", err)
+	}
+	res = doc.Document
+	r.Mapper.SetRev(&res, rev)
+	return res, nil
+}
+
+// Update stores the entity. Returns [ErrConflict] if the entity was modified
+// since it was read.
+func (r Repository[T, ID]) Update(ctx context.Context, entity T) (T, error) {
+	return r.UpdateWithEvents(ctx, core.UseCaseOfEntity(entity))
+}
+
+// UpdateWithEvents stores the entity, and the domain events of the use case.
+// Returns [ErrConflict] if the entity was modified since it was read.
+//
+// Events of a previous use case that have not yet been published are kept.
+func (r Repository[T, ID]) UpdateWithEvents(
+	ctx context.Context,
+	uc core.UseCaseResult[T],
+) (T, error) {
+	entity := uc.Entity
+	id := r.docID(entity)
+	rev := r.Mapper.Rev(entity)
+
+	var existing DocumentWithEvents[json.RawMessage]
+	currentRev, err := r.DB.Get(ctx, id, &existing)
+	if err != nil {
+		return entity, fmt.Errorf("Repository.UpdateWithEvents: %w", err)
+	}
+	if currentRev != rev {
+		return entity, fmt.Errorf("Repository.UpdateWithEvents: %w", ErrConflict)
+	}
+	events := core.WithEventMetadata(r.aggregateCtx(ctx, entity), uc.Events)
+	doc := DocumentWithEvents[T]{
+		Document: entity,
+		Events:   append(existing.Events, events...),
+	}
+	newRev, err := r.DB.Update(ctx, id, rev, doc)
+	if err != nil {
+		return entity, fmt.Errorf("Repository.UpdateWithEvents: %w", err)
+	}
+	r.Mapper.SetRev(&entity, newRev)
+	return entity, nil
+}
+
+// Query returns the entities emitted by a view in a design document. The view
+// must emit rows for entity documents, as the documents are included in the
+// response.
+func (r Repository[T, ID]) Query(
+	ctx context.Context,
+	designDoc, view string,
+	q ViewQuery,
+) ([]T, error) {
+	res, err := QueryViewDocs[DocumentWithEvents[T]](ctx, *r.DB, designDoc, view, q)
+	if err != nil {
+		return nil, fmt.Errorf("Repository.Query: %w", err)
+	}
+	entities := make([]T, len(res.Rows))
+	for i, doc := range res.Docs() {
+		entities[i] = doc.Document
+		r.Mapper.SetRev(&entities[i], doc.Rev)
+	}
+	return entities, nil
+}
+
+// QueryKey returns the entities for which the view emits the key.
+func (r Repository[T, ID]) QueryKey(
+	ctx context.Context,
+	designDoc, view string,
+	key any,
+) ([]T, error) {
+	return r.Query(ctx, designDoc, view, KeyQuery(key))
+}
+
+func (r Repository[T, ID]) docID(entity T) string {
+	return r.Mapper.DocID(r.Mapper.ID(entity))
+}
+
+// aggregateCtx returns a context for storing events of the entity.
+func (r Repository[T, ID]) aggregateCtx(ctx context.Context, entity T) context.Context {
+	return core.WithAggregateID(ctx, fmt.Sprint(r.Mapper.ID(entity)))
+}
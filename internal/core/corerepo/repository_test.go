@@ -0,0 +1,145 @@
+package corerepo_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"harmony/internal/core"
+	"harmony/internal/core/corerepo"
+	"harmony/internal/testing/repotest"
+
+	gonanoid "github.com/matoous/go-nanoid/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+type TestEntity struct {
+	ID    string
+	Rev   string `json:"-"`
+	Value string
+}
+
+type TestEntityMapper struct{}
+
+func (TestEntityMapper) ID(e TestEntity) string           { return e.ID }
+func (TestEntityMapper) DocID(id string) string           { return "corerepo_test:entity:" + id }
+func (TestEntityMapper) Rev(e TestEntity) string          { return e.Rev }
+func (TestEntityMapper) SetRev(e *TestEntity, rev string) { e.Rev = rev }
+
+const testEntitiesByValue = `function(doc) {
+	if (doc._id.startsWith("corerepo_test:entity:")) {
+		emit(doc.doc.Value, null)
+	}
+}`
+
+func initRepository(t *testing.T) corerepo.Repository[TestEntity, string] {
+	corerepo.AssertInitialized()
+	db := &corerepo.DefaultConnection
+	assert.NoError(t, db.SetDesignDoc(t.Context(), "corerepo_test", corerepo.DesignDoc{
+		Views: corerepo.Views{"by_value": corerepo.View{Map: testEntitiesByValue}},
+	}))
+	return corerepo.NewRepository(db, TestEntityMapper{})
+}
+
+func newEntityUseCase(value string) core.UseCaseResult[TestEntity] {
+	entity := TestEntity{ID: gonanoid.Must(), Value: value}
+	uc := core.UseCaseOfEntity(entity)
+	uc.AddEvent(core.NewDomainEvent(TestEvent{EntityID: entity.ID}))
+	return uc
+}
+
+func storedEvents(t testing.TB, entity TestEntity) []core.DomainEvent {
+	t.Helper()
+	var doc corerepo.DocumentWithEvents[json.RawMessage]
+	_, err := corerepo.DefaultConnection.Get(t.Context(), TestEntityMapper{}.DocID(entity.ID), &doc)
+	assert.NoError(t, err)
+	return doc.Events
+}
+
+func TestRepositoryRoundtrip(t *testing.T) {
+	ctx := t.Context()
+	repo := initRepository(t)
+
+	uc := newEntityUseCase("Foo")
+	inserted, err := repo.Insert(ctx, uc)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, inserted.Rev)
+	assert.Len(t, storedEvents(t, inserted), 1, "Events stored in the outbox")
+
+	_, err = repo.Insert(ctx, uc)
+	assert.ErrorIs(t, err, corerepo.ErrConflict, "Inserting a duplicate")
+
+	reloaded, err := repo.Get(ctx, inserted.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, inserted, reloaded)
+
+	_, err = repo.Get(ctx, "missing")
+	assert.ErrorIs(t, err, core.ErrNotFound)
+}
+
+func TestRepositoryUpdate(t *testing.T) {
+	ctx := t.Context()
+	repo := initRepository(t)
+	inserted, err := repo.Insert(ctx, newEntityUseCase("Foo"))
+	assert.NoError(t, err)
+
+	inserted.Value = "Bar"
+	uc := core.UseCaseOfEntity(inserted)
+	uc.AddEvent(core.NewDomainEvent(TestEvent{EntityID: inserted.ID}))
+	updated, err := repo.UpdateWithEvents(ctx, uc)
+	assert.NoError(t, err)
+	assert.NotEqual(t, inserted.Rev, updated.Rev)
+	assert.Len(t, storedEvents(t, updated), 2, "Unpublished events are kept")
+
+	reloaded, err := repo.Get(ctx, inserted.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "Bar", reloaded.Value)
+
+	inserted.Value = "Baz"
+	_, err = repo.Update(ctx, inserted)
+	assert.ErrorIs(t, err, corerepo.ErrConflict, "Updating a stale entity")
+}
+
+func TestRepositoryQuery(t *testing.T) {
+	ctx := t.Context()
+	repo := initRepository(t)
+	value := gonanoid.Must()
+	e1, err1 := repo.Insert(ctx, newEntityUseCase(value))
+	e2, err2 := repo.Insert(ctx, newEntityUseCase(value))
+	_, err3 := repo.Insert(ctx, newEntityUseCase("other"))
+	assert.NoError(t, err1)
+	assert.NoError(t, err2)
+	assert.NoError(t, err3)
+
+	found, err := repo.QueryKey(ctx, "corerepo_test", "by_value", value)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []TestEntity{e1, e2}, found)
+}
+
+func TestRepositoryContract(t *testing.T) {
+	repo := initRepository(t)
+	repotest.RunRepositoryContract(t, repotest.RepositoryContract[TestEntity, string]{
+		Repository: repo,
+		NewEntity:  func() TestEntity { return TestEntity{ID: gonanoid.Must()} },
+		ID:         TestEntityMapper{}.ID,
+		Modify:     func(e *TestEntity) { e.Value = gonanoid.Must() },
+		StoredEvents: func(t testing.TB, id string) []core.DomainEvent {
+			return storedEvents(t, TestEntity{ID: id})
+		},
+	})
+}
+
+func TestRepositoryStoresEventMetadata(t *testing.T) {
+	ctx := core.WithActor(core.WithCorrelationID(t.Context(), "req-1"), "acc-1")
+	repo := initRepository(t)
+
+	inserted, err := repo.Insert(ctx, newEntityUseCase("Foo"))
+	assert.NoError(t, err)
+	events := storedEvents(t, inserted)
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, core.EventMetadata{
+			CorrelationID: "req-1",
+			Actor:         "acc-1",
+			AggregateID:   inserted.ID,
+		}, events[0].Metadata)
+	}
+}
@@ -24,80 +24,149 @@ type DomainEvent struct {
 	Rev         string     `json:"-"`
 	CreatedAt   time.Time  `json:"created_at"`
 	PublishedAt *time.Time `json:"published_at"`
-	Body        EventBody
+	// DeliverAfter delays processing of a scheduled event until the time. See
+	// [NewScheduledEvent].
+	DeliverAfter *time.Time `json:"deliver_after,omitempty"`
+	Metadata     EventMetadata
+	Body         EventBody
 }
 
-func (e *DomainEvent) MarkPublished() {
+// Due returns whether the event should be processed at the time, i.e., it isn't
+// scheduled, or the time to deliver it has come.
+func (e DomainEvent) Due(now time.Time) bool {
+	return e.DeliverAfter == nil || !now.Before(*e.DeliverAfter)
+}
+
+// MarkPublished records the time of the clock as the time the event was
+// published, unless already published.
+func (e *DomainEvent) MarkPublished(clock Clock) {
 	if e.PublishedAt != nil {
 		return
 	}
-	now := time.Now().UTC()
+	now := Now(clock).UTC()
 	e.PublishedAt = &now
 }
 
 func (e DomainEvent) MarshalJSON() ([]byte, error) {
 	var js eventJSON
-	typeName := types[reflect.TypeOf(e.Body)]
 
 	if e.Body == nil {
 		return nil, fmt.Errorf("domain: Event.MarshalJSON: body is nil")
 	}
-	if typeName == "" {
-		return nil, fmt.Errorf("domain: Event.MarshalJSON: no registration for type %T", e.Body)
-	}
-
-	rawMessage, err := json.Marshal(e.Body)
-	if err != nil {
-		return nil, err
+	if opaque, ok := e.Body.(OpaqueEvent); ok {
+		js.Type = opaque.Type
+		js.Version = opaque.Version
+		js.Body = opaque.Body
+	} else {
+		typeName := types[reflect.TypeOf(e.Body)]
+		if typeName == "" {
+			return nil, fmt.Errorf("domain: Event.MarshalJSON: no registration for type %T", e.Body)
+		}
+		rawMessage, err := json.Marshal(e.Body)
+		if err != nil {
+			return nil, err
+		}
+		js.Type = typeName
+		js.Version = currentVersion(typeName)
+		js.Body = rawMessage
 	}
 	js.ID = e.ID
 	js.CreatedAt = e.CreatedAt
 	js.PublishedAt = e.PublishedAt
-	js.Type = typeName
-	js.Body = (rawMessage)
+	js.DeliverAfter = e.DeliverAfter
+	js.Metadata = e.Metadata
 	return json.Marshal(js)
 }
 
 type eventJSON struct {
-	ID          EventID    `json:"id"`
-	Rev         string     `json:"_rev,omitempty"`
-	CreatedAt   time.Time  `json:"created_at"`
-	PublishedAt *time.Time `json:"published_at"`
-	Type        string     `json:"type"`
-	Body        json.RawMessage
+	ID           EventID       `json:"id"`
+	Rev          string        `json:"_rev,omitempty"`
+	CreatedAt    time.Time     `json:"created_at"`
+	PublishedAt  *time.Time    `json:"published_at"`
+	DeliverAfter *time.Time    `json:"deliver_after,omitempty"`
+	Type         string        `json:"type"`
+	Version      int           `json:"version"`
+	Metadata     EventMetadata `json:"metadata,omitzero"`
+	Body         json.RawMessage
 }
 
+// UnmarshalJSON reads an event, upcasting the body from the stored schema
+// version to the current version of the type. Events of unknown types, or
+// stored with a newer version than known, are read as an [OpaqueEvent].
 func (e *DomainEvent) UnmarshalJSON(data []byte) error {
 	var rawEvent eventJSON
 	if err := json.Unmarshal(data, &rawEvent); err != nil {
 		return err
 	}
+	e.ID = rawEvent.ID
+	e.Rev = rawEvent.Rev
+	e.PublishedAt = rawEvent.PublishedAt
+	e.CreatedAt = rawEvent.CreatedAt
+	e.DeliverAfter = rawEvent.DeliverAfter
+	e.Metadata = rawEvent.Metadata
+
+	version := max(rawEvent.Version, 1)
 	t := names[rawEvent.Type]
-	if t == nil {
-		return fmt.Errorf(
-			"domain: Event.UnmarshalJSON: unknown message type: %s - json %s",
-			rawEvent.Type, string(data),
-		)
+	if t == nil || version > currentVersion(rawEvent.Type) {
+		e.Body = OpaqueEvent{rawEvent.Type, version, rawEvent.Body}
+		return nil
+	}
+	rawBody, err := upcast(rawEvent.Type, version, rawEvent.Body)
+	if err != nil {
+		return fmt.Errorf("domain: Event.UnmarshalJSON: %s %s: %w", rawEvent.Type, rawEvent.ID, err)
 	}
 	body := reflect.New(t)
-	if err := json.Unmarshal(rawEvent.Body, body.Interface()); err != nil {
+	if err := json.Unmarshal(rawBody, body.Interface()); err != nil {
 		return err
 	}
-	e.ID = rawEvent.ID
-	e.Rev = rawEvent.Rev
-	e.PublishedAt = rawEvent.PublishedAt
-	e.CreatedAt = rawEvent.CreatedAt
 	e.Body = EventBody(body.Elem().Interface())
 	return nil
 }
 
-func NewDomainEvent(data EventBody) DomainEvent {
+// EventTypeName returns the registered name of the type of the event body,
+// e.g., "auth.AccountRegistered".
+func EventTypeName(e DomainEvent) string {
+	if opaque, ok := e.Body.(OpaqueEvent); ok {
+		return opaque.Type
+	}
+	return types[reflect.TypeOf(e.Body)]
+}
+
+// EventTypeNameOf returns the registered name of the event type T, or an
+// empty string if T isn't registered.
+func EventTypeNameOf[T any]() string { return types[reflect.TypeFor[T]()] }
+
+// OpaqueEvent is the body of an event that this version of the application
+// cannot interpret; either the type is unknown, or it was stored with a newer
+// schema version, e.g., by a newer version of the application. Handlers
+// should ignore opaque events. The event is written back unchanged.
+type OpaqueEvent struct {
+	Type    string
+	Version int
+	Body    json.RawMessage
+}
+
+// NewDomainEvent creates an event, created at the time of the [SystemClock].
+func NewDomainEvent(data EventBody) DomainEvent { return NewDomainEventAt(nil, data) }
+
+// NewDomainEventAt creates an event, created at the time of the clock.
+func NewDomainEventAt(clock Clock, data EventBody) DomainEvent {
 	return DomainEvent{
 		ID: NewEventID(), Body: data,
-		CreatedAt: time.Now().UTC(),
+		CreatedAt: Now(clock).UTC(),
 	}
 }
 
+// NewScheduledEvent creates an event that is processed when the time to
+// deliver it has come, e.g., a reminder. Message handlers can cancel a
+// scheduled event if it is no longer relevant when it is due.
+func NewScheduledEvent(clock Clock, data EventBody, deliverAfter time.Time) DomainEvent {
+	res := NewDomainEventAt(clock, data)
+	deliverAfter = deliverAfter.UTC()
+	res.DeliverAfter = &deliverAfter
+	return res
+}
+
 type UnmarshallerFunc func([]byte) (EventBody, error)
 
 func (f UnmarshallerFunc) UnmarshalEvent(data []byte) (EventBody, error) { return f(data) }
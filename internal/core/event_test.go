@@ -0,0 +1,47 @@
+package core_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"harmony/internal/core"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnknownEventsAreOpaque(t *testing.T) {
+	for _, data := range []string{
+		`{"id":"1","type":"core_test.Unknown","version":1,"Body":{"foo":"bar"}}`,
+		`{"id":"1","type":"core_test.Renamed","version":4,"Body":{"foo":"bar"}}`,
+	} {
+		var event core.DomainEvent
+		assert.NoError(t, json.Unmarshal([]byte(data), &event))
+		opaque, ok := event.Body.(core.OpaqueEvent)
+		assert.True(t, ok, "Body is opaque")
+		assert.JSONEq(t, `{"foo":"bar"}`, string(opaque.Body))
+
+		roundTrip, err := json.Marshal(event)
+		assert.NoError(t, err)
+		var expected, actual map[string]any
+		assert.NoError(t, json.Unmarshal([]byte(data), &expected))
+		assert.NoError(t, json.Unmarshal(roundTrip, &actual))
+		assert.Equal(t, expected["type"], actual["type"])
+		assert.Equal(t, expected["version"], actual["version"])
+		assert.Equal(t, expected["Body"], actual["Body"])
+	}
+}
+
+func TestScheduledEventIsDueAfterDeliveryTime(t *testing.T) {
+	deliverAfter := time.Now().Add(time.Hour)
+	event := core.NewScheduledEvent(nil, renamedEvent{FullName: "John Smith"}, deliverAfter)
+	assert.False(t, event.Due(deliverAfter.Add(-time.Second)))
+	assert.True(t, event.Due(deliverAfter))
+	assert.True(t, core.NewDomainEvent(renamedEvent{}).Due(time.Time{}), "Unscheduled event")
+
+	data, err := json.Marshal(event)
+	assert.NoError(t, err)
+	var reloaded core.DomainEvent
+	assert.NoError(t, json.Unmarshal(data, &reloaded))
+	assert.True(t, deliverAfter.Equal(*reloaded.DeliverAfter), "Delivery time stored")
+}
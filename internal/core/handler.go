@@ -0,0 +1,21 @@
+package core
+
+import "context"
+
+// EventHandler processes domain events, e.g., sending emails, or updating
+// read models.
+type EventHandler interface {
+	ProcessDomainEvent(context.Context, DomainEvent) error
+}
+
+// EventHandlerFunc is an [EventHandler] implemented by a function.
+type EventHandlerFunc func(context.Context, DomainEvent) error
+
+func (f EventHandlerFunc) ProcessDomainEvent(ctx context.Context, e DomainEvent) error {
+	return f(ctx, e)
+}
+
+// EventPublisher publishes domain events, e.g., an in-memory event bus.
+type EventPublisher interface {
+	Publish(context.Context, ...DomainEvent) error
+}
@@ -0,0 +1,133 @@
+// Package jobs runs periodic background jobs, e.g., removing stale data.
+//
+// A [Job] is a named function run at an interval by a [Runner]. Jobs run
+// independently of each other, and a job never runs concurrently with itself
+// in the same process. With multiple server instances, the runners share a
+// [lease.Store], and each job only runs on the instance holding its lease.
+package jobs
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"harmony/internal/core/lease"
+	"harmony/internal/infrastructure/log"
+	"harmony/internal/infrastructure/metrics"
+)
+
+var jobRuns = metrics.NewCounterVec(
+	"jobs_runs_total",
+	"Number of runs of background jobs",
+	"job", "result",
+)
+
+// Job is a function run at an interval.
+type Job struct {
+	// Name identifies the job in logs and metrics, e.g.,
+	// "auth.registration_cleanup".
+	Name string
+	// Interval is the time between the end of one run, and the start of the
+	// next.
+	Interval time.Duration
+	// Run performs the job. Errors are logged, and the job runs again at the
+	// next interval.
+	Run func(context.Context) error
+}
+
+// run runs the job once, logging errors, and recovering from panics so a
+// failing job doesn't stop the process.
+func (j Job) run(ctx context.Context) {
+	ctx = log.With(ctx, "job", j.Name)
+	defer func() {
+		if r := recover(); r != nil {
+			jobRuns.Inc(j.Name, "error")
+			log.Error(ctx, "Job panicked", "panic", r)
+		}
+	}()
+	if err := j.Run(ctx); err != nil {
+		jobRuns.Inc(j.Name, "error")
+		log.LogError(ctx, "Job failed", err)
+		return
+	}
+	jobRuns.Inc(j.Name, "ok")
+}
+
+// leaseName is the name of the lease giving an instance the right to run the
+// job.
+func (j Job) leaseName() string { return "job:" + j.Name }
+
+// leaseDuration is the time the lease is held after the job starts. The
+// holder renews the lease when the job runs again, so the lease is kept as
+// long as a run takes less than the interval. If the holder stops, another
+// instance takes over within two intervals.
+func (j Job) leaseDuration() time.Duration { return 2 * j.Interval }
+
+// Runner runs jobs at their interval.
+type Runner struct {
+	Jobs []Job
+	// Leases decides which instance runs the jobs. If nil, jobs run on all
+	// instances.
+	Leases lease.Store
+	// Holder identifies the instance holding leases. A new ID is generated if
+	// empty.
+	Holder string
+	// Stopped, if set, is waited for until the jobs have stopped, and released
+	// their leases.
+	Stopped *sync.WaitGroup
+}
+
+// Start runs each job immediately, and then at its interval, until the context
+// is cancelled. Start returns immediately.
+//
+// If the runner has leases, a job only runs if the instance holds the lease of
+// the job. Leases are released when the context is cancelled, so another
+// instance can take over.
+func (r Runner) Start(ctx context.Context) {
+	if r.Holder == "" {
+		r.Holder = lease.NewHolderID()
+	}
+	for _, j := range r.Jobs {
+		if r.Stopped != nil {
+			r.Stopped.Add(1)
+		}
+		go func() {
+			if r.Stopped != nil {
+				defer r.Stopped.Done()
+			}
+			defer r.release(ctx, j)
+			for {
+				if r.acquire(ctx, j) {
+					j.run(ctx)
+				}
+				select {
+				case <-time.After(j.Interval):
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+}
+
+// acquire returns whether this instance should run the job.
+func (r Runner) acquire(ctx context.Context, j Job) bool {
+	if r.Leases == nil {
+		return true
+	}
+	ok, err := r.Leases.Acquire(ctx, j.leaseName(), r.Holder, j.leaseDuration())
+	if err != nil {
+		log.LogError(ctx, "Runner: acquire lease", err, "job", j.Name)
+	}
+	return ok
+}
+
+func (r Runner) release(ctx context.Context, j Job) {
+	if r.Leases == nil {
+		return
+	}
+	ctx = context.WithoutCancel(ctx)
+	if err := r.Leases.Release(ctx, j.leaseName(), r.Holder); err != nil {
+		log.LogError(ctx, "Runner: release lease", err, "job", j.Name)
+	}
+}
@@ -0,0 +1,115 @@
+package jobs_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"harmony/internal/core/jobs"
+	"harmony/internal/core/lease"
+	"harmony/internal/testing/clocktest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunnerRunsJobsRepeatedly(t *testing.T) {
+	var ok, failing atomic.Int32
+	ctx, cancel := context.WithCancel(t.Context())
+	jobs.Runner{Jobs: []jobs.Job{{
+		Name:     "jobs_test.ok",
+		Interval: time.Millisecond,
+		Run:      func(context.Context) error { ok.Add(1); return nil },
+	}, {
+		Name:     "jobs_test.failing",
+		Interval: time.Millisecond,
+		Run: func(context.Context) error {
+			if failing.Add(1)%2 == 0 {
+				panic("job panicked")
+			}
+			return errors.New("job failed")
+		},
+	}}}.Start(ctx)
+
+	assert.Eventually(t, func() bool { return ok.Load() >= 3 && failing.Load() >= 3 },
+		time.Second, time.Millisecond, "Jobs run again after errors and panics")
+	cancel()
+	time.Sleep(10 * time.Millisecond)
+	n := ok.Load()
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, n, ok.Load(), "Jobs stopped when context cancelled")
+}
+
+// holders records the holders running a job.
+type holders struct {
+	mu   sync.Mutex
+	runs map[string]int
+}
+
+func (h *holders) job(holder string) jobs.Job {
+	return jobs.Job{
+		Name:     "jobs_test.singleton",
+		Interval: time.Millisecond,
+		Run: func(context.Context) error {
+			h.mu.Lock()
+			defer h.mu.Unlock()
+			h.runs[holder]++
+			return nil
+		},
+	}
+}
+
+func (h *holders) holders() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var res []string
+	for holder := range h.runs {
+		res = append(res, holder)
+	}
+	return res
+}
+
+func TestRunnersWithLeasesRunJobsOnOneInstance(t *testing.T) {
+	// Leases don't expire with a frozen clock, so only the released lease is
+	// taken over
+	store := &lease.MemoryStore{Clock: clocktest.New()}
+	h := &holders{runs: make(map[string]int)}
+	cancels := make(map[string]context.CancelFunc)
+	for _, holder := range []string{"a", "b", "c"} {
+		ctx, cancel := context.WithCancel(t.Context())
+		cancels[holder] = cancel
+		jobs.Runner{Jobs: []jobs.Job{h.job(holder)}, Leases: store, Holder: holder}.Start(ctx)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	running := h.holders()
+	if !assert.Len(t, running, 1, "Job runs on one instance") {
+		return
+	}
+	leader := running[0]
+
+	cancels[leader]()
+	assert.Eventually(t, func() bool { return len(h.holders()) == 2 },
+		time.Second, time.Millisecond, "Another instance takes over released lease")
+}
+
+func TestRunnerReleasesLeasesBeforeStopped(t *testing.T) {
+	store := &lease.MemoryStore{Clock: clocktest.New()}
+	h := &holders{runs: make(map[string]int)}
+	var stopped sync.WaitGroup
+	ctx, cancel := context.WithCancel(t.Context())
+	jobs.Runner{
+		Jobs:    []jobs.Job{h.job("a")},
+		Leases:  store,
+		Holder:  "a",
+		Stopped: &stopped,
+	}.Start(ctx)
+	cancel()
+	stopped.Wait()
+
+	ok, err := store.Acquire(t.Context(), "job:jobs_test.singleton", "b", time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, ok, "Lease released when stopped")
+}
@@ -0,0 +1,111 @@
+package lease
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"harmony/internal/core"
+	"harmony/internal/core/corerepo"
+)
+
+const docPrefix = "lease:"
+
+// CouchDBStore is a [Store] keeping leases in CouchDB documents, shared by all
+// instances using the database. Concurrent attempts to acquire a lease are
+// decided by the document revision; only one succeeds.
+type CouchDBStore struct {
+	DB *corerepo.Connection
+	// Clock decides when leases expire. The system clock is used if nil.
+	Clock core.Clock
+}
+
+type leaseDoc struct {
+	ID  string `json:"_id,omitempty"`
+	Rev string `json:"_rev,omitempty"`
+	Lease
+}
+
+func (s CouchDBStore) docID(name string) string { return docPrefix + name }
+
+func (s CouchDBStore) Acquire(
+	ctx context.Context, name, holder string, ttl time.Duration,
+) (bool, error) {
+	now := core.Now(s.Clock)
+	id := s.docID(name)
+	lease := Lease{Holder: holder, ExpiresAt: now.Add(ttl)}
+	var existing leaseDoc
+	rev, err := s.DB.Get(ctx, id, &existing)
+	switch {
+	case errors.Is(err, corerepo.ErrNotFound):
+		_, err = s.DB.Insert(ctx, id, lease)
+	case err != nil:
+	case existing.Held(holder, now):
+		return false, nil
+	default:
+		_, err = s.DB.Update(ctx, id, rev, lease)
+	}
+	if errors.Is(err, corerepo.ErrConflict) {
+		// Acquired by another instance in the meantime
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("lease.CouchDBStore.Acquire: %s: %w", name, err)
+	}
+	return true, nil
+}
+
+func (s CouchDBStore) Release(ctx context.Context, name, holder string) error {
+	id := s.docID(name)
+	var existing leaseDoc
+	rev, err := s.DB.Get(ctx, id, &existing)
+	if err == nil && existing.Holder == holder {
+		_, err = s.DB.Delete(ctx, id, rev)
+	}
+	// A conflict means the lease was acquired by another instance
+	if errors.Is(err, corerepo.ErrNotFound) || errors.Is(err, corerepo.ErrConflict) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("lease.CouchDBStore.Release: %s: %w", name, err)
+	}
+	return nil
+}
+
+// DeleteExpired deletes the documents of expired leases, e.g., of stopped
+// instances, returning the number of leases deleted. Leases acquired again
+// while deleting are kept.
+func (s CouchDBStore) DeleteExpired(ctx context.Context) (int, error) {
+	now := core.Now(s.Clock)
+	q := corerepo.PrefixQuery(docPrefix)
+	q.Limit = corerepo.DefaultEventBatchSize
+	var count int
+	for {
+		res, err := corerepo.AllDocs[leaseDoc](ctx, *s.DB, q)
+		if err != nil {
+			return count, fmt.Errorf("lease.CouchDBStore.DeleteExpired: %w", err)
+		}
+		var deleted []any
+		for _, doc := range res.Docs() {
+			if !now.Before(doc.ExpiresAt) {
+				deleted = append(deleted, corerepo.DeletedDocument(doc.ID, doc.Rev))
+			}
+		}
+		if len(deleted) > 0 {
+			results, err := s.DB.BulkDocs(ctx, deleted...)
+			if err != nil {
+				return count, fmt.Errorf("lease.CouchDBStore.DeleteExpired: %w", err)
+			}
+			for _, r := range results {
+				if r.Err() == nil {
+					count++
+				}
+			}
+		}
+		if res.Next == nil {
+			return count, nil
+		}
+		q = *res.Next
+	}
+}
@@ -0,0 +1,92 @@
+// Package lease coordinates work between multiple instances of the server.
+//
+// A lease gives one holder, i.e., a server instance, the exclusive right to do
+// a piece of work, e.g., running a singleton job, or processing an event, until
+// the lease expires. The holder renews the lease to keep it; if the holder
+// stops, another instance acquires the lease when it has expired.
+//
+// Expiry is decided by the clock of the instance acquiring the lease, so the
+// clocks of the instances must be reasonably synchronised compared to the
+// duration of the leases.
+package lease
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	"harmony/internal/core"
+
+	gonanoid "github.com/matoous/go-nanoid/v2"
+)
+
+// Lease is the state of an acquired lease.
+type Lease struct {
+	Holder    string    `json:"holder"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Held returns whether the lease is held by another holder than the one given
+// at the time.
+func (l Lease) Held(holder string, now time.Time) bool {
+	return l.Holder != holder && now.Before(l.ExpiresAt)
+}
+
+// Store keeps leases, shared by all instances. Implemented by [MemoryStore],
+// and [CouchDBStore].
+type Store interface {
+	// Acquire acquires the lease for the holder until ttl from now, unless
+	// held by another holder. A holder renews a lease by acquiring it again.
+	// Returns whether the lease was acquired.
+	Acquire(ctx context.Context, name, holder string, ttl time.Duration) (bool, error)
+	// Release gives up the lease, if held by the holder, so other instances can
+	// acquire it before it expires.
+	Release(ctx context.Context, name, holder string) error
+}
+
+// NewHolderID returns an ID identifying a server instance as holder of leases.
+// The ID contains the host name for troubleshooting, and is unique for each
+// call.
+func NewHolderID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return host + ":" + gonanoid.Must(8)
+}
+
+// MemoryStore is a [Store] keeping leases in memory, for tests, and multiple
+// pumps in a single process.
+type MemoryStore struct {
+	// Clock decides when leases expire. The system clock is used if nil.
+	Clock core.Clock
+
+	mu     sync.Mutex
+	leases map[string]Lease
+}
+
+func (s *MemoryStore) Acquire(
+	_ context.Context, name, holder string, ttl time.Duration,
+) (bool, error) {
+	now := core.Now(s.Clock)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.leases[name].Held(holder, now) {
+		return false, nil
+	}
+	if s.leases == nil {
+		s.leases = make(map[string]Lease)
+	}
+	s.leases[name] = Lease{Holder: holder, ExpiresAt: now.Add(ttl)}
+	return true, nil
+}
+
+func (s *MemoryStore) Release(_ context.Context, name, holder string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.leases[name].Holder == holder {
+		delete(s.leases, name)
+	}
+	return nil
+}
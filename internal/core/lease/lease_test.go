@@ -0,0 +1,98 @@
+package lease_test
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"harmony/internal/core/corerepo"
+	"harmony/internal/core/lease"
+	"harmony/internal/testing/clocktest"
+	_ "harmony/internal/testing/couchtest" // clear database before tests
+
+	gonanoid "github.com/matoous/go-nanoid/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func testStores(t *testing.T, test func(t *testing.T, s lease.Store, c *clocktest.FakeClock)) {
+	t.Run("MemoryStore", func(t *testing.T) {
+		c := clocktest.New()
+		test(t, &lease.MemoryStore{Clock: c}, c)
+	})
+	t.Run("CouchDBStore", func(t *testing.T) {
+		corerepo.AssertInitialized()
+		c := clocktest.New()
+		test(t, lease.CouchDBStore{DB: &corerepo.DefaultConnection, Clock: c}, c)
+	})
+}
+
+func TestLeaseStore(t *testing.T) {
+	testStores(t, func(t *testing.T, s lease.Store, c *clocktest.FakeClock) {
+		ctx := t.Context()
+		name := "lease_test:" + gonanoid.Must()
+		acquire := func(holder string) bool {
+			t.Helper()
+			ok, err := s.Acquire(ctx, name, holder, time.Minute)
+			assert.NoError(t, err)
+			return ok
+		}
+
+		assert.True(t, acquire("a"), "Acquire new lease")
+		assert.False(t, acquire("b"), "Acquire lease held by other holder")
+		c.Advance(30 * time.Second)
+		assert.True(t, acquire("a"), "Renew lease")
+		c.Advance(45 * time.Second)
+		assert.False(t, acquire("b"), "Acquire renewed lease")
+		c.Advance(15 * time.Second)
+		assert.True(t, acquire("b"), "Acquire expired lease")
+
+		assert.NoError(t, s.Release(ctx, name, "a"), "Release lease held by other holder")
+		assert.False(t, acquire("a"), "Acquire lease released by other holder")
+		assert.NoError(t, s.Release(ctx, name, "b"))
+		assert.True(t, acquire("a"), "Acquire released lease")
+	})
+}
+
+func TestLeaseStoreConcurrentAcquire(t *testing.T) {
+	testStores(t, func(t *testing.T, s lease.Store, _ *clocktest.FakeClock) {
+		name := "lease_test:" + gonanoid.Must()
+		var acquired atomic.Int32
+		var wg sync.WaitGroup
+		for range 5 {
+			wg.Go(func() {
+				ok, err := s.Acquire(t.Context(), name, lease.NewHolderID(), time.Minute)
+				assert.NoError(t, err)
+				if ok {
+					acquired.Add(1)
+				}
+			})
+		}
+		wg.Wait()
+		assert.Equal(t, int32(1), acquired.Load())
+	})
+}
+
+func TestCouchDBStoreDeleteExpired(t *testing.T) {
+	ctx := t.Context()
+	corerepo.AssertInitialized()
+	c := clocktest.New()
+	s := lease.CouchDBStore{DB: &corerepo.DefaultConnection, Clock: c}
+	expired := "lease_test:" + gonanoid.Must()
+	active := "lease_test:" + gonanoid.Must()
+	_, err := s.Acquire(ctx, expired, "a", time.Minute)
+	assert.NoError(t, err)
+	_, err = s.Acquire(ctx, active, "a", time.Hour)
+	assert.NoError(t, err)
+
+	c.Advance(time.Minute)
+	count, err := s.DeleteExpired(ctx)
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, count, 1)
+
+	var doc lease.Lease
+	_, err = corerepo.DefaultConnection.Get(ctx, "lease:"+expired, &doc)
+	assert.ErrorIs(t, err, corerepo.ErrNotFound, "Expired lease deleted")
+	_, err = corerepo.DefaultConnection.Get(ctx, "lease:"+active, &doc)
+	assert.NoError(t, err, "Active lease kept")
+}
@@ -0,0 +1,152 @@
+package ledger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"harmony/internal/core"
+	"harmony/internal/core/corerepo"
+)
+
+const docPrefix = "ledger:"
+
+// CouchDBLedger is a [Ledger] keeping an entry document for each handler and
+// event, shared by all instances using the database. Concurrent claims of an
+// event are decided by the document revision; only one succeeds.
+type CouchDBLedger struct {
+	DB *corerepo.Connection
+	// Clock sets the time entries are recorded, and decides when claims
+	// expire. The system clock is used if nil.
+	Clock core.Clock
+}
+
+type entryDoc struct {
+	ID  string `json:"_id,omitempty"`
+	Rev string `json:"_rev,omitempty"`
+	Entry
+}
+
+func (l CouchDBLedger) docID(id core.EventID, handler string) string {
+	return docPrefix + handler + ":" + string(id)
+}
+
+func (l CouchDBLedger) Processed(
+	ctx context.Context, id core.EventID, handler string,
+) (bool, error) {
+	var entry Entry
+	_, err := l.DB.Get(ctx, l.docID(id, handler), &entry)
+	if errors.Is(err, corerepo.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("ledger.CouchDBLedger.Processed: %w", err)
+	}
+	return entry.Processed(), nil
+}
+
+func (l CouchDBLedger) Claim(
+	ctx context.Context, id core.EventID, handler string,
+) (bool, error) {
+	now := core.Now(l.Clock)
+	docID := l.docID(id, handler)
+	entry := Entry{EventID: id, Handler: handler, ClaimedAt: now}
+	var existing Entry
+	rev, err := l.DB.Get(ctx, docID, &existing)
+	switch {
+	case errors.Is(err, corerepo.ErrNotFound):
+		_, err = l.DB.Insert(ctx, docID, entry)
+	case err != nil:
+	case existing.Claimed(now):
+		return false, nil
+	default:
+		// The claim expired, e.g., the process stopped before the side effect
+		// was recorded.
+		_, err = l.DB.Update(ctx, docID, rev, entry)
+	}
+	if errors.Is(err, corerepo.ErrConflict) {
+		// Claimed by another process in the meantime
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("ledger.CouchDBLedger.Claim: %w", err)
+	}
+	return true, nil
+}
+
+func (l CouchDBLedger) Record(ctx context.Context, id core.EventID, handler string) error {
+	now := core.Now(l.Clock)
+	docID := l.docID(id, handler)
+	entry := Entry{EventID: id, Handler: handler, ClaimedAt: now}
+	rev, err := l.DB.Get(ctx, docID, &entry)
+	switch {
+	case errors.Is(err, corerepo.ErrNotFound):
+		entry.ProcessedAt = &now
+		_, err = l.DB.Insert(ctx, docID, entry)
+	case err != nil:
+	case entry.Processed():
+		return nil
+	default:
+		entry.ProcessedAt = &now
+		_, err = l.DB.Update(ctx, docID, rev, entry)
+	}
+	if err != nil && !errors.Is(err, corerepo.ErrConflict) {
+		return fmt.Errorf("ledger.CouchDBLedger.Record: %w", err)
+	}
+	return nil
+}
+
+func (l CouchDBLedger) Release(ctx context.Context, id core.EventID, handler string) error {
+	docID := l.docID(id, handler)
+	var entry Entry
+	rev, err := l.DB.Get(ctx, docID, &entry)
+	if err == nil && !entry.Processed() {
+		_, err = l.DB.Delete(ctx, docID, rev)
+	}
+	// A conflict means the event was claimed, or recorded, in the meantime
+	if errors.Is(err, corerepo.ErrNotFound) || errors.Is(err, corerepo.ErrConflict) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("ledger.CouchDBLedger.Release: %w", err)
+	}
+	return nil
+}
+
+// DeleteOlderThan deletes entries of events claimed more than age ago,
+// returning the number of entries deleted. Entries should be kept for as long
+// as the events may be delivered again, e.g., [DefaultRetention].
+func (l CouchDBLedger) DeleteOlderThan(ctx context.Context, age time.Duration) (int, error) {
+	before := core.Now(l.Clock).Add(-age)
+	q := corerepo.PrefixQuery(docPrefix)
+	q.Limit = corerepo.DefaultEventBatchSize
+	var count int
+	for {
+		res, err := corerepo.AllDocs[entryDoc](ctx, *l.DB, q)
+		if err != nil {
+			return count, fmt.Errorf("ledger.CouchDBLedger.DeleteOlderThan: %w", err)
+		}
+		var deleted []any
+		for _, doc := range res.Docs() {
+			if doc.ClaimedAt.Before(before) {
+				deleted = append(deleted, corerepo.DeletedDocument(doc.ID, doc.Rev))
+			}
+		}
+		if len(deleted) > 0 {
+			results, err := l.DB.BulkDocs(ctx, deleted...)
+			if err != nil {
+				return count, fmt.Errorf("ledger.CouchDBLedger.DeleteOlderThan: %w", err)
+			}
+			for _, r := range results {
+				if r.Err() == nil {
+					count++
+				}
+			}
+		}
+		if res.Next == nil {
+			return count, nil
+		}
+		q = *res.Next
+	}
+}
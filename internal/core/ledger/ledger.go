@@ -0,0 +1,191 @@
+// Package ledger makes side effects of event handlers idempotent.
+//
+// Events are delivered at least once; an event is delivered again if the
+// handler fails to mark it as published, e.g., if the process stops after
+// sending an email. A [Ledger] records which handlers have processed which
+// events, and [Once] skips the side effect of an event already processed by
+// the handler. Handlers opt in by wrapping side effects in [Once].
+//
+// Once claims the event by inserting a pending entry before the side effect,
+// so the side effect isn't repeated when the event is processed concurrently,
+// e.g., by another instance. If the process stops after the claim, the claim
+// expires after [ClaimDuration], and the side effect is done when the event is
+// delivered again. The side effect is therefore repeated if the process stops
+// after the side effect, and before it is recorded.
+//
+// Entries are kept until deleted, e.g., by [CouchDBLedger.DeleteOlderThan], so
+// they must be kept for as long as an event may be delivered again.
+package ledger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"harmony/internal/core"
+)
+
+// ClaimDuration is the time a pending claim prevents the event from being
+// claimed again. It should exceed the time it takes to process an event.
+const ClaimDuration = time.Minute
+
+// DefaultRetention is the default time entries are kept.
+const DefaultRetention = 30 * 24 * time.Hour
+
+// ErrClaimed is returned by [Once] when the event is being processed by the
+// handler elsewhere, i.e., claimed and not yet recorded as processed. The
+// event should be delivered again, as the claim expires if the other process
+// stops.
+var ErrClaimed = errors.New("ledger: event claimed")
+
+// Entry records that a handler has claimed, or processed, an event.
+type Entry struct {
+	EventID core.EventID `json:"event_id"`
+	Handler string       `json:"handler"`
+	// ClaimedAt is the time the handler claimed the event, before the side
+	// effect.
+	ClaimedAt time.Time `json:"claimed_at"`
+	// ProcessedAt is the time the side effect completed, or nil while the
+	// claim is pending.
+	ProcessedAt *time.Time `json:"processed_at,omitempty"`
+}
+
+// Processed returns whether the handler has processed the event.
+func (e Entry) Processed() bool { return e.ProcessedAt != nil }
+
+// Claimed returns whether the entry prevents a new claim at the time, i.e.,
+// the event is processed, or the pending claim hasn't expired.
+func (e Entry) Claimed(now time.Time) bool {
+	return e.Processed() || now.Before(e.ClaimedAt.Add(ClaimDuration))
+}
+
+// Ledger keeps the events processed by each handler. Implemented by
+// [MemoryLedger], and [CouchDBLedger].
+type Ledger interface {
+	// Processed returns whether the handler has processed the event.
+	Processed(ctx context.Context, id core.EventID, handler string) (bool, error)
+	// Claim claims the event for the handler before the side effect, unless
+	// already claimed, see [Entry.Claimed]. Returns whether the event was
+	// claimed.
+	Claim(ctx context.Context, id core.EventID, handler string) (bool, error)
+	// Record records that the handler has processed the event. Recording an
+	// event again is not an error.
+	Record(ctx context.Context, id core.EventID, handler string) error
+	// Release removes the claim of an event that was not processed, so it can
+	// be claimed again.
+	Release(ctx context.Context, id core.EventID, handler string) error
+}
+
+// Once calls fn, unless the handler has already processed the event, and
+// records that the event is processed when fn succeeds. The claim is released
+// if fn fails. If the event is claimed, and not yet processed, [ErrClaimed] is
+// returned without calling fn. If the ledger is nil, fn is always called.
+//
+// The handler name identifies the side effect, e.g., "auth.EmailValidator";
+// different handlers of the same event are recorded separately.
+func Once(
+	ctx context.Context,
+	l Ledger,
+	handler string,
+	event core.DomainEvent,
+	fn func(context.Context) error,
+) error {
+	if l == nil {
+		return fn(ctx)
+	}
+	claimed, err := l.Claim(ctx, event.ID, handler)
+	if err == nil && !claimed {
+		var processed bool
+		if processed, err = l.Processed(ctx, event.ID, handler); err == nil && !processed {
+			err = fmt.Errorf("%s: %w", event.ID, ErrClaimed)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("ledger.Once: %w", err)
+	}
+	if !claimed {
+		return nil
+	}
+	if err := fn(ctx); err != nil {
+		if releaseErr := l.Release(ctx, event.ID, handler); releaseErr != nil {
+			return errors.Join(err, fmt.Errorf("ledger.Once: %w", releaseErr))
+		}
+		return err
+	}
+	if err := l.Record(ctx, event.ID, handler); err != nil {
+		return fmt.Errorf("ledger.Once: %w", err)
+	}
+	return nil
+}
+
+type entryKey struct {
+	id      core.EventID
+	handler string
+}
+
+// MemoryLedger is a [Ledger] keeping entries in memory, for tests.
+type MemoryLedger struct {
+	// Clock sets the time entries are recorded, and decides when claims
+	// expire. The system clock is used if nil.
+	Clock core.Clock
+
+	mu      sync.Mutex
+	entries map[entryKey]Entry
+}
+
+func (l *MemoryLedger) Processed(
+	_ context.Context, id core.EventID, handler string,
+) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.entries[entryKey{id, handler}].Processed(), nil
+}
+
+func (l *MemoryLedger) Claim(
+	_ context.Context, id core.EventID, handler string,
+) (bool, error) {
+	now := core.Now(l.Clock)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	key := entryKey{id, handler}
+	if entry, ok := l.entries[key]; ok && entry.Claimed(now) {
+		return false, nil
+	}
+	if l.entries == nil {
+		l.entries = make(map[entryKey]Entry)
+	}
+	l.entries[key] = Entry{EventID: id, Handler: handler, ClaimedAt: now}
+	return true, nil
+}
+
+func (l *MemoryLedger) Record(_ context.Context, id core.EventID, handler string) error {
+	now := core.Now(l.Clock)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	key := entryKey{id, handler}
+	entry, ok := l.entries[key]
+	if entry.Processed() {
+		return nil
+	}
+	if !ok {
+		entry = Entry{EventID: id, Handler: handler, ClaimedAt: now}
+	}
+	entry.ProcessedAt = &now
+	if l.entries == nil {
+		l.entries = make(map[entryKey]Entry)
+	}
+	l.entries[key] = entry
+	return nil
+}
+
+func (l *MemoryLedger) Release(_ context.Context, id core.EventID, handler string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	key := entryKey{id, handler}
+	if !l.entries[key].Processed() {
+		delete(l.entries, key)
+	}
+	return nil
+}
@@ -0,0 +1,222 @@
+package ledger_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"harmony/internal/core"
+	"harmony/internal/core/corerepo"
+	"harmony/internal/core/ledger"
+	"harmony/internal/testing/clocktest"
+	_ "harmony/internal/testing/couchtest" // clear database before tests
+
+	"github.com/stretchr/testify/assert"
+)
+
+type ledgerTestEvent struct{}
+
+func init() {
+	core.RegisterEventType(reflect.TypeFor[ledgerTestEvent](), "ledger_test.Event")
+}
+
+func testLedgers(t *testing.T, test func(t *testing.T, l ledger.Ledger)) {
+	t.Run("MemoryLedger", func(t *testing.T) { test(t, &ledger.MemoryLedger{}) })
+	t.Run("CouchDBLedger", func(t *testing.T) {
+		corerepo.AssertInitialized()
+		test(t, ledger.CouchDBLedger{DB: &corerepo.DefaultConnection})
+	})
+}
+
+func TestLedger(t *testing.T) {
+	testLedgers(t, func(t *testing.T, l ledger.Ledger) {
+		ctx := t.Context()
+		id := core.NewDomainEvent(ledgerTestEvent{}).ID
+
+		processed, err := l.Processed(ctx, id, "a")
+		assert.NoError(t, err)
+		assert.False(t, processed, "Processed before recorded")
+
+		assert.NoError(t, l.Record(ctx, id, "a"))
+		assert.NoError(t, l.Record(ctx, id, "a"), "Record again")
+		processed, err = l.Processed(ctx, id, "a")
+		assert.NoError(t, err)
+		assert.True(t, processed, "Processed after recorded")
+
+		processed, err = l.Processed(ctx, id, "b")
+		assert.NoError(t, err)
+		assert.False(t, processed, "Processed by other handler")
+	})
+}
+
+func TestLedgerClaim(t *testing.T) {
+	testLedgers(t, func(t *testing.T, l ledger.Ledger) {
+		ctx := t.Context()
+		id := core.NewDomainEvent(ledgerTestEvent{}).ID
+
+		claimed, err := l.Claim(ctx, id, "a")
+		assert.NoError(t, err)
+		assert.True(t, claimed, "First claim")
+		claimed, err = l.Claim(ctx, id, "a")
+		assert.NoError(t, err)
+		assert.False(t, claimed, "Claimed again")
+		processed, err := l.Processed(ctx, id, "a")
+		assert.NoError(t, err)
+		assert.False(t, processed, "Claimed event processed")
+
+		assert.NoError(t, l.Release(ctx, id, "a"))
+		claimed, err = l.Claim(ctx, id, "a")
+		assert.NoError(t, err)
+		assert.True(t, claimed, "Claimed after release")
+
+		assert.NoError(t, l.Record(ctx, id, "a"))
+		assert.NoError(t, l.Release(ctx, id, "a"), "Release processed event")
+		claimed, err = l.Claim(ctx, id, "a")
+		assert.NoError(t, err)
+		assert.False(t, claimed, "Claimed after processed")
+	})
+}
+
+func TestLedgerClaimExpires(t *testing.T) {
+	for name, l := range map[string]func(core.Clock) ledger.Ledger{
+		"MemoryLedger": func(c core.Clock) ledger.Ledger { return &ledger.MemoryLedger{Clock: c} },
+		"CouchDBLedger": func(c core.Clock) ledger.Ledger {
+			return ledger.CouchDBLedger{DB: &corerepo.DefaultConnection, Clock: c}
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctx := t.Context()
+			clock := clocktest.New()
+			l := l(clock)
+			id := core.NewDomainEvent(ledgerTestEvent{}).ID
+
+			claimed, err := l.Claim(ctx, id, "a")
+			assert.NoError(t, err)
+			assert.True(t, claimed)
+
+			clock.Advance(ledger.ClaimDuration - time.Nanosecond)
+			claimed, err = l.Claim(ctx, id, "a")
+			assert.NoError(t, err)
+			assert.False(t, claimed, "Claimed before expiry")
+
+			clock.Advance(time.Nanosecond)
+			claimed, err = l.Claim(ctx, id, "a")
+			assert.NoError(t, err)
+			assert.True(t, claimed, "Claimed after expiry")
+		})
+	}
+}
+
+func TestCouchDBLedgerDeleteOlderThan(t *testing.T) {
+	corerepo.AssertInitialized()
+	ctx := t.Context()
+	clock := clocktest.New()
+	l := ledger.CouchDBLedger{DB: &corerepo.DefaultConnection, Clock: clock}
+	old := core.NewDomainEvent(ledgerTestEvent{}).ID
+	assert.NoError(t, l.Record(ctx, old, "a"))
+	clock.Advance(time.Hour)
+	recent := core.NewDomainEvent(ledgerTestEvent{}).ID
+	assert.NoError(t, l.Record(ctx, recent, "a"))
+
+	count, err := l.DeleteOlderThan(ctx, time.Minute)
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, count, 1)
+
+	processed, err := l.Processed(ctx, old, "a")
+	assert.NoError(t, err)
+	assert.False(t, processed, "Old entry deleted")
+	processed, err = l.Processed(ctx, recent, "a")
+	assert.NoError(t, err)
+	assert.True(t, processed, "Recent entry kept")
+}
+
+// faultyLedger fails the next call of Claim or Record, simulating a crash, or
+// lost connection to the database.
+type faultyLedger struct {
+	ledger.Ledger
+	failClaim, failRecord bool
+}
+
+var errCrash = errors.New("simulated crash")
+
+func (l *faultyLedger) Claim(ctx context.Context, id core.EventID, h string) (bool, error) {
+	if l.failClaim {
+		l.failClaim = false
+		return false, errCrash
+	}
+	return l.Ledger.Claim(ctx, id, h)
+}
+
+func (l *faultyLedger) Record(ctx context.Context, id core.EventID, h string) error {
+	if l.failRecord {
+		l.failRecord = false
+		return errCrash
+	}
+	return l.Ledger.Record(ctx, id, h)
+}
+
+func TestOnceRetriedAfterCrash(t *testing.T) {
+	for name, tc := range map[string]struct {
+		ledger    faultyLedger
+		failSend  bool
+		wantSends int
+	}{
+		"Crash before side effect": {ledger: faultyLedger{failClaim: true}, wantSends: 1},
+		"Side effect failed":       {failSend: true, wantSends: 2},
+		// The side effect and the record are not atomic; this case is
+		// repeated when the claim expires
+		"Crash before record": {ledger: faultyLedger{failRecord: true}, wantSends: 2},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctx := t.Context()
+			clock := clocktest.New()
+			l := tc.ledger
+			l.Ledger = &ledger.MemoryLedger{Clock: clock}
+			event := core.NewDomainEvent(ledgerTestEvent{})
+			var sends int
+			send := func(context.Context) error {
+				sends++
+				if tc.failSend && sends == 1 {
+					return errCrash
+				}
+				return nil
+			}
+
+			assert.ErrorIs(t, ledger.Once(ctx, &l, "handler", event, send), errCrash)
+			clock.Advance(ledger.ClaimDuration)
+			for range 2 {
+				assert.NoError(t, ledger.Once(ctx, &l, "handler", event, send), "Retry")
+			}
+			assert.Equal(t, tc.wantSends, sends)
+		})
+	}
+}
+
+func TestOnceSkipsEventProcessedConcurrently(t *testing.T) {
+	ctx := t.Context()
+	l := &ledger.MemoryLedger{}
+	event := core.NewDomainEvent(ledgerTestEvent{})
+	var sends int
+	send := func(context.Context) error { sends++; return nil }
+
+	err := ledger.Once(ctx, l, "handler", event, func(ctx context.Context) error {
+		assert.ErrorIs(t, ledger.Once(ctx, l, "handler", event, send), ledger.ErrClaimed,
+			"Processed while claimed")
+		return send(ctx)
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, ledger.Once(ctx, l, "handler", event, send), "Processed after recorded")
+	assert.Equal(t, 1, sends)
+}
+
+func TestOnceWithoutLedger(t *testing.T) {
+	var calls int
+	event := core.NewDomainEvent(ledgerTestEvent{})
+	for range 2 {
+		assert.NoError(t, ledger.Once(t.Context(), nil, "handler", event,
+			func(context.Context) error { calls++; return nil }))
+	}
+	assert.Equal(t, 2, calls)
+}
@@ -0,0 +1,101 @@
+package core
+
+import "context"
+
+// ActorSystem is the actor of events caused by the system itself, e.g., by
+// handlers processing other events, rather than by a user.
+const ActorSystem = "system"
+
+// EventMetadata describes what caused an event, and which aggregate it belongs
+// to.
+//
+// Events caused by the same HTTP request, and events caused by processing
+// those, have the same CorrelationID. CausationID is the ID of the event being
+// processed when the event was created. Actor is the ID of the authenticated
+// account, or [ActorSystem]. AggregateID is the ID of the entity that was the
+// source of the event, set by the repository storing it.
+type EventMetadata struct {
+	CorrelationID string `json:"correlation_id,omitempty"`
+	CausationID   string `json:"causation_id,omitempty"`
+	Actor         string `json:"actor,omitempty"`
+	AggregateID   string `json:"aggregate_id,omitempty"`
+}
+
+type contextKey string
+
+const ctxKeyEventMetadata contextKey = "core:event-metadata"
+
+// MetadataFromContext returns the metadata for events created in the context.
+func MetadataFromContext(ctx context.Context) EventMetadata {
+	m, _ := ctx.Value(ctxKeyEventMetadata).(EventMetadata)
+	return m
+}
+
+func withMetadata(ctx context.Context, m EventMetadata) context.Context {
+	return context.WithValue(ctx, ctxKeyEventMetadata, m)
+}
+
+// WithCorrelationID returns a context where new events have the correlation
+// ID, typically the ID of an HTTP request.
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	m := MetadataFromContext(ctx)
+	m.CorrelationID = id
+	return withMetadata(ctx, m)
+}
+
+// WithActor returns a context where new events have the actor, i.e., the ID of
+// the authenticated account, or [ActorSystem].
+func WithActor(ctx context.Context, actor string) context.Context {
+	m := MetadataFromContext(ctx)
+	m.Actor = actor
+	return withMetadata(ctx, m)
+}
+
+// WithAggregateID returns a context for storing events of the aggregate with
+// the ID.
+func WithAggregateID(ctx context.Context, id string) context.Context {
+	m := MetadataFromContext(ctx)
+	m.AggregateID = id
+	return withMetadata(ctx, m)
+}
+
+// WithCausingEvent returns a context for processing the event. New events are
+// caused by the event, have the same correlation ID, and [ActorSystem] as
+// actor.
+func WithCausingEvent(ctx context.Context, event DomainEvent) context.Context {
+	correlationID := event.Metadata.CorrelationID
+	if correlationID == "" {
+		correlationID = string(event.ID)
+	}
+	return withMetadata(ctx, EventMetadata{
+		CorrelationID: correlationID,
+		CausationID:   string(event.ID),
+		Actor:         ActorSystem,
+	})
+}
+
+// WithEventMetadata returns a copy of events where missing metadata is set
+// from the context. Repositories call this when storing events.
+func WithEventMetadata(ctx context.Context, events []DomainEvent) []DomainEvent {
+	if len(events) == 0 {
+		return events
+	}
+	m := MetadataFromContext(ctx)
+	res := make([]DomainEvent, len(events))
+	for i, e := range events {
+		if e.Metadata.CorrelationID == "" {
+			e.Metadata.CorrelationID = m.CorrelationID
+		}
+		if e.Metadata.CausationID == "" {
+			e.Metadata.CausationID = m.CausationID
+		}
+		if e.Metadata.Actor == "" {
+			e.Metadata.Actor = m.Actor
+		}
+		if e.Metadata.AggregateID == "" {
+			e.Metadata.AggregateID = m.AggregateID
+		}
+		res[i] = e
+	}
+	return res
+}
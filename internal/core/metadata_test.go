@@ -0,0 +1,35 @@
+package core_test
+
+import (
+	"testing"
+
+	"harmony/internal/core"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithEventMetadataKeepsExistingMetadata(t *testing.T) {
+	ctx := core.WithActor(core.WithCorrelationID(t.Context(), "req-1"), "acc-1")
+	existing := core.NewDomainEvent(renamedEvent{})
+	existing.Metadata.CorrelationID = "req-0"
+	events := []core.DomainEvent{core.NewDomainEvent(renamedEvent{}), existing}
+
+	res := core.WithEventMetadata(ctx, events)
+	assert.Equal(t, core.EventMetadata{CorrelationID: "req-1", Actor: "acc-1"}, res[0].Metadata)
+	assert.Equal(t, core.EventMetadata{CorrelationID: "req-0", Actor: "acc-1"}, res[1].Metadata)
+	assert.Empty(t, events[0].Metadata, "Input is not modified")
+}
+
+func TestWithCausingEvent(t *testing.T) {
+	event := core.NewDomainEvent(renamedEvent{})
+	ctx := core.WithCausingEvent(t.Context(), event)
+	assert.Equal(t, core.EventMetadata{
+		CorrelationID: string(event.ID),
+		CausationID:   string(event.ID),
+		Actor:         core.ActorSystem,
+	}, core.MetadataFromContext(ctx), "Event without correlation ID starts a new correlation")
+
+	event.Metadata.CorrelationID = "req-1"
+	ctx = core.WithCausingEvent(t.Context(), event)
+	assert.Equal(t, "req-1", core.MetadataFromContext(ctx).CorrelationID)
+}
@@ -0,0 +1,226 @@
+// Package projection builds read models, i.e., views of data optimised for
+// queries, from domain events.
+//
+// A [Projection] subscribes to event types, and updates its state as events
+// are applied. A [Runner] delivers events to the projection, keeping track of
+// the last applied event in a checkpoint, and can rebuild the projection from
+// scratch from the event store.
+package projection
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"harmony/internal/core"
+	"harmony/internal/core/corerepo"
+	"harmony/internal/infrastructure/log"
+)
+
+// Projection builds a read model from domain events.
+type Projection interface {
+	// Name identifies the projection, e.g., "host.account_activity". The name
+	// is used to store the checkpoint.
+	Name() string
+	// EventTypes returns the registered names of the event types the
+	// projection subscribes to.
+	EventTypes() []string
+	// Apply updates the read model with the event.
+	Apply(context.Context, core.DomainEvent) error
+	// Reset removes all state, before the projection is rebuilt.
+	Reset(context.Context) error
+}
+
+// Checkpoint identifies the last event applied to a projection, and the events
+// applied within the window before it.
+type Checkpoint struct {
+	EventID   core.EventID `json:"event_id"`
+	CreatedAt time.Time    `json:"created_at"`
+	// Applied contains the creation time of the events applied within the
+	// window before CreatedAt, by event ID. Events created in that window may
+	// still be delivered, e.g., events stored by another instance, or events
+	// the projection failed to apply.
+	Applied map[core.EventID]time.Time `json:"applied,omitempty"`
+}
+
+// before returns whether the checkpoint is before the event. Events created at
+// the same time are ordered by ID, like the views of the event store.
+func (c Checkpoint) before(e core.DomainEvent) bool {
+	if c.CreatedAt.Equal(e.CreatedAt) {
+		return c.EventID < e.ID
+	}
+	return c.CreatedAt.Before(e.CreatedAt)
+}
+
+// windowStart returns the creation time of the oldest event that may not have
+// been applied.
+func (c Checkpoint) windowStart(window time.Duration) time.Time {
+	if c.CreatedAt.IsZero() {
+		return time.Time{}
+	}
+	return c.CreatedAt.Add(-window)
+}
+
+// applied returns whether the event has been applied. Events created before
+// the window are assumed to be applied.
+func (c Checkpoint) applied(e core.DomainEvent, window time.Duration) bool {
+	if _, ok := c.Applied[e.ID]; ok {
+		return true
+	}
+	return e.CreatedAt.Before(c.windowStart(window))
+}
+
+// add returns the checkpoint after applying the event, forgetting the events
+// that are no longer within the window.
+func (c Checkpoint) add(e core.DomainEvent, window time.Duration) Checkpoint {
+	res := c
+	if c.before(e) {
+		res.EventID, res.CreatedAt = e.ID, e.CreatedAt
+	}
+	res.Applied = make(map[core.EventID]time.Time, len(c.Applied)+1)
+	start := res.windowStart(window)
+	for id, createdAt := range c.Applied {
+		if !createdAt.Before(start) {
+			res.Applied[id] = createdAt
+		}
+	}
+	res.Applied[e.ID] = e.CreatedAt
+	return res
+}
+
+// CheckpointStore keeps the checkpoints of projections.
+type CheckpointStore interface {
+	// Checkpoint returns the checkpoint of the projection, or the zero value
+	// if no events have been applied.
+	Checkpoint(ctx context.Context, name string) (Checkpoint, error)
+	SaveCheckpoint(ctx context.Context, name string, cp Checkpoint) error
+}
+
+// EventReader reads historical events, implemented by
+// [corerepo.DomainEventRepository].
+type EventReader interface {
+	EachEvent(context.Context, corerepo.EventQuery, func(core.DomainEvent) error) error
+}
+
+// DefaultWindow is the default time before the last applied event, in which
+// the [Runner] keeps track of applied events.
+const DefaultWindow = 5 * time.Minute
+
+// Runner delivers events to a projection. Live events are delivered by
+// [Runner.ProcessDomainEvent], e.g., from the message pump, and events stored
+// while the application was not running, or that failed to apply, are applied
+// by [Runner.CatchUp].
+//
+// Events may be delivered out of order, e.g., when another instance stores an
+// event created before the last applied event. The checkpoint keeps track of
+// the events applied within the Window before the last applied event, so each
+// event is applied once, and CatchUp starts from the beginning of the window.
+// Events created before the window are assumed to be applied; an event
+// delivered later is ignored, until the projection is rebuilt.
+type Runner struct {
+	Projection  Projection
+	Checkpoints CheckpointStore
+	Events      EventReader
+	// Window is the time before the last applied event in which events may
+	// arrive out of order. [DefaultWindow] is used if zero.
+	Window time.Duration
+
+	mu sync.Mutex
+}
+
+func NewRunner(p Projection, checkpoints CheckpointStore, events EventReader) *Runner {
+	return &Runner{Projection: p, Checkpoints: checkpoints, Events: events}
+}
+
+func (r *Runner) window() time.Duration { return cmp.Or(r.Window, DefaultWindow) }
+
+func (r *Runner) subscribes(e core.DomainEvent) bool {
+	return slices.Contains(r.Projection.EventTypes(), core.EventTypeName(e))
+}
+
+// apply applies the event if the projection subscribes to it, and it hasn't
+// been applied, returning whether it was applied. The caller must hold the
+// lock.
+func (r *Runner) apply(ctx context.Context, cp *Checkpoint, e core.DomainEvent) (bool, error) {
+	if !r.subscribes(e) || cp.applied(e, r.window()) {
+		return false, nil
+	}
+	name := r.Projection.Name()
+	if err := r.Projection.Apply(ctx, e); err != nil {
+		return false, fmt.Errorf("projection %s: apply %s: %w", name, e.ID, err)
+	}
+	*cp = cp.add(e, r.window())
+	if err := r.Checkpoints.SaveCheckpoint(ctx, name, *cp); err != nil {
+		return true, fmt.Errorf("projection %s: save checkpoint: %w", name, err)
+	}
+	return true, nil
+}
+
+// ProcessDomainEvent implements [core.EventHandler], applying a live event.
+func (r *Runner) ProcessDomainEvent(ctx context.Context, e core.DomainEvent) error {
+	if !r.subscribes(e) {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cp, err := r.Checkpoints.Checkpoint(ctx, r.Projection.Name())
+	if err != nil {
+		return fmt.Errorf("projection %s: load checkpoint: %w", r.Projection.Name(), err)
+	}
+	_, err = r.apply(ctx, &cp, e)
+	return err
+}
+
+// CatchUp applies the stored events that haven't been applied, from the
+// beginning of the window before the checkpoint.
+func (r *Runner) CatchUp(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.catchUp(ctx)
+}
+
+func (r *Runner) catchUp(ctx context.Context) error {
+	name := r.Projection.Name()
+	cp, err := r.Checkpoints.Checkpoint(ctx, name)
+	if err != nil {
+		return fmt.Errorf("projection %s: load checkpoint: %w", name, err)
+	}
+	var applied int
+	var errs []error
+	err = r.Events.EachEvent(ctx, corerepo.EventQuery{From: cp.windowStart(r.window())},
+		func(e core.DomainEvent) error {
+			// An event that fails to apply doesn't stop the catch up; it is
+			// applied by the next catch up, while within the window.
+			ok, err := r.apply(ctx, &cp, e)
+			if ok {
+				applied++
+			}
+			if err != nil {
+				errs = append(errs, err)
+			}
+			return nil
+		},
+	)
+	if applied > 0 {
+		log.Info(ctx, "projection: caught up", "name", name, "applied", applied)
+	}
+	return errors.Join(append(errs, err)...)
+}
+
+// Rebuild removes the state of the projection, and applies all stored events.
+func (r *Runner) Rebuild(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	name := r.Projection.Name()
+	if err := r.Projection.Reset(ctx); err != nil {
+		return fmt.Errorf("projection %s: reset: %w", name, err)
+	}
+	if err := r.Checkpoints.SaveCheckpoint(ctx, name, Checkpoint{}); err != nil {
+		return fmt.Errorf("projection %s: reset checkpoint: %w", name, err)
+	}
+	return r.catchUp(ctx)
+}
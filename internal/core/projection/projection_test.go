@@ -0,0 +1,195 @@
+package projection_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"harmony/internal/core"
+	"harmony/internal/core/corerepo"
+	"harmony/internal/core/projection"
+
+	gonanoid "github.com/matoous/go-nanoid/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+type countedEvent struct{ Key string }
+type ignoredEvent struct{ Key string }
+
+func init() {
+	core.RegisterEventType(reflect.TypeFor[countedEvent](), "projection_test.Counted")
+	core.RegisterEventType(reflect.TypeFor[ignoredEvent](), "projection_test.Ignored")
+}
+
+// counter counts events of each key.
+type counter struct{ store projection.Store[int] }
+
+func (counter) Name() string         { return "projection_test.counter" }
+func (counter) EventTypes() []string { return []string{"projection_test.Counted"} }
+
+func (c counter) Apply(ctx context.Context, e core.DomainEvent) error {
+	key := e.Body.(countedEvent).Key
+	n, _ := c.store.Get(ctx, key)
+	return c.store.Put(ctx, key, n+1)
+}
+
+func (c counter) Reset(ctx context.Context) error { return c.store.Reset(ctx) }
+
+// eventLog is an [projection.EventReader] of events in memory.
+type eventLog []core.DomainEvent
+
+func (l eventLog) EachEvent(
+	_ context.Context,
+	q corerepo.EventQuery,
+	fn func(core.DomainEvent) error,
+) error {
+	for _, e := range l {
+		if !e.CreatedAt.Before(q.From) {
+			if err := fn(e); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func newEvents(bodies ...core.EventBody) eventLog {
+	start := time.Now().UTC()
+	res := make(eventLog, len(bodies))
+	for i, b := range bodies {
+		res[i] = core.NewDomainEvent(b)
+		res[i].CreatedAt = start.Add(time.Duration(i) * time.Second)
+	}
+	return res
+}
+
+func TestRunnerAppliesEachEventOnce(t *testing.T) {
+	ctx := t.Context()
+	store := projection.NewMemoryStore[int]()
+	events := newEvents(countedEvent{"a"}, ignoredEvent{"a"}, countedEvent{"a"}, countedEvent{"b"})
+	runner := projection.NewRunner(counter{store}, &projection.MemoryCheckpoints{}, events[:2])
+
+	assert.NoError(t, runner.CatchUp(ctx))
+	for _, e := range events {
+		assert.NoError(t, runner.ProcessDomainEvent(ctx, e))
+	}
+	assert.NoError(t, runner.ProcessDomainEvent(ctx, events[0]), "Redelivered event")
+
+	a, _ := store.Get(ctx, "a")
+	b, _ := store.Get(ctx, "b")
+	assert.Equal(t, 2, a)
+	assert.Equal(t, 1, b)
+
+	cp, err := runner.Checkpoints.Checkpoint(ctx, "projection_test.counter")
+	assert.NoError(t, err)
+	assert.Equal(t, events[3].ID, cp.EventID)
+}
+
+func TestRunnerAppliesLateEventsWithinWindow(t *testing.T) {
+	ctx := t.Context()
+	store := projection.NewMemoryStore[int]()
+	events := newEvents(countedEvent{"old"}, countedEvent{"a"}, countedEvent{"b"})
+	events[0].CreatedAt = events[2].CreatedAt.Add(-projection.DefaultWindow - time.Second)
+	runner := projection.NewRunner(counter{store}, &projection.MemoryCheckpoints{}, nil)
+
+	assert.NoError(t, runner.ProcessDomainEvent(ctx, events[2]))
+	assert.NoError(t, runner.ProcessDomainEvent(ctx, events[1]), "Event created before checkpoint")
+	assert.NoError(t, runner.ProcessDomainEvent(ctx, events[1]), "Redelivered event")
+	assert.NoError(t, runner.ProcessDomainEvent(ctx, events[0]), "Event before window")
+
+	a, _ := store.Get(ctx, "a")
+	assert.Equal(t, 1, a, "Late event applied once")
+	_, err := store.Get(ctx, "old")
+	assert.ErrorIs(t, err, core.ErrNotFound, "Event before window ignored")
+}
+
+// failingCounter fails to apply events while failing is true.
+type failingCounter struct {
+	counter
+	failing *bool
+}
+
+func (c failingCounter) Apply(ctx context.Context, e core.DomainEvent) error {
+	if *c.failing {
+		return errors.New("simulated failure")
+	}
+	return c.counter.Apply(ctx, e)
+}
+
+func TestRunnerCatchUpAppliesFailedEvents(t *testing.T) {
+	ctx := t.Context()
+	store := projection.NewMemoryStore[int]()
+	events := newEvents(countedEvent{"a"}, countedEvent{"b"})
+	failing := true
+	runner := projection.NewRunner(
+		failingCounter{counter{store}, &failing}, &projection.MemoryCheckpoints{}, events,
+	)
+
+	assert.Error(t, runner.ProcessDomainEvent(ctx, events[0]), "Failure is returned")
+	failing = false
+	assert.NoError(t, runner.ProcessDomainEvent(ctx, events[1]))
+	assert.NoError(t, runner.CatchUp(ctx))
+
+	a, _ := store.Get(ctx, "a")
+	b, _ := store.Get(ctx, "b")
+	assert.Equal(t, 1, a, "Failed event applied by catch up")
+	assert.Equal(t, 1, b)
+}
+
+func TestRunnerRebuild(t *testing.T) {
+	ctx := t.Context()
+	store := projection.NewMemoryStore[int]()
+	events := newEvents(countedEvent{"a"}, countedEvent{"a"})
+	runner := projection.NewRunner(counter{store}, &projection.MemoryCheckpoints{}, events)
+	assert.NoError(t, runner.CatchUp(ctx))
+	assert.NoError(t, store.Put(ctx, "a", 42))
+	assert.NoError(t, store.Put(ctx, "stale", 1))
+
+	assert.NoError(t, runner.Rebuild(ctx))
+	a, _ := store.Get(ctx, "a")
+	assert.Equal(t, 2, a)
+	_, err := store.Get(ctx, "stale")
+	assert.ErrorIs(t, err, core.ErrNotFound)
+}
+
+func TestCouchDBStore(t *testing.T) {
+	ctx := t.Context()
+	store := projection.NewCouchDBStore[int](&corerepo.DefaultConnection, gonanoid.Must())
+
+	_, err := store.Get(ctx, "a")
+	assert.ErrorIs(t, err, core.ErrNotFound)
+	assert.NoError(t, store.Put(ctx, "a", 1))
+	assert.NoError(t, store.Put(ctx, "a", 2))
+	assert.NoError(t, store.Put(ctx, "b", 3))
+	a, err := store.Get(ctx, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, a)
+
+	assert.NoError(t, store.Delete(ctx, "b"))
+	_, err = store.Get(ctx, "b")
+	assert.ErrorIs(t, err, core.ErrNotFound)
+
+	assert.NoError(t, store.Reset(ctx))
+	_, err = store.Get(ctx, "a")
+	assert.ErrorIs(t, err, core.ErrNotFound)
+}
+
+func TestCouchDBCheckpoints(t *testing.T) {
+	ctx := t.Context()
+	checkpoints := projection.CouchDBCheckpoints{DB: &corerepo.DefaultConnection}
+	name := gonanoid.Must()
+
+	cp, err := checkpoints.Checkpoint(ctx, name)
+	assert.NoError(t, err)
+	assert.Zero(t, cp)
+
+	expected := projection.Checkpoint{EventID: "1", CreatedAt: time.Now().UTC()}
+	assert.NoError(t, checkpoints.SaveCheckpoint(ctx, name, projection.Checkpoint{EventID: "0"}))
+	assert.NoError(t, checkpoints.SaveCheckpoint(ctx, name, expected))
+	cp, err = checkpoints.Checkpoint(ctx, name)
+	assert.NoError(t, err)
+	assert.Equal(t, expected.EventID, cp.EventID)
+	assert.True(t, expected.CreatedAt.Equal(cp.CreatedAt))
+}
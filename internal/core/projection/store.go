@@ -0,0 +1,188 @@
+package projection
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"harmony/internal/core"
+	"harmony/internal/core/corerepo"
+)
+
+// Store keeps the state of a projection, a value of type S for each key, e.g.,
+// a summary for each account.
+type Store[S any] interface {
+	// Get returns the state for the key, or [core.ErrNotFound].
+	Get(ctx context.Context, key string) (S, error)
+	Put(ctx context.Context, key string, state S) error
+	Delete(ctx context.Context, key string) error
+	// Reset removes the state for all keys.
+	Reset(ctx context.Context) error
+}
+
+// MemoryStore is a [Store] keeping state in memory. The state must be rebuilt
+// when the application starts, e.g., by a [Runner] using
+// [MemoryCheckpoints].
+type MemoryStore[S any] struct {
+	mu    sync.Mutex
+	state map[string]S
+}
+
+func NewMemoryStore[S any]() *MemoryStore[S] {
+	return &MemoryStore[S]{state: make(map[string]S)}
+}
+
+func (s *MemoryStore[S]) Get(_ context.Context, key string) (S, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res, ok := s.state[key]
+	if !ok {
+		return res, core.ErrNotFound
+	}
+	return res, nil
+}
+
+func (s *MemoryStore[S]) Put(_ context.Context, key string, state S) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state[key] = state
+	return nil
+}
+
+func (s *MemoryStore[S]) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.state, key)
+	return nil
+}
+
+func (s *MemoryStore[S]) Reset(context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	clear(s.state)
+	return nil
+}
+
+// MemoryCheckpoints is a [CheckpointStore] keeping checkpoints in memory.
+type MemoryCheckpoints struct {
+	mu          sync.Mutex
+	checkpoints map[string]Checkpoint
+}
+
+func (s *MemoryCheckpoints) Checkpoint(_ context.Context, name string) (Checkpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.checkpoints[name], nil
+}
+
+func (s *MemoryCheckpoints) SaveCheckpoint(_ context.Context, name string, cp Checkpoint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.checkpoints == nil {
+		s.checkpoints = make(map[string]Checkpoint)
+	}
+	s.checkpoints[name] = cp
+	return nil
+}
+
+// CouchDBStore is a [Store] keeping the state for each key in a document with
+// the ID "projection:<name>:<key>".
+type CouchDBStore[S any] struct {
+	DB   *corerepo.Connection
+	Name string
+}
+
+func NewCouchDBStore[S any](db *corerepo.Connection, name string) CouchDBStore[S] {
+	return CouchDBStore[S]{DB: db, Name: name}
+}
+
+type stateDoc[S any] struct {
+	State S `json:"state"`
+}
+
+func (s CouchDBStore[S]) prefix() string        { return fmt.Sprintf("projection:%s:", s.Name) }
+func (s CouchDBStore[S]) docID(k string) string { return s.prefix() + k }
+
+func (s CouchDBStore[S]) Get(ctx context.Context, key string) (S, error) {
+	var doc stateDoc[S]
+	_, err := s.DB.Get(ctx, s.docID(key), &doc)
+	return doc.State, err
+}
+
+func (s CouchDBStore[S]) Put(ctx context.Context, key string, state S) error {
+	id := s.docID(key)
+	var existing json.RawMessage
+	rev, err := s.DB.Get(ctx, id, &existing)
+	switch {
+	case errors.Is(err, corerepo.ErrNotFound):
+		_, err = s.DB.Insert(ctx, id, stateDoc[S]{state})
+	case err == nil:
+		_, err = s.DB.Update(ctx, id, rev, stateDoc[S]{state})
+	}
+	return err
+}
+
+func (s CouchDBStore[S]) Delete(ctx context.Context, key string) error {
+	return s.DB.DeleteIfExists(ctx, s.docID(key))
+}
+
+// Reset deletes the documents of all keys.
+func (s CouchDBStore[S]) Reset(ctx context.Context) error {
+	q := corerepo.PrefixQuery(s.prefix())
+	q.Limit = corerepo.DefaultMigrationBatchSize
+	for {
+		res, err := corerepo.AllDocs[struct {
+			ID  string `json:"_id"`
+			Rev string `json:"_rev"`
+		}](ctx, *s.DB, q)
+		if err != nil {
+			return err
+		}
+		deleted := make([]any, len(res.Rows))
+		for i, doc := range res.Docs() {
+			deleted[i] = corerepo.DeletedDocument(doc.ID, doc.Rev)
+		}
+		if len(deleted) > 0 {
+			results, err := s.DB.BulkDocs(ctx, deleted...)
+			if err = errors.Join(err, corerepo.BulkErr(results)); err != nil {
+				return err
+			}
+		}
+		if res.Next == nil {
+			return nil
+		}
+		q = *res.Next
+	}
+}
+
+// CouchDBCheckpoints is a [CheckpointStore] keeping checkpoints in local
+// documents, which are not replicated.
+type CouchDBCheckpoints struct {
+	DB *corerepo.Connection
+}
+
+func (s CouchDBCheckpoints) docID(name string) string { return "_local/projection:" + name }
+
+func (s CouchDBCheckpoints) Checkpoint(ctx context.Context, name string) (Checkpoint, error) {
+	var cp Checkpoint
+	_, err := s.DB.Get(ctx, s.docID(name), &cp)
+	if errors.Is(err, corerepo.ErrNotFound) {
+		err = nil
+	}
+	return cp, err
+}
+
+func (s CouchDBCheckpoints) SaveCheckpoint(ctx context.Context, name string, cp Checkpoint) error {
+	id := s.docID(name)
+	var existing json.RawMessage
+	rev, err := s.DB.Get(ctx, id, &existing)
+	switch {
+	case errors.Is(err, corerepo.ErrNotFound):
+		_, err = s.DB.Insert(ctx, id, cp)
+	case err == nil:
+		_, err = s.DB.Update(ctx, id, rev, cp)
+	}
+	return err
+}
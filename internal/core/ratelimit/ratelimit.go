@@ -0,0 +1,73 @@
+// Package ratelimit limits how often something can happen, e.g., guessing a
+// code sent by email.
+//
+// State is kept in memory, so each instance of the server limits separately.
+package ratelimit
+
+import (
+	"sync"
+	"time"
+
+	"harmony/internal/core"
+)
+
+// Limiter allows up to Limit events for each key in a fixed window of time.
+// The zero value is not usable; create a Limiter with [New]. A Limiter is safe
+// for concurrent use.
+type Limiter struct {
+	Limit  int
+	Window time.Duration
+	Clock  core.Clock
+
+	mu        sync.Mutex
+	windows   map[string]window
+	lastPrune time.Time
+}
+
+type window struct {
+	start time.Time
+	count int
+}
+
+// New returns a Limiter allowing limit events per key in each window.
+func New(limit int, window time.Duration) *Limiter {
+	return &Limiter{Limit: limit, Window: window}
+}
+
+// Allow records an event for the key, and returns whether it is within the
+// limit. Events rejected still count, so a client retrying in a loop stays
+// limited until the window has passed.
+func (l *Limiter) Allow(key string) bool {
+	now := core.Now(l.Clock)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.windows == nil {
+		l.windows = make(map[string]window)
+	}
+	l.prune(now)
+	w, ok := l.windows[key]
+	if !ok || l.expired(w, now) {
+		w = window{start: now}
+	}
+	w.count++
+	l.windows[key] = w
+	return w.count <= l.Limit
+}
+
+func (l *Limiter) expired(w window, now time.Time) bool {
+	return !now.Before(w.start.Add(l.Window))
+}
+
+// prune removes expired windows once per window, keeping memory bounded by the
+// number of keys seen within two windows.
+func (l *Limiter) prune(now time.Time) {
+	if now.Sub(l.lastPrune) < l.Window {
+		return
+	}
+	l.lastPrune = now
+	for key, w := range l.windows {
+		if l.expired(w, now) {
+			delete(l.windows, key)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package ratelimit_test
+
+import (
+	"testing"
+	"time"
+
+	"harmony/internal/core/ratelimit"
+	"harmony/internal/testing/clocktest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiter(t *testing.T) {
+	clock := clocktest.New()
+	l := ratelimit.New(2, time.Minute)
+	l.Clock = clock
+
+	assert.True(t, l.Allow("a"))
+	assert.True(t, l.Allow("a"))
+	assert.False(t, l.Allow("a"), "Third event in the window")
+	assert.True(t, l.Allow("b"), "Other keys are limited separately")
+
+	clock.Advance(59 * time.Second)
+	assert.False(t, l.Allow("a"), "Before the window has passed")
+
+	clock.Advance(time.Second)
+	assert.True(t, l.Allow("a"), "After the window has passed")
+}
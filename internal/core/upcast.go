@@ -0,0 +1,69 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Upcaster transforms the JSON body of an event from one schema version to the
+// next.
+type Upcaster func(body json.RawMessage) (json.RawMessage, error)
+
+// upcasters contains the upcasters of each event type name, where the
+// upcaster at index i transforms version i+1 to version i+2.
+var upcasters = make(map[string][]Upcaster)
+
+// currentVersion returns the schema version new events of the type are stored
+// with.
+func currentVersion(name string) int { return len(upcasters[name]) + 1 }
+
+// RegisterEventUpcaster registers an upcaster transforming events of the type
+// with the registered name from version fromVersion to fromVersion+1, making
+// fromVersion+1 the current version of the type. Events stored without a
+// version have version 1.
+//
+// When changing the JSON shape of an event type, e.g., renaming a field,
+// register an upcaster transforming existing events to the new shape.
+// Upcasters must be registered in order of version, after the type.
+func RegisterEventUpcaster(name string, fromVersion int, upcaster Upcaster) {
+	if names[name] == nil {
+		panic(fmt.Sprintf("domain: RegisterEventUpcaster: unknown type: %s", name))
+	}
+	if current := currentVersion(name); fromVersion != current {
+		panic(fmt.Sprintf(
+			"domain: RegisterEventUpcaster: %s: expected upcaster from version %d, got %d",
+			name, current, fromVersion,
+		))
+	}
+	upcasters[name] = append(upcasters[name], upcaster)
+}
+
+// upcast transforms the body of an event of the type with the registered name
+// from version to the current version.
+func upcast(name string, version int, body json.RawMessage) (json.RawMessage, error) {
+	for v, upcaster := range upcasters[name][version-1:] {
+		var err error
+		if body, err = upcaster(body); err != nil {
+			return nil, fmt.Errorf("upcast from version %d: %w", version+v, err)
+		}
+	}
+	return body, nil
+}
+
+// RenameFields returns an upcaster renaming fields of the JSON body, mapping
+// old field names to new.
+func RenameFields(renames map[string]string) Upcaster {
+	return func(body json.RawMessage) (json.RawMessage, error) {
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(body, &fields); err != nil {
+			return nil, err
+		}
+		for from, to := range renames {
+			if value, ok := fields[from]; ok {
+				delete(fields, from)
+				fields[to] = value
+			}
+		}
+		return json.Marshal(fields)
+	}
+}
@@ -0,0 +1,56 @@
+package core_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"harmony/internal/core"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// renamedEvent is a test-only event type demonstrating upcasting. It is at
+// version 3 of the schema; version 1 had the field "Name", and version 2
+// "name". Each rename is registered as an upcaster from the previous version.
+type renamedEvent struct {
+	FullName string `json:"full_name"`
+}
+
+func init() {
+	core.RegisterEventType(reflect.TypeFor[renamedEvent](), "core_test.Renamed")
+	core.RegisterEventUpcaster("core_test.Renamed", 1,
+		core.RenameFields(map[string]string{"Name": "name"}))
+	core.RegisterEventUpcaster("core_test.Renamed", 2,
+		core.RenameFields(map[string]string{"name": "full_name"}))
+}
+
+func TestEventIsStoredWithCurrentVersion(t *testing.T) {
+	data, err := json.Marshal(core.NewDomainEvent(renamedEvent{FullName: "John Smith"}))
+	assert.NoError(t, err)
+	var raw map[string]any
+	assert.NoError(t, json.Unmarshal(data, &raw))
+	assert.Equal(t, float64(3), raw["version"])
+
+	var event core.DomainEvent
+	assert.NoError(t, json.Unmarshal(data, &event))
+	assert.Equal(t, renamedEvent{FullName: "John Smith"}, event.Body)
+}
+
+func TestOldEventVersionsAreUpcast(t *testing.T) {
+	for _, data := range []string{
+		`{"id":"1","type":"core_test.Renamed","Body":{"Name":"John Smith"}}`,
+		`{"id":"1","type":"core_test.Renamed","version":1,"Body":{"Name":"John Smith"}}`,
+		`{"id":"1","type":"core_test.Renamed","version":2,"Body":{"name":"John Smith"}}`,
+	} {
+		var event core.DomainEvent
+		assert.NoError(t, json.Unmarshal([]byte(data), &event))
+		assert.Equal(t, renamedEvent{FullName: "John Smith"}, event.Body, data)
+	}
+}
+
+func TestRegisterEventUpcasterOutOfOrder(t *testing.T) {
+	assert.Panics(t, func() {
+		core.RegisterEventUpcaster("core_test.Renamed", 1, core.RenameFields(nil))
+	})
+}
@@ -0,0 +1,99 @@
+// Package activity contains a projection summarising the activity of each
+// account, shown to the user on the host page.
+package activity
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"harmony/internal/auth/domain"
+	"harmony/internal/core"
+	"harmony/internal/core/projection"
+)
+
+// Summary describes the activity of an account.
+type Summary struct {
+	AccountID    domain.AccountID `json:"account_id"`
+	RegisteredAt time.Time        `json:"registered_at,omitzero"`
+	// EmailValidationRequests is the number of times an email validation code
+	// was sent.
+	EmailValidationRequests int `json:"email_validation_requests"`
+	// AdminActions is the number of times an administrator changed the
+	// account, e.g., locking it.
+	AdminActions int       `json:"admin_actions"`
+	LastActivity time.Time `json:"last_activity"`
+}
+
+// Projection builds a [Summary] for each account from the events of the auth
+// context.
+type Projection struct {
+	Store projection.Store[Summary]
+}
+
+func New(store projection.Store[Summary]) Projection { return Projection{store} }
+
+func (Projection) Name() string { return "host.account_activity" }
+
+func (Projection) EventTypes() []string {
+	return []string{
+		core.EventTypeNameOf[domain.AccountRegistered](),
+		core.EventTypeNameOf[domain.EmailValidationRequest](),
+		core.EventTypeNameOf[domain.EmailVerifiedByAdmin](),
+		core.EventTypeNameOf[domain.AccountLocked](),
+		core.EventTypeNameOf[domain.AccountUnlocked](),
+		core.EventTypeNameOf[domain.PasswordResetForced](),
+		core.EventTypeNameOf[domain.AccountDeleted](),
+		core.EventTypeNameOf[domain.AccountRegistrationAbandoned](),
+	}
+}
+
+func (p Projection) Apply(ctx context.Context, e core.DomainEvent) error {
+	var id domain.AccountID
+	update := func(s *Summary) {}
+	switch body := e.Body.(type) {
+	case domain.AccountRegistered:
+		id = body.AccountID
+		update = func(s *Summary) { s.RegisteredAt = e.CreatedAt }
+	case domain.EmailValidationRequest:
+		id = body.AccountID
+		update = func(s *Summary) { s.EmailValidationRequests++ }
+	case domain.EmailVerifiedByAdmin:
+		id = body.AccountID
+		update = func(s *Summary) { s.AdminActions++ }
+	case domain.AccountLocked:
+		id = body.AccountID
+		update = func(s *Summary) { s.AdminActions++ }
+	case domain.AccountUnlocked:
+		id = body.AccountID
+		update = func(s *Summary) { s.AdminActions++ }
+	case domain.PasswordResetForced:
+		id = body.AccountID
+		update = func(s *Summary) { s.AdminActions++ }
+	case domain.AccountDeleted:
+		return p.Store.Delete(ctx, string(body.AccountID))
+	case domain.AccountRegistrationAbandoned:
+		return p.Store.Delete(ctx, string(body.AccountID))
+	default:
+		return nil
+	}
+
+	s, err := p.Store.Get(ctx, string(id))
+	if err != nil && !errors.Is(err, core.ErrNotFound) {
+		return err
+	}
+	s.AccountID = id
+	update(&s)
+	if e.CreatedAt.After(s.LastActivity) {
+		s.LastActivity = e.CreatedAt
+	}
+	return p.Store.Put(ctx, string(id), s)
+}
+
+func (p Projection) Reset(ctx context.Context) error { return p.Store.Reset(ctx) }
+
+// AccountActivity returns the activity of the account. Returns
+// [core.ErrNotFound] if no activity has been recorded.
+func (p Projection) AccountActivity(ctx context.Context, id domain.AccountID) (Summary, error) {
+	return p.Store.Get(ctx, string(id))
+}
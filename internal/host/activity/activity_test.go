@@ -0,0 +1,64 @@
+package activity_test
+
+import (
+	"testing"
+	"time"
+
+	"harmony/internal/auth/domain"
+	"harmony/internal/core"
+	"harmony/internal/core/projection"
+	"harmony/internal/host/activity"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func event(body core.EventBody, createdAt time.Time) core.DomainEvent {
+	e := core.NewDomainEvent(body)
+	e.CreatedAt = createdAt
+	return e
+}
+
+func TestAccountActivity(t *testing.T) {
+	ctx := t.Context()
+	p := activity.New(projection.NewMemoryStore[activity.Summary]())
+	id := domain.AccountID("acc-1")
+	registered := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	for _, e := range []core.DomainEvent{
+		event(domain.EmailValidationRequest{AccountID: id}, registered),
+		event(domain.AccountRegistered{AccountID: id}, registered),
+		event(domain.EmailValidationRequest{AccountID: id}, registered.Add(time.Hour)),
+		event(domain.AccountLocked{AccountID: id, AdminID: "admin"}, registered.Add(2*time.Hour)),
+		event(domain.AccountRegistered{AccountID: "acc-2"}, registered),
+	} {
+		assert.NoError(t, p.Apply(ctx, e))
+	}
+
+	summary, err := p.AccountActivity(ctx, id)
+	assert.NoError(t, err)
+	assert.Equal(t, activity.Summary{
+		AccountID:               id,
+		RegisteredAt:            registered,
+		EmailValidationRequests: 2,
+		AdminActions:            1,
+		LastActivity:            registered.Add(2 * time.Hour),
+	}, summary)
+
+	assert.NoError(t, p.Apply(ctx, event(domain.AccountDeleted{AccountID: id}, time.Now())))
+	_, err = p.AccountActivity(ctx, id)
+	assert.ErrorIs(t, err, core.ErrNotFound, "Activity removed with the account")
+}
+
+func TestAccountActivityRemovedWithAbandonedRegistration(t *testing.T) {
+	ctx := t.Context()
+	p := activity.New(projection.NewMemoryStore[activity.Summary]())
+	id := domain.AccountID("acc-1")
+	registered := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	assert.NoError(t, p.Apply(ctx, event(domain.AccountRegistered{AccountID: id}, registered)))
+	assert.NoError(t, p.Apply(ctx, event(domain.EmailValidationRequest{AccountID: id}, registered)))
+
+	assert.NoError(t, p.Apply(ctx,
+		event(domain.AccountRegistrationAbandoned{AccountID: id}, registered.Add(48*time.Hour))))
+	_, err := p.AccountActivity(ctx, id)
+	assert.ErrorIs(t, err, core.ErrNotFound, "Activity removed with the registration")
+}
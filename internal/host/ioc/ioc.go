@@ -0,0 +1,30 @@
+package ioc
+
+import (
+	"harmony/internal/core/corerepo"
+	"harmony/internal/core/projection"
+	"harmony/internal/host/activity"
+	hostrouter "harmony/internal/host/router"
+
+	"github.com/gost-dom/surgeon"
+)
+
+// Activity is the account activity projection, with state stored in CouchDB.
+var Activity = activity.New(projection.NewCouchDBStore[activity.Summary](
+	&corerepo.DefaultConnection, activity.Projection{}.Name(),
+))
+
+// Projections returns the runners of the projections of the host context.
+func Projections() []*projection.Runner {
+	return []*projection.Runner{activityRunner}
+}
+
+var activityRunner = projection.NewRunner(
+	Activity,
+	projection.CouchDBCheckpoints{DB: &corerepo.DefaultConnection},
+	corerepo.DefaultDomainEventRepo,
+)
+
+func Install[T any](graph *surgeon.Graph[T]) *surgeon.Graph[T] {
+	return surgeon.Replace[hostrouter.ActivityReader](graph, Activity)
+}
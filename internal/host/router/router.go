@@ -1,18 +1,45 @@
 package router
 
 import (
+	"context"
+	"errors"
+	"harmony/internal/auth"
+	"harmony/internal/auth/domain"
+	"harmony/internal/core"
+	"harmony/internal/host/activity"
 	"harmony/internal/host/router/views"
+	"harmony/internal/infrastructure/log"
 	"net/http"
-
-	"github.com/a-h/templ"
 )
 
+// ActivityReader returns the activity summary of an account, implemented by
+// [activity.Projection].
+type ActivityReader interface {
+	AccountActivity(context.Context, domain.AccountID) (activity.Summary, error)
+}
+
 type HostRouter struct {
 	*http.ServeMux
+	Activity ActivityReader
 }
 
+// Index renders the host page, with the activity of the authenticated
+// account. The page is rendered without activity if it cannot be read.
 func (r *HostRouter) Index() http.Handler {
-	return templ.Handler(views.HostsPage())
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ctx := req.Context()
+		var summary *activity.Summary
+		if acc, ok := auth.AuthenticatedUser(ctx); ok && r.Activity != nil {
+			s, err := r.Activity.AccountActivity(ctx, acc.ID)
+			switch {
+			case err == nil:
+				summary = &s
+			case !errors.Is(err, core.ErrNotFound):
+				log.LogError(ctx, "host: read account activity", err)
+			}
+		}
+		views.HostsPage(summary).Render(ctx, w)
+	})
 }
 
 // Init implements interface [surgeon.Initer].
@@ -0,0 +1,73 @@
+package router_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"harmony/internal/auth"
+	"harmony/internal/auth/domain"
+	"harmony/internal/core"
+	"harmony/internal/core/projection"
+	"harmony/internal/host/activity"
+	"harmony/internal/host/router"
+	"harmony/internal/testing/domaintest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func initRouter(t *testing.T, events ...core.DomainEvent) *router.HostRouter {
+	p := activity.New(projection.NewMemoryStore[activity.Summary]())
+	runner := projection.NewRunner(p, &projection.MemoryCheckpoints{}, nil)
+	for _, e := range events {
+		assert.NoError(t, runner.ProcessDomainEvent(t.Context(), e))
+	}
+	r := router.New()
+	r.Activity = p
+	return r
+}
+
+func getIndex(r http.Handler, acc *domain.AuthenticatedAccount) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", "/", nil)
+	if acc != nil {
+		auth.SetAuthenticatedUser(&req, *acc)
+	}
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestIndexShowsAccountActivity(t *testing.T) {
+	acc := domaintest.InitAuthenticatedAccount()
+	other := domaintest.InitAuthenticatedAccount()
+	registered := core.NewDomainEvent(domain.AccountRegistered{AccountID: acc.ID})
+	locked := core.NewDomainEvent(domain.AccountLocked{AccountID: acc.ID, AdminID: "admin"})
+	locked.CreatedAt = registered.CreatedAt.Add(time.Hour)
+	r := initRouter(t,
+		registered,
+		core.NewDomainEvent(domain.EmailValidationRequest{AccountID: acc.ID}),
+		locked,
+	)
+
+	t.Run("Account with activity", func(t *testing.T) {
+		rec := getIndex(r, &acc)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		body := rec.Body.String()
+		assert.Contains(t, body, "Account activity")
+		assert.Contains(t, body, "Member since")
+		assert.Contains(t, body, "Changes by administrators")
+	})
+
+	t.Run("Account without activity", func(t *testing.T) {
+		rec := getIndex(r, &other)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.NotContains(t, rec.Body.String(), "Account activity")
+	})
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		rec := getIndex(r, nil)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.NotContains(t, rec.Body.String(), "Account activity")
+	})
+}
@@ -0,0 +1,14 @@
+// Package env exposes information about the environment the process runs in.
+package env
+
+import "os"
+
+// VarName is the name of the environment variable specifying the environment.
+const VarName = "HARMONY_ENV"
+
+const production = "production"
+
+// Production returns whether the process is running in production mode, i.e.,
+// the HARMONY_ENV environment variable is "production". Development fallbacks,
+// e.g., hardcoded keys, must not be used in production.
+func Production() bool { return os.Getenv(VarName) == production }
@@ -0,0 +1,239 @@
+// Package metrics records application metrics, and exposes them in the
+// Prometheus text format.
+//
+// Metrics are created once, typically in package variables, and registered in
+// the [Default] registry, served by [Handler].
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are histogram buckets suitable for latencies in seconds.
+var DefaultBuckets = []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type metric interface {
+	write(w io.Writer)
+}
+
+// Registry contains a set of metrics.
+type Registry struct {
+	mu      sync.Mutex
+	metrics map[string]metric
+}
+
+func NewRegistry() *Registry { return &Registry{metrics: make(map[string]metric)} }
+
+// Default is the registry used by [NewCounterVec], [NewHistogramVec], and
+// [Handler].
+var Default = NewRegistry()
+
+func (r *Registry) register(name string, m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.metrics[name]; ok {
+		panic(fmt.Sprintf("metrics: duplicate metric: %s", name))
+	}
+	r.metrics[name] = m
+}
+
+// Write writes all metrics in the Prometheus text format.
+func (r *Registry) Write(w io.Writer) {
+	r.mu.Lock()
+	names := slices.Sorted(maps.Keys(r.metrics))
+	metrics := make([]metric, len(names))
+	for i, n := range names {
+		metrics[i] = r.metrics[n]
+	}
+	r.mu.Unlock()
+	for _, m := range metrics {
+		m.write(w)
+	}
+}
+
+// Handler serves the metrics of the [Default] registry.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		Default.Write(w)
+	})
+}
+
+// vec contains the label names, and the values for each combination of label
+// values.
+type vec[T any] struct {
+	name   string
+	help   string
+	labels []string
+	mu     sync.Mutex
+	values map[string]*T
+	keys   map[string][]string
+}
+
+func newVec[T any](name, help string, labels []string) vec[T] {
+	return vec[T]{
+		name:   name,
+		help:   help,
+		labels: labels,
+		values: make(map[string]*T),
+		keys:   make(map[string][]string),
+	}
+}
+
+// with returns the value for the label values, creating it using init if it
+// doesn't exist. The caller must hold the lock.
+func (v *vec[T]) with(labelValues []string, init func() *T) *T {
+	if len(labelValues) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %s: expected %d label values", v.name, len(v.labels)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	res, ok := v.values[key]
+	if !ok {
+		res = init()
+		v.values[key] = res
+		v.keys[key] = slices.Clone(labelValues)
+	}
+	return res
+}
+
+func (v *vec[T]) sortedKeys() []string { return slices.Sorted(maps.Keys(v.values)) }
+
+func (v *vec[T]) writeHeader(w io.Writer, typ string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", v.name, v.help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", v.name, typ)
+}
+
+// labelString formats label names and values, with extra labels appended.
+func (v *vec[T]) labelString(values []string, extra ...string) string {
+	pairs := make([]string, 0, len(values)+len(extra)/2)
+	for i, l := range v.labels {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", l, strconv.Quote(values[i])))
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", extra[i], strconv.Quote(extra[i+1])))
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// CounterVec is a counter partitioned by labels.
+type CounterVec struct{ vec[float64] }
+
+// NewCounterVec creates a counter in the [Default] registry.
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	return Default.NewCounterVec(name, help, labels...)
+}
+
+// NewCounterVec creates a counter in the registry.
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	res := &CounterVec{newVec[float64](name, help, labels)}
+	r.register(name, res)
+	return res
+}
+
+// Inc increments the counter for the label values.
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	*c.with(labelValues, func() *float64 { return new(float64) }) += 1
+}
+
+// Value returns the value of the counter for the label values.
+func (c *CounterVec) Value(labelValues ...string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return *c.with(labelValues, func() *float64 { return new(float64) })
+}
+
+func (c *CounterVec) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.writeHeader(w, "counter")
+	for _, k := range c.sortedKeys() {
+		fmt.Fprintf(w, "%s%s %g\n", c.name, c.labelString(c.keys[k]), *c.values[k])
+	}
+}
+
+type histogram struct {
+	counts []uint64 // counts[i] is the number of observations <= buckets[i]
+	count  uint64
+	sum    float64
+}
+
+// HistogramVec is a histogram partitioned by labels.
+type HistogramVec struct {
+	vec[histogram]
+	buckets []float64
+}
+
+// NewHistogramVec creates a histogram in the [Default] registry.
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	return Default.NewHistogramVec(name, help, buckets, labels...)
+}
+
+// NewHistogramVec creates a histogram in the registry.
+func (r *Registry) NewHistogramVec(
+	name, help string,
+	buckets []float64,
+	labels ...string,
+) *HistogramVec {
+	res := &HistogramVec{
+		vec:     newVec[histogram](name, help, labels),
+		buckets: slices.Sorted(slices.Values(buckets)),
+	}
+	r.register(name, res)
+	return res
+}
+
+func (h *HistogramVec) get(labelValues []string) *histogram {
+	return h.with(labelValues, func() *histogram {
+		return &histogram{counts: make([]uint64, len(h.buckets))}
+	})
+}
+
+// Observe adds a value to the histogram for the label values.
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	hist := h.get(labelValues)
+	for i, b := range h.buckets {
+		if value <= b {
+			hist.counts[i]++
+		}
+	}
+	hist.count++
+	hist.sum += value
+}
+
+// Count returns the number of observations for the label values.
+func (h *HistogramVec) Count(labelValues ...string) uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.get(labelValues).count
+}
+
+func (h *HistogramVec) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.writeHeader(w, "histogram")
+	for _, k := range h.sortedKeys() {
+		hist := h.values[k]
+		labels := h.keys[k]
+		for i, b := range h.buckets {
+			le := strconv.FormatFloat(b, 'g', -1, 64)
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelString(labels, "le", le), hist.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.name, h.labelString(labels, "le", "+Inf"), hist.count)
+		fmt.Fprintf(w, "%s_sum%s %g\n", h.name, h.labelString(labels), hist.sum)
+		fmt.Fprintf(w, "%s_count%s %d\n", h.name, h.labelString(labels), hist.count)
+	}
+}
@@ -0,0 +1,46 @@
+package metrics_test
+
+import (
+	"strings"
+	"testing"
+
+	"harmony/internal/infrastructure/metrics"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistryWrite(t *testing.T) {
+	reg := metrics.NewRegistry()
+	counter := reg.NewCounterVec("requests_total", "Number of requests", "status")
+	hist := reg.NewHistogramVec("duration_seconds", "Latency", []float64{1, 0.1}, "op")
+
+	counter.Inc("200")
+	counter.Inc("200")
+	counter.Inc("500")
+	hist.Observe(0.05, "get")
+	hist.Observe(0.5, "get")
+
+	assert.Equal(t, float64(2), counter.Value("200"))
+	assert.Equal(t, uint64(2), hist.Count("get"))
+
+	var b strings.Builder
+	reg.Write(&b)
+	assert.Equal(t, `# HELP duration_seconds Latency
+# TYPE duration_seconds histogram
+duration_seconds_bucket{op="get",le="0.1"} 1
+duration_seconds_bucket{op="get",le="1"} 2
+duration_seconds_bucket{op="get",le="+Inf"} 2
+duration_seconds_sum{op="get"} 0.55
+duration_seconds_count{op="get"} 2
+# HELP requests_total Number of requests
+# TYPE requests_total counter
+requests_total{status="200"} 2
+requests_total{status="500"} 1
+`, b.String())
+}
+
+func TestRegistryPanicsOnDuplicateName(t *testing.T) {
+	reg := metrics.NewRegistry()
+	reg.NewCounterVec("requests_total", "Number of requests")
+	assert.Panics(t, func() { reg.NewCounterVec("requests_total", "Number of requests") })
+}
@@ -0,0 +1,180 @@
+// Package trace records trace spans, i.e., timed operations forming a tree
+// for a single unit of work, such as an HTTP request.
+//
+// Spans are passed to the configured [Exporter] when they end. By default,
+// spans are not exported. Tests can use a [Recorder] to verify recorded spans.
+package trace
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"harmony/internal/infrastructure/log"
+)
+
+type contextKey string
+
+const ctxKeySpan contextKey = "infra:trace:span"
+
+// Exporter receives spans when they end.
+type Exporter interface {
+	Export(Span)
+}
+
+var exporter atomic.Pointer[Exporter]
+
+// SetExporter sets the exporter receiving all ended spans. A nil exporter
+// disables exporting.
+func SetExporter(e Exporter) {
+	if e == nil {
+		exporter.Store(nil)
+	} else {
+		exporter.Store(&e)
+	}
+}
+
+// Span is a timed operation. Create spans with [Start], and end them with
+// [Span.End].
+type Span struct {
+	Name     string
+	TraceID  string
+	SpanID   string
+	ParentID string
+	Start    time.Time
+	End      time.Time
+	Attrs    map[string]string
+	Err      error
+}
+
+// ActiveSpan is a span that has been started, but not ended.
+type ActiveSpan struct {
+	mu   sync.Mutex
+	span Span
+}
+
+func newID(bytes int) string {
+	b := make([]byte, bytes)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func newTraceID() string { return newID(16) }
+func newSpanID() string  { return newID(8) }
+
+// Start starts a new span. If the context contains a span, the new span is a
+// child of that; otherwise it starts a new trace. The returned context contains
+// the new span.
+func Start(ctx context.Context, name string) (context.Context, *ActiveSpan) {
+	span := Span{
+		Name:   name,
+		SpanID: newSpanID(),
+		Start:  time.Now(),
+		Attrs:  make(map[string]string),
+	}
+	if parent := FromContext(ctx); parent != nil {
+		span.TraceID = parent.span.TraceID
+		span.ParentID = parent.span.SpanID
+	} else {
+		span.TraceID = newTraceID()
+	}
+	res := &ActiveSpan{span: span}
+	return context.WithValue(ctx, ctxKeySpan, res), res
+}
+
+// StartRemote starts a span continuing a trace from another process, e.g., an
+// incoming HTTP request with a traceparent header.
+func StartRemote(ctx context.Context, name, traceID, parentID string) (
+	context.Context, *ActiveSpan,
+) {
+	ctx, span := Start(ctx, name)
+	span.span.TraceID = traceID
+	span.span.ParentID = parentID
+	return ctx, span
+}
+
+// FromContext returns the active span of the context, or nil.
+func FromContext(ctx context.Context) *ActiveSpan {
+	s, _ := ctx.Value(ctxKeySpan).(*ActiveSpan)
+	return s
+}
+
+// TraceID returns the ID of the trace the span belongs to.
+func (s *ActiveSpan) TraceID() string { return s.span.TraceID }
+
+// SpanID returns the ID of the span.
+func (s *ActiveSpan) SpanID() string { return s.span.SpanID }
+
+// SetAttr sets an attribute describing the operation.
+func (s *ActiveSpan) SetAttr(key, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.span.Attrs[key] = value
+}
+
+// SetError records that the operation failed.
+func (s *ActiveSpan) SetError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.span.Err = err
+}
+
+// End ends the span, and exports it.
+func (s *ActiveSpan) End() {
+	s.mu.Lock()
+	s.span.End = time.Now()
+	span := s.span
+	s.mu.Unlock()
+	if e := exporter.Load(); e != nil {
+		(*e).Export(span)
+	}
+}
+
+// LogExporter writes spans to the log at debug level.
+type LogExporter struct{}
+
+func (LogExporter) Export(s Span) {
+	attrs := make([]any, 0, len(s.Attrs))
+	for k, v := range s.Attrs {
+		attrs = append(attrs, log.String(k, v))
+	}
+	ctx := context.Background()
+	log.Debug(ctx, "Span",
+		log.String("name", s.Name),
+		log.String("traceID", s.TraceID),
+		log.String("spanID", s.SpanID),
+		log.String("parentID", s.ParentID),
+		log.Duration("duration", s.End.Sub(s.Start)),
+		log.Group("attrs", attrs...),
+		log.ErrAttr(s.Err),
+	)
+}
+
+// Recorder is an exporter keeping spans in memory, to be used in tests.
+type Recorder struct {
+	mu    sync.Mutex
+	spans []Span
+}
+
+func (r *Recorder) Export(s Span) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = append(r.spans, s)
+}
+
+// Spans returns the recorded spans of the trace. As tests may run in
+// parallel, tests should start a span, and only look at spans of that trace.
+func (r *Recorder) Spans(traceID string) []Span {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var res []Span
+	for _, s := range r.spans {
+		if s.TraceID == traceID {
+			res = append(res, s)
+		}
+	}
+	return res
+}
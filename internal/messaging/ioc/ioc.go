@@ -1,7 +1,10 @@
 package ioc
 
 import (
+	"harmony/internal/auth"
+	"harmony/internal/core"
 	"harmony/internal/core/corerepo"
+	"harmony/internal/core/ledger"
 	"harmony/internal/messaging"
 
 	"github.com/gost-dom/surgeon"
@@ -9,6 +12,10 @@ import (
 
 var Graph *surgeon.Graph[messaging.MessageHandler]
 
+// Ledger records the events processed by the handlers, shared by all server
+// instances using the database.
+var Ledger = ledger.CouchDBLedger{DB: &corerepo.DefaultConnection, Clock: core.SystemClock{}}
+
 func init() {
 	handler := messaging.NewMessageHandler()
 	// handler := messaging.MessageHandler{
@@ -19,6 +26,9 @@ func init() {
 
 	// Graph.Inject(repo.AccountRepository{Connection: couchdb.DefaultConnection})
 	Graph.Inject(corerepo.DefaultDomainEventRepo)
+	Graph.Inject(core.SystemClock{})
+	Graph.Inject(auth.SMTPSender{})
+	Graph.Inject(Ledger)
 }
 
 func Handler() messaging.MessageHandler { return Graph.Instance() }
@@ -0,0 +1,83 @@
+package messaging
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"harmony/internal/core"
+	"harmony/internal/core/lease"
+	"harmony/internal/infrastructure/log"
+)
+
+// heldLeases keeps track of the leases held by a [MessagePump]. The leases are
+// renewed on a timer, so the pump checks if it holds a lease in memory.
+type heldLeases struct {
+	store  lease.Store
+	holder string
+	ttl    time.Duration
+	clock  core.Clock
+	names  []string
+
+	mu        sync.Mutex
+	expiresAt map[string]time.Time
+}
+
+// holds returns whether the lease is held, and hasn't expired. A lease that
+// failed to renew is considered held until it expires.
+func (l *heldLeases) holds(name string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return core.Now(l.clock).Before(l.expiresAt[name])
+}
+
+// renew acquires, or renews, the leases. The expiry is computed before
+// acquiring, so the lease expires in the store no sooner than in memory.
+func (l *heldLeases) renew(ctx context.Context) {
+	for _, name := range l.names {
+		expiresAt := core.Now(l.clock).Add(l.ttl)
+		ok, err := l.store.Acquire(ctx, name, l.holder, l.ttl)
+		if err != nil {
+			log.LogError(ctx, "MessagePump: acquire lease", err, "lease", name)
+			continue
+		}
+		l.mu.Lock()
+		if l.expiresAt == nil {
+			l.expiresAt = make(map[string]time.Time)
+		}
+		if ok {
+			l.expiresAt[name] = expiresAt
+		} else {
+			delete(l.expiresAt, name)
+		}
+		l.mu.Unlock()
+	}
+}
+
+// keep renews the leases at the interval until the context is cancelled, and
+// then releases them, letting another pump take over without waiting for them
+// to expire.
+func (l *heldLeases) keep(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			l.renew(ctx)
+		case <-ctx.Done():
+			l.release(context.WithoutCancel(ctx))
+			return
+		}
+	}
+}
+
+func (l *heldLeases) release(ctx context.Context) {
+	l.mu.Lock()
+	clear(l.expiresAt)
+	l.mu.Unlock()
+	for _, name := range l.names {
+		if err := l.store.Release(ctx, name, l.holder); err != nil {
+			log.LogError(ctx, "MessagePump: release lease", err, "lease", name)
+		}
+	}
+}
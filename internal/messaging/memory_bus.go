@@ -0,0 +1,227 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+
+	"harmony/internal/core"
+	"harmony/internal/core/corerepo"
+)
+
+// MemoryBus is an in-memory event bus, replacing CouchDB as [MessageSource],
+// [EventStream], and [DomainEventUpdater] in tests and local runs. It also reads
+// historical events for projections, like corerepo.DomainEventRepository.
+//
+// Delivery semantics match CouchDB: a stream first delivers the events that
+// haven't been published, and then new events as they are published. Events
+// that are not marked as published are delivered again to new streams.
+type MemoryBus struct {
+	mu      sync.Mutex
+	events  []core.DomainEvent
+	streams []*busStream
+	// outstanding is the number of events delivered to streams, that have not
+	// yet been processed.
+	outstanding int
+	// changed is closed, and replaced, when the bus may have become idle.
+	changed chan struct{}
+}
+
+var _ core.EventPublisher = (*MemoryBus)(nil)
+
+func NewMemoryBus() *MemoryBus { return &MemoryBus{changed: make(chan struct{})} }
+
+// busStream queues events for a stream, so publishing never blocks on slow
+// consumers.
+type busStream struct {
+	mu     sync.Mutex
+	queue  []core.DomainEvent
+	notify chan struct{}
+}
+
+func (s *busStream) push(events ...core.DomainEvent) {
+	s.mu.Lock()
+	s.queue = append(s.queue, events...)
+	s.mu.Unlock()
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (s *busStream) pop() (core.DomainEvent, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.queue) == 0 {
+		return core.DomainEvent{}, false
+	}
+	e := s.queue[0]
+	s.queue = s.queue[1:]
+	return e, true
+}
+
+// Publish adds the events to the bus, delivering them to all streams.
+func (b *MemoryBus) Publish(_ context.Context, events ...core.DomainEvent) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.events = append(b.events, events...)
+	for _, s := range b.streams {
+		b.outstanding += len(events)
+		s.push(events...)
+	}
+	return nil
+}
+
+// StartListener implements [MessageSource]. Published events are immediately
+// available, so there is nothing to listen to.
+func (b *MemoryBus) StartListener(context.Context) error { return nil }
+
+// StreamOfEvents implements [EventStream]. The channel is closed when the
+// context is cancelled.
+func (b *MemoryBus) StreamOfEvents(ctx context.Context) (<-chan core.DomainEvent, error) {
+	s := &busStream{notify: make(chan struct{}, 1)}
+	b.mu.Lock()
+	unpublished := b.unpublished()
+	b.outstanding += len(unpublished)
+	s.push(unpublished...)
+	b.streams = append(b.streams, s)
+	b.mu.Unlock()
+
+	ch := make(chan core.DomainEvent)
+	go func() {
+		defer close(ch)
+		defer b.removeStream(s)
+		for {
+			for e, ok := s.pop(); ok; e, ok = s.pop() {
+				select {
+				case ch <- e:
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case <-s.notify:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// removeStream removes a closed stream. Events that were queued for the stream
+// will not be processed.
+func (b *MemoryBus) removeStream(s *busStream) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.streams = slices.DeleteFunc(b.streams, func(x *busStream) bool { return x == s })
+	s.mu.Lock()
+	b.outstanding -= len(s.queue)
+	s.mu.Unlock()
+	b.notifyChanged()
+}
+
+func (b *MemoryBus) unpublished() []core.DomainEvent {
+	var res []core.DomainEvent
+	for _, e := range b.events {
+		if e.PublishedAt == nil {
+			res = append(res, e)
+		}
+	}
+	return res
+}
+
+// Update implements [DomainEventUpdater], storing the event, e.g., when marked
+// as published.
+func (b *MemoryBus) Update(_ context.Context, e core.DomainEvent) (core.DomainEvent, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	i := slices.IndexFunc(b.events, func(x core.DomainEvent) bool { return x.ID == e.ID })
+	if i < 0 {
+		return e, core.ErrNotFound
+	}
+	b.events[i] = e
+	return e, nil
+}
+
+// Processed is called by the [MessagePump] when an event delivered by the bus
+// has been processed, successfully or not.
+func (b *MemoryBus) Processed(context.Context, core.DomainEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.outstanding--
+	b.notifyChanged()
+}
+
+// notifyChanged wakes up callers of Wait. The caller must hold the lock.
+func (b *MemoryBus) notifyChanged() {
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
+
+// idle returns whether all events have been processed. Without any streams,
+// unpublished events are waiting to be processed. The caller must hold the
+// lock.
+func (b *MemoryBus) idle() bool {
+	if len(b.streams) == 0 {
+		return len(b.unpublished()) == 0
+	}
+	return b.outstanding == 0
+}
+
+// Wait blocks until all published events have been processed, or the context
+// is done.
+func (b *MemoryBus) Wait(ctx context.Context) error {
+	for {
+		b.mu.Lock()
+		idle, changed := b.idle(), b.changed
+		b.mu.Unlock()
+		if idle {
+			return nil
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Events returns all events published to the bus.
+func (b *MemoryBus) Events() []core.DomainEvent {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return slices.Clone(b.events)
+}
+
+// EachEvent calls fn for each event selected by the query, ordered by
+// creation time, like [corerepo.DomainEventRepository.EachEvent].
+func (b *MemoryBus) EachEvent(
+	_ context.Context,
+	q corerepo.EventQuery,
+	fn func(core.DomainEvent) error,
+) error {
+	events := slices.DeleteFunc(b.Events(), func(e core.DomainEvent) bool {
+		return (q.Type != "" && core.EventTypeName(e) != q.Type) ||
+			(q.AggregateID != "" && e.Metadata.AggregateID != q.AggregateID) ||
+			(!q.From.IsZero() && e.CreatedAt.Before(q.From)) ||
+			(!q.To.IsZero() && !e.CreatedAt.Before(q.To))
+	})
+	slices.SortStableFunc(events, func(x, y core.DomainEvent) int {
+		if c := x.CreatedAt.Compare(y.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(string(x.ID), string(y.ID))
+	})
+	for _, e := range events {
+		if err := fn(e); err != nil {
+			if errors.Is(err, corerepo.ErrStopIteration) {
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
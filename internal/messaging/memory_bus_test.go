@@ -0,0 +1,97 @@
+package messaging_test
+
+import (
+	"context"
+	"testing"
+
+	"harmony/internal/auth"
+	"harmony/internal/core"
+	"harmony/internal/core/projection"
+	"harmony/internal/messaging"
+	"harmony/internal/testing/eventstest"
+	"harmony/internal/testing/repotest"
+
+	gonanoid "github.com/matoous/go-nanoid/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+type entity struct{ ID string }
+
+type entityTranslator struct{}
+
+func (entityTranslator) ID(e entity) string { return e.ID }
+
+// contractEvents collects the values of applied [repotest.ContractEvent]s.
+type contractEvents struct {
+	store *projection.MemoryStore[[]string]
+}
+
+func (contractEvents) Name() string         { return "messaging_test.contract_events" }
+func (contractEvents) EventTypes() []string { return []string{"repotest.ContractEvent"} }
+
+func (p contractEvents) Apply(ctx context.Context, e core.DomainEvent) error {
+	values, _ := p.store.Get(ctx, "")
+	return p.store.Put(ctx, "", append(values, e.Body.(repotest.ContractEvent).Value))
+}
+
+func (p contractEvents) Reset(ctx context.Context) error { return p.store.Reset(ctx) }
+
+func newProjection() (contractEvents, *projection.Runner) {
+	p := contractEvents{projection.NewMemoryStore[[]string]()}
+	return p, projection.NewRunner(p, &projection.MemoryCheckpoints{}, nil)
+}
+
+func insertWithEvent(t *testing.T, publisher core.EventPublisher, value string) {
+	t.Helper()
+	repo := repotest.NewRepositoryStub[entity, string](t, entityTranslator{})
+	repo.Publisher = publisher
+	uc := core.UseCaseOfEntity(entity{ID: gonanoid.Must()})
+	uc.AddEvent(core.NewDomainEvent(repotest.ContractEvent{Value: value}))
+	_, err := repo.Insert(t.Context(), uc)
+	assert.NoError(t, err)
+}
+
+func TestMemoryBusDeliversPublishedEvents(t *testing.T) {
+	p, runner := newProjection()
+	bus := eventstest.StartPump(t, auth.NewEmailValidator(), runner)
+
+	insertWithEvent(t, bus, "first")
+	insertWithEvent(t, bus, "second")
+	eventstest.WaitForEvents(t, bus)
+
+	values, err := p.store.Get(t.Context(), "")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"first", "second"}, values)
+	for _, e := range bus.Events() {
+		assert.NotNil(t, e.PublishedAt, "Event marked as published")
+	}
+}
+
+func TestMemoryBusRedeliversUnpublishedEvents(t *testing.T) {
+	bus := messaging.NewMemoryBus()
+	insertWithEvent(t, bus, "value")
+	published := core.NewDomainEvent(repotest.ContractEvent{Value: "published"})
+	published.MarkPublished(nil)
+	assert.NoError(t, bus.Publish(t.Context(), published))
+
+	ctx, cancel := context.WithCancel(t.Context())
+	ch, err := bus.StreamOfEvents(ctx)
+	assert.NoError(t, err)
+	first := <-ch
+	assert.Equal(t, repotest.ContractEvent{Value: "value"}, first.Body)
+	cancel()
+	for range ch {
+	}
+
+	ch, err = bus.StreamOfEvents(t.Context())
+	assert.NoError(t, err)
+	assert.Equal(t, first.ID, (<-ch).ID, "Unpublished event delivered again")
+}
+
+func TestMemoryBusWaitsForUnpublishedEventsBeforePumpStarts(t *testing.T) {
+	bus := messaging.NewMemoryBus()
+	insertWithEvent(t, bus, "value")
+	ctx, cancel := context.WithTimeout(t.Context(), 0)
+	defer cancel()
+	assert.ErrorIs(t, bus.Wait(ctx), context.DeadlineExceeded)
+}
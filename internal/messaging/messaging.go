@@ -1,14 +1,37 @@
 package messaging
 
 import (
+	"cmp"
 	"context"
 	"harmony/internal/auth"
 	"harmony/internal/core"
-	"harmony/internal/core/corerepo"
+	"harmony/internal/core/lease"
+	"harmony/internal/core/projection"
 	"harmony/internal/infrastructure/log"
+	"sync"
 	"time"
 )
 
+// MessageSource collects new domain events from the outbox of stored
+// entities, making them available in the [EventStream]. Implemented by
+// corerepo.MessageSource, and [MemoryBus].
+type MessageSource interface {
+	StartListener(context.Context) error
+}
+
+// EventStream delivers unpublished domain events. Events that are not marked
+// as published by the [DomainEventUpdater] are delivered again in a new
+// stream. Implemented by corerepo.DomainEventRepository, and [MemoryBus].
+type EventStream interface {
+	StreamOfEvents(context.Context) (<-chan core.DomainEvent, error)
+}
+
+// processedNotifier is implemented by event streams that need to know when the
+// pump has processed an event, e.g., [MemoryBus].
+type processedNotifier interface {
+	Processed(context.Context, core.DomainEvent)
+}
+
 type DomainEventUpdater interface {
 	Update(context.Context, core.DomainEvent) (core.DomainEvent, error)
 }
@@ -16,51 +39,266 @@ type DomainEventUpdater interface {
 type MessageHandler struct {
 	EventUpdater DomainEventUpdater
 	Validator    *auth.EmailValidator
+	// Clock sets the time events are published. The system clock is used if
+	// nil.
+	Clock core.Clock
 }
 
 func NewMessageHandler() *MessageHandler {
 	return &MessageHandler{
-		nil,
-		auth.NewEmailValidator(),
+		Validator: auth.NewEmailValidator(),
 	}
 }
 
+// ProcessDomainEvent processes the event. Domain events created while
+// processing are caused by the event, and log entries contain the event ID and
+// correlation ID.
 func (h MessageHandler) ProcessDomainEvent(ctx context.Context, event core.DomainEvent) error {
 	var err error
+	ctx = core.WithCausingEvent(ctx, event)
+	m := core.MetadataFromContext(ctx)
+	ctx = log.With(ctx, "eventID", event.ID, "correlationID", m.CorrelationID)
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 	if err = h.Validator.ProcessDomainEvent(ctx, event); err == nil {
-		event.MarkPublished()
+		event.MarkPublished(h.Clock)
 		_, err = h.EventUpdater.Update(ctx, event)
 	}
 	return err
 }
 
+// CancelScheduledEvent implements [ScheduledEventCanceller]. A cancelled event
+// is marked as published, so it isn't delivered again.
+func (h MessageHandler) CancelScheduledEvent(ctx context.Context, event core.DomainEvent) (bool, error) {
+	cancelled, err := h.Validator.CancelScheduledEvent(ctx, event)
+	if err == nil && cancelled {
+		event.MarkPublished(h.Clock)
+		_, err = h.EventUpdater.Update(ctx, event)
+	}
+	return cancelled, err
+}
+
+// DefaultLeaseRenewInterval is the default time between a [MessagePump]
+// renewing its leases.
+const DefaultLeaseRenewInterval = 10 * time.Second
+
+// DefaultCatchUpInterval is the default time between projections catching up
+// with stored events.
+const DefaultCatchUpInterval = time.Minute
+
+// handlerLeaseName is the name of the lease giving a pump the right to deliver
+// events to the handler.
+const handlerLeaseName = "messaging:handler"
+
+// MessagePump delivers new domain events to the handler, and to the
+// projections. Projections catch up with events stored while the application
+// wasn't running when the pump starts, and every CatchUpInterval, applying
+// events that failed to apply, or were missed.
+//
+// Scheduled events are kept by the Scheduler, and delivered to the handler
+// when due, unless the handler cancels them. Projections receive scheduled
+// events immediately, keeping events in the order they were created.
+//
+// When multiple instances of the server run, each pump receives all events.
+// Pumps sharing Leases split the work: events are only delivered to the
+// handler by the pump holding the handler lease, and a projection is only
+// updated by the pump holding the lease of the projection, so the projection
+// and its checkpoint are updated by one pump at a time. Leases are renewed
+// every LeaseRenewInterval, and the pump checks which leases it holds in
+// memory, so processing an event doesn't access the lease store. If the holder
+// stops, another pump acquires the leases when they expire. Events missed
+// meanwhile are applied when the projection catches up, and delivered to the
+// handler when delivered again, as they are not marked as published.
+// Projections must therefore keep their state in shared storage. Scheduled
+// events are only kept by the pump holding the handler lease.
 type MessagePump struct {
-	corerepo.MessageSource
-	corerepo.DomainEventRepository
-	Handler MessageHandler
+	Source      MessageSource
+	Events      EventStream
+	Handler     MessageHandler
+	Projections []*projection.Runner
+	// Scheduler keeps scheduled events until they are due. A default scheduler
+	// is used if nil.
+	Scheduler *Scheduler
+	// Leases decide which pump handles events, and updates projections. If
+	// nil, the pump processes all events.
+	Leases lease.Store
+	// Holder identifies the pump when acquiring leases. A new ID is generated
+	// if empty.
+	Holder string
+	// CatchUpInterval is the time between projections catching up.
+	// [DefaultCatchUpInterval] is used if zero.
+	CatchUpInterval time.Duration
+	// LeaseRenewInterval is the time between renewing leases.
+	// [DefaultLeaseRenewInterval] is used if zero.
+	LeaseRenewInterval time.Duration
+	// Stopped, if set, is waited for until the pump has stopped, and released
+	// its leases, e.g., to release them before the process exits.
+	Stopped *sync.WaitGroup
+
+	held *heldLeases
 }
 
+// Start starts the pump, returning when projections have caught up. Events are
+// processed until the context is cancelled, and leases are then released.
 func (h MessagePump) Start(ctx context.Context) error {
 	log.Info(ctx, "Starting message pump")
 	if ctx == nil {
 		ctx = context.Background()
 	}
-	err := h.MessageSource.StartListener(ctx)
+	if h.Scheduler == nil {
+		h.Scheduler = NewScheduler()
+	}
+	if h.Holder == "" {
+		h.Holder = lease.NewHolderID()
+	}
+	renewInterval := cmp.Or(h.LeaseRenewInterval, DefaultLeaseRenewInterval)
+	if h.Leases != nil {
+		h.held = &heldLeases{
+			store:  h.Leases,
+			holder: h.Holder,
+			ttl:    3 * renewInterval,
+			clock:  h.Scheduler.Clock,
+			names:  h.leaseNames(),
+		}
+		h.held.renew(ctx)
+	}
+	for _, p := range h.Projections {
+		h.catchUp(ctx, p)
+	}
+	err := h.Source.StartListener(ctx)
 	if err != nil {
 		return err
 	}
-	ch, err := h.DomainEventRepository.StreamOfEvents(ctx)
+	ch, err := h.Events.StreamOfEvents(ctx)
 	if err != nil {
 		return err
 	}
-	go func() {
-		for event := range ch {
-			if err := h.Handler.ProcessDomainEvent(ctx, event); err != nil {
-				log.Error(ctx, "MessageHandler: error processing", "err", err)
+	var stopped sync.WaitGroup
+	if h.held != nil {
+		stopped.Go(func() { h.held.keep(ctx, renewInterval) })
+	}
+	stopped.Go(func() {
+		ticker := time.NewTicker(h.Scheduler.interval())
+		defer ticker.Stop()
+		catchUpTicker := time.NewTicker(cmp.Or(h.CatchUpInterval, DefaultCatchUpInterval))
+		defer catchUpTicker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-ch:
+				if !ok {
+					return
+				}
+				h.processEvent(ctx, event)
+			case <-ticker.C:
+				for _, event := range h.Scheduler.Due() {
+					h.processScheduledEvent(ctx, event)
+				}
+			case <-catchUpTicker.C:
+				for _, p := range h.Projections {
+					h.catchUp(ctx, p)
+				}
 			}
 		}
-	}()
+	})
+	if h.Stopped != nil {
+		h.Stopped.Add(1)
+		go func() {
+			defer h.Stopped.Done()
+			stopped.Wait()
+		}()
+	}
 	return nil
 }
+
+func projectionLeaseName(p *projection.Runner) string {
+	return "projection:" + p.Projection.Name()
+}
+
+// leaseNames returns the names of the leases acquired by the pump.
+func (h MessagePump) leaseNames() []string {
+	names := []string{handlerLeaseName}
+	for _, p := range h.Projections {
+		names = append(names, projectionLeaseName(p))
+	}
+	return names
+}
+
+// holds returns whether the pump holds the lease, i.e., should do the work.
+func (h MessagePump) holds(name string) bool {
+	return h.held == nil || h.held.holds(name)
+}
+
+// catchUp lets the projection catch up with stored events, unless another
+// pump holds the lease of the projection.
+func (h MessagePump) catchUp(ctx context.Context, p *projection.Runner) {
+	if !h.holds(projectionLeaseName(p)) {
+		return
+	}
+	if err := p.CatchUp(ctx); err != nil {
+		log.LogError(ctx, "MessagePump: projection catch up", err,
+			"projection", p.Projection.Name())
+	}
+}
+
+// project delivers the event to the projection, unless another pump holds the
+// lease of the projection. An event that fails to apply is applied when the
+// projection catches up.
+func (h MessagePump) project(ctx context.Context, p *projection.Runner, event core.DomainEvent) {
+	if !h.holds(projectionLeaseName(p)) {
+		return
+	}
+	if err := p.ProcessDomainEvent(ctx, event); err != nil {
+		log.LogError(ctx, "MessagePump: projection error", err,
+			"projection", p.Projection.Name(), "eventID", event.ID)
+	}
+}
+
+// handle delivers the event to the handler. An event the handler fails to
+// process isn't marked as published, and is processed when delivered again.
+func (h MessagePump) handle(ctx context.Context, event core.DomainEvent) {
+	if err := h.Handler.ProcessDomainEvent(ctx, event); err != nil {
+		log.Error(ctx, "MessageHandler: error processing", "err", err)
+	}
+}
+
+// processEvent delivers an event from the stream to projections, and to the
+// handler, unless it is scheduled for later, or another pump holds the handler
+// lease.
+func (h MessagePump) processEvent(ctx context.Context, event core.DomainEvent) {
+	if n, ok := h.Events.(processedNotifier); ok {
+		defer n.Processed(ctx, event)
+	}
+	for _, p := range h.Projections {
+		h.project(ctx, p, event)
+	}
+	if !h.holds(handlerLeaseName) {
+		return
+	}
+	if event.Due(core.Now(h.Scheduler.Clock)) {
+		h.handle(ctx, event)
+	} else {
+		h.Scheduler.Schedule(event)
+	}
+}
+
+// processScheduledEvent delivers a due event to the handler, unless the
+// handler cancels it, or the pump has lost the handler lease since the event
+// was scheduled.
+func (h MessagePump) processScheduledEvent(ctx context.Context, event core.DomainEvent) {
+	if !h.holds(handlerLeaseName) {
+		return
+	}
+	cancelled, err := h.Handler.CancelScheduledEvent(ctx, event)
+	if err != nil {
+		log.LogError(ctx, "MessagePump: cancel scheduled event", err, "eventID", event.ID)
+		return
+	}
+	if cancelled {
+		log.Info(ctx, "MessagePump: scheduled event cancelled",
+			"eventID", event.ID, "type", core.EventTypeName(event))
+		return
+	}
+	h.handle(ctx, event)
+}
@@ -0,0 +1,414 @@
+package messaging_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"harmony/internal/auth"
+	"harmony/internal/auth/domain"
+	"harmony/internal/core"
+	"harmony/internal/core/corerepo"
+	"harmony/internal/core/lease"
+	"harmony/internal/core/ledger"
+	"harmony/internal/core/projection"
+	"harmony/internal/messaging"
+	_ "harmony/internal/testing/couchtest" // clear database before tests
+
+	gonanoid "github.com/matoous/go-nanoid/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+// countingUpdater counts the events marked as published by the handlers.
+type countingUpdater struct {
+	*messaging.MemoryBus
+	mu     sync.Mutex
+	counts map[core.EventID]int
+}
+
+func (u *countingUpdater) Update(ctx context.Context, e core.DomainEvent) (core.DomainEvent, error) {
+	u.mu.Lock()
+	u.counts[e.ID]++
+	u.mu.Unlock()
+	return u.MemoryBus.Update(ctx, e)
+}
+
+func (u *countingUpdater) count(id core.EventID) int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.counts[id]
+}
+
+func TestPumpsWithLeasesHandleEachEventOnce(t *testing.T) {
+	ctx := t.Context()
+	corerepo.AssertInitialized()
+	bus := messaging.NewMemoryBus()
+	updater := &countingUpdater{MemoryBus: bus, counts: make(map[core.EventID]int)}
+	leases := lease.CouchDBStore{DB: &corerepo.DefaultConnection}
+	for range 3 {
+		pump := messaging.MessagePump{
+			Source: bus,
+			Events: bus,
+			Handler: messaging.MessageHandler{
+				EventUpdater: updater,
+				Validator:    &auth.EmailValidator{Repository: accounts{}},
+			},
+			Leases: leases,
+		}
+		assert.NoError(t, pump.Start(ctx))
+	}
+
+	events := make([]core.DomainEvent, 20)
+	for i := range events {
+		events[i] = domain.CreateAccountRegisteredEvent(newAccount(), nil)
+	}
+	// Publish concurrently, like entities stored by different instances
+	var wg sync.WaitGroup
+	for _, e := range events {
+		wg.Go(func() { assert.NoError(t, bus.Publish(ctx, e)) })
+	}
+	wg.Wait()
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	assert.NoError(t, bus.Wait(ctx))
+
+	for _, e := range events {
+		assert.Equal(t, 1, updater.count(e.ID), "Times event %s was handled", e.ID)
+	}
+}
+
+func TestPumpsWithoutLeasesHandleEventsInEachPump(t *testing.T) {
+	bus := messaging.NewMemoryBus()
+	updater := &countingUpdater{MemoryBus: bus, counts: make(map[core.EventID]int)}
+	for range 2 {
+		pump := messaging.MessagePump{
+			Source: bus,
+			Events: bus,
+			Handler: messaging.MessageHandler{
+				EventUpdater: updater,
+				Validator:    &auth.EmailValidator{Repository: accounts{}},
+			},
+		}
+		assert.NoError(t, pump.Start(t.Context()))
+	}
+	event := domain.CreateAccountRegisteredEvent(newAccount(), nil)
+	assert.NoError(t, bus.Publish(t.Context(), event))
+	assert.NoError(t, bus.Wait(t.Context()))
+	assert.Equal(t, 2, updater.count(event.ID))
+}
+
+// countingLeases counts the leases acquired.
+type countingLeases struct {
+	lease.MemoryStore
+	mu       sync.Mutex
+	acquired int
+}
+
+func (s *countingLeases) Acquire(
+	ctx context.Context, name, holder string, ttl time.Duration,
+) (bool, error) {
+	s.mu.Lock()
+	s.acquired++
+	s.mu.Unlock()
+	return s.MemoryStore.Acquire(ctx, name, holder, ttl)
+}
+
+func (s *countingLeases) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.acquired
+}
+
+func TestPumpDoesNotAcquireLeasesForEachEvent(t *testing.T) {
+	bus := messaging.NewMemoryBus()
+	leases := &countingLeases{}
+	pump := messaging.MessagePump{
+		Source: bus,
+		Events: bus,
+		Handler: messaging.MessageHandler{
+			EventUpdater: bus,
+			Validator:    &auth.EmailValidator{Repository: accounts{}},
+		},
+		Projections: []*projection.Runner{
+			projection.NewRunner(&registrationCounter{}, &projection.MemoryCheckpoints{}, bus),
+		},
+		Leases:             leases,
+		LeaseRenewInterval: time.Hour,
+	}
+	assert.NoError(t, pump.Start(t.Context()))
+	for range 10 {
+		event := domain.CreateAccountRegisteredEvent(newAccount(), nil)
+		assert.NoError(t, bus.Publish(t.Context(), event))
+	}
+	assert.NoError(t, bus.Wait(t.Context()))
+	for _, e := range bus.Events() {
+		assert.NotNil(t, e.PublishedAt, "Event handled")
+	}
+	assert.Equal(t, 2, leases.count(), "Leases acquired, handler and projection")
+}
+
+func TestPumpTakesOverHandlerLeaseWhenHolderStops(t *testing.T) {
+	bus := messaging.NewMemoryBus()
+	updater := &countingUpdater{MemoryBus: bus, counts: make(map[core.EventID]int)}
+	leases := &lease.MemoryStore{}
+	start := func(ctx context.Context) {
+		pump := messaging.MessagePump{
+			Source: bus,
+			Events: bus,
+			Handler: messaging.MessageHandler{
+				EventUpdater: updater,
+				Validator:    &auth.EmailValidator{Repository: accounts{}},
+			},
+			Leases:             leases,
+			LeaseRenewInterval: 10 * time.Millisecond,
+		}
+		assert.NoError(t, pump.Start(ctx))
+	}
+	ctx, stop := context.WithCancel(t.Context())
+	start(ctx)
+	start(t.Context())
+	stop()
+	time.Sleep(50 * time.Millisecond) // Allow the remaining pump to renew its leases
+
+	event := domain.CreateAccountRegisteredEvent(newAccount(), nil)
+	assert.NoError(t, bus.Publish(t.Context(), event))
+	assert.NoError(t, bus.Wait(t.Context()))
+	assert.Equal(t, 1, updater.count(event.ID), "Event handled by the remaining pump")
+}
+
+// countingSender keeps sent emails, instead of sending them.
+type countingSender struct {
+	mu   sync.Mutex
+	sent []string
+}
+
+func (s *countingSender) SendMail(_ context.Context, _ string, _ []string, msg []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent = append(s.sent, string(msg))
+	return nil
+}
+
+func (s *countingSender) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.sent)
+}
+
+// last returns the last sent email.
+func (s *countingSender) last() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.sent) == 0 {
+		return ""
+	}
+	return s.sent[len(s.sent)-1]
+}
+
+// crashingUpdater fails to mark events as published, like a process stopping
+// after the email was sent.
+type crashingUpdater struct{}
+
+func (crashingUpdater) Update(_ context.Context, e core.DomainEvent) (core.DomainEvent, error) {
+	return e, errors.New("simulated crash")
+}
+
+func TestPumpSendsEmailOnceWhenRestartedAfterCrash(t *testing.T) {
+	for name, tc := range map[string]struct {
+		ledger    ledger.Ledger
+		wantSends int
+	}{
+		"Without ledger": {wantSends: 2},
+		"MemoryLedger":   {ledger: &ledger.MemoryLedger{}, wantSends: 1},
+		"CouchDBLedger": {
+			ledger:    ledger.CouchDBLedger{DB: &corerepo.DefaultConnection},
+			wantSends: 1,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			bus := messaging.NewMemoryBus()
+			sender := &countingSender{}
+			acc := newAccount()
+			event := acc.StartEmailValidationChallenge(nil)
+			validator := &auth.EmailValidator{
+				Repository: accounts{acc.ID: acc},
+				Sender:     sender,
+				Ledger:     tc.ledger,
+			}
+			start := func(ctx context.Context, updater messaging.DomainEventUpdater) {
+				pump := messaging.MessagePump{
+					Source: bus,
+					Events: bus,
+					Handler: messaging.MessageHandler{
+						EventUpdater: updater,
+						Validator:    validator,
+					},
+				}
+				assert.NoError(t, pump.Start(ctx))
+			}
+
+			ctx, crash := context.WithCancel(t.Context())
+			start(ctx, crashingUpdater{})
+			assert.NoError(t, bus.Publish(t.Context(), event))
+			assert.NoError(t, bus.Wait(t.Context()))
+			crash()
+			assert.Equal(t, 1, sender.count(), "Email sent before crash")
+
+			// The restarted pump receives the unpublished event again
+			start(t.Context(), bus)
+			assert.NoError(t, bus.Wait(t.Context()))
+			assert.Equal(t, tc.wantSends, sender.count(), "Emails sent")
+			assert.NotNil(t, bus.Events()[0].PublishedAt, "Event published after restart")
+		})
+	}
+}
+
+// registrationCounter is a projection counting registered accounts, failing
+// to apply the first event.
+type registrationCounter struct {
+	mu     sync.Mutex
+	failed bool
+	count  int
+}
+
+func (*registrationCounter) Name() string { return "messaging_test.registrations" }
+
+func (*registrationCounter) EventTypes() []string {
+	return []string{core.EventTypeNameOf[domain.AccountRegistered]()}
+}
+
+func (c *registrationCounter) Apply(context.Context, core.DomainEvent) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.failed {
+		c.failed = true
+		return errors.New("simulated failure")
+	}
+	c.count++
+	return nil
+}
+
+func (c *registrationCounter) Reset(context.Context) error { return nil }
+
+func (c *registrationCounter) registrations() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.count
+}
+
+func TestPumpCatchesUpWithFailedProjectionEvents(t *testing.T) {
+	bus := messaging.NewMemoryBus()
+	counter := &registrationCounter{}
+	pump := messaging.MessagePump{
+		Source: bus,
+		Events: bus,
+		Handler: messaging.MessageHandler{
+			EventUpdater: bus,
+			Validator:    &auth.EmailValidator{Repository: accounts{}},
+		},
+		Projections: []*projection.Runner{
+			projection.NewRunner(counter, &projection.MemoryCheckpoints{}, bus),
+		},
+		CatchUpInterval: 10 * time.Millisecond,
+	}
+	assert.NoError(t, pump.Start(t.Context()))
+
+	assert.NoError(t, bus.Publish(t.Context(), domain.CreateAccountRegisteredEvent(newAccount(), nil)))
+	assert.NoError(t, bus.Wait(t.Context()))
+	assert.Eventually(t, func() bool { return counter.registrations() == 1 },
+		time.Second, 10*time.Millisecond, "Failed event applied by catch up")
+}
+
+// registrations is a projection counting the AccountRegistered events of each
+// account, keeping the counts in CouchDB, shared by all pumps.
+type registrations struct {
+	name  string
+	store projection.Store[int]
+}
+
+func (p registrations) Name() string { return p.name }
+
+func (registrations) EventTypes() []string {
+	return []string{core.EventTypeNameOf[domain.AccountRegistered]()}
+}
+
+func (p registrations) Apply(ctx context.Context, e core.DomainEvent) error {
+	id := string(e.Body.(domain.AccountRegistered).AccountID)
+	n, err := p.store.Get(ctx, id)
+	if err != nil && !errors.Is(err, core.ErrNotFound) {
+		return err
+	}
+	return p.store.Put(ctx, id, n+1)
+}
+
+func (p registrations) Reset(ctx context.Context) error { return p.store.Reset(ctx) }
+
+func TestPumpsWithLeasesApplyProjectionEventsOnce(t *testing.T) {
+	ctx := t.Context()
+	corerepo.AssertInitialized()
+	db := &corerepo.DefaultConnection
+	events := corerepo.DefaultDomainEventRepo
+	// A new projection for each test run, as the lease and checkpoint of the
+	// projection outlive the pumps
+	name := "messaging_test.registrations_" + gonanoid.Must()
+	p := registrations{name, projection.NewCouchDBStore[int](db, name)}
+	for range 3 {
+		pump := messaging.MessagePump{
+			Source: corerepo.MessageSource{DomainEventRepository: events, DB: db},
+			Events: events,
+			Handler: messaging.MessageHandler{
+				EventUpdater: events,
+				Validator: &auth.EmailValidator{
+					Repository: accounts{},
+					Sender:     &countingSender{},
+				},
+			},
+			// Each instance has a runner of the same projection
+			Projections: []*projection.Runner{
+				projection.NewRunner(p, projection.CouchDBCheckpoints{DB: db}, events),
+			},
+			Leases: lease.CouchDBStore{DB: db},
+		}
+		assert.NoError(t, pump.Start(ctx))
+	}
+
+	// Events are stored concurrently, and created out of order, like events of
+	// entities stored by different instances
+	ids := make([]domain.AccountID, 10)
+	now := time.Now()
+	var wg sync.WaitGroup
+	for i := range ids {
+		acc := newAccount()
+		ids[i] = acc.ID
+		event := domain.CreateAccountRegisteredEvent(acc, nil)
+		event.CreatedAt = now.Add(time.Duration(len(ids)-i) * time.Millisecond)
+		wg.Go(func() {
+			_, err := db.Insert(ctx, "messaging_test:"+string(acc.ID),
+				corerepo.DocumentWithEvents[domain.AccountID]{
+					Document: acc.ID,
+					Events:   []core.DomainEvent{event},
+				})
+			assert.NoError(t, err)
+		})
+	}
+	wg.Wait()
+
+	applied := func() (res int) {
+		for _, id := range ids {
+			if n, err := p.store.Get(ctx, string(id)); err == nil && n > 0 {
+				res++
+			}
+		}
+		return
+	}
+	assert.Eventually(t, func() bool { return applied() == len(ids) },
+		5*time.Second, 50*time.Millisecond, "All events applied")
+	time.Sleep(100 * time.Millisecond) // Allow duplicate applies to happen
+	for _, id := range ids {
+		n, err := p.store.Get(ctx, string(id))
+		assert.NoError(t, err)
+		assert.Equal(t, 1, n, "Times event of %s was applied", id)
+	}
+}
@@ -0,0 +1,83 @@
+package messaging
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"harmony/internal/core"
+)
+
+// DefaultSchedulerInterval is how often the [MessagePump] checks for scheduled
+// events that are due.
+const DefaultSchedulerInterval = time.Second
+
+// ScheduledEventCanceller decides whether a scheduled event is still relevant
+// when it is due, e.g., a reminder to validate an email address is moot when
+// the address has been validated.
+type ScheduledEventCanceller interface {
+	CancelScheduledEvent(context.Context, core.DomainEvent) (bool, error)
+}
+
+// Scheduler keeps scheduled events until they are due. Events are only kept in
+// memory; unpublished events are delivered again by the [EventStream] when the
+// application restarts, and scheduled again.
+type Scheduler struct {
+	// Clock decides when events are due. The system clock is used if nil.
+	Clock core.Clock
+	// Interval is how often the pump checks for due events.
+	Interval time.Duration
+
+	mu      sync.Mutex
+	pending map[core.EventID]core.DomainEvent
+}
+
+func NewScheduler() *Scheduler {
+	return &Scheduler{Interval: DefaultSchedulerInterval}
+}
+
+func (s *Scheduler) interval() time.Duration {
+	if s.Interval <= 0 {
+		return DefaultSchedulerInterval
+	}
+	return s.Interval
+}
+
+// Schedule keeps the event until it is due. Scheduling an event again replaces
+// it.
+func (s *Scheduler) Schedule(e core.DomainEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pending == nil {
+		s.pending = make(map[core.EventID]core.DomainEvent)
+	}
+	s.pending[e.ID] = e
+}
+
+// Due removes and returns the events that are due, ordered by the time they
+// were scheduled for.
+func (s *Scheduler) Due() []core.DomainEvent {
+	now := core.Now(s.Clock)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var res []core.DomainEvent
+	for id, e := range s.pending {
+		if e.Due(now) {
+			res = append(res, e)
+			delete(s.pending, id)
+		}
+	}
+	slices.SortFunc(res, func(a, b core.DomainEvent) int {
+		return cmp.Or(a.DeliverAfter.Compare(*b.DeliverAfter), cmp.Compare(a.ID, b.ID))
+	})
+	return res
+}
+
+// Pending returns the number of events waiting to be due.
+func (s *Scheduler) Pending() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.pending)
+}
@@ -0,0 +1,178 @@
+package messaging_test
+
+import (
+	"context"
+	"net/mail"
+	"testing"
+	"time"
+
+	"harmony/internal/auth"
+	"harmony/internal/auth/domain"
+	"harmony/internal/core"
+	"harmony/internal/messaging"
+	"harmony/internal/testing/clocktest"
+
+	gonanoid "github.com/matoous/go-nanoid/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+type accounts map[domain.AccountID]domain.Account
+
+func (a accounts) Get(_ context.Context, id domain.AccountID) (domain.Account, error) {
+	if acc, ok := a[id]; ok {
+		return acc, nil
+	}
+	return domain.Account{}, core.ErrNotFound
+}
+
+func (a accounts) Update(_ context.Context, acc domain.Account) (domain.Account, error) {
+	if _, ok := a[acc.ID]; !ok {
+		return acc, core.ErrNotFound
+	}
+	a[acc.ID] = acc
+	return acc, nil
+}
+
+func newAccount() domain.Account {
+	return domain.Account{
+		ID: domain.AccountID(gonanoid.Must()),
+		Email: domain.NewUnvalidatedEmail(mail.Address{
+			Address: gonanoid.MustGenerate("abcdefghijklmnopqrstuvwxyz", 20) + "@example.com",
+		}),
+	}
+}
+
+func TestSchedulerReturnsDueEventsInOrder(t *testing.T) {
+	c := clocktest.New()
+	scheduler := messaging.Scheduler{Clock: c}
+	later := core.NewScheduledEvent(c, domain.EmailValidationReminder{}, c.Now().Add(2*time.Hour))
+	sooner := core.NewScheduledEvent(c, domain.EmailValidationReminder{}, c.Now().Add(time.Hour))
+	scheduler.Schedule(later)
+	scheduler.Schedule(sooner)
+
+	assert.Empty(t, scheduler.Due())
+	c.Advance(3 * time.Hour)
+	assert.Equal(t, []core.EventID{sooner.ID, later.ID}, eventIDs(scheduler.Due()))
+	assert.Zero(t, scheduler.Pending())
+}
+
+func eventIDs(events []core.DomainEvent) []core.EventID {
+	res := make([]core.EventID, len(events))
+	for i, e := range events {
+		res[i] = e.ID
+	}
+	return res
+}
+
+type scheduledEventTest struct {
+	clock     *clocktest.FakeClock
+	accounts  accounts
+	sender    *countingSender
+	bus       *messaging.MemoryBus
+	scheduler *messaging.Scheduler
+}
+
+func startScheduledEventTest(t *testing.T) scheduledEventTest {
+	c := clocktest.New()
+	s := scheduledEventTest{
+		clock:     c,
+		accounts:  accounts{},
+		sender:    &countingSender{},
+		bus:       messaging.NewMemoryBus(),
+		scheduler: &messaging.Scheduler{Clock: c, Interval: time.Millisecond},
+	}
+	pump := messaging.MessagePump{
+		Source: s.bus,
+		Events: s.bus,
+		Handler: messaging.MessageHandler{
+			EventUpdater: s.bus,
+			Validator: &auth.EmailValidator{
+				Repository: s.accounts,
+				Sender:     s.sender,
+				Clock:      c,
+			},
+			Clock: c,
+		},
+		Scheduler: s.scheduler,
+	}
+	assert.NoError(t, pump.Start(t.Context()))
+	return s
+}
+
+// scheduleReminder publishes a reminder for the account, and waits until the
+// pump has scheduled it.
+func (s scheduledEventTest) scheduleReminder(t *testing.T, acc domain.Account) core.EventID {
+	t.Helper()
+	event := acc.ScheduleEmailValidationReminder(s.clock)
+	assert.NoError(t, s.bus.Publish(t.Context(), event))
+	assert.NoError(t, s.bus.Wait(t.Context()))
+	return event.ID
+}
+
+func (s scheduledEventTest) published(id core.EventID) bool {
+	for _, e := range s.bus.Events() {
+		if e.ID == id {
+			return e.PublishedAt != nil
+		}
+	}
+	return false
+}
+
+func TestPumpCancelsMootScheduledEvents(t *testing.T) {
+	for name, update := range map[string]func(accounts, domain.Account){
+		"Email validated": func(a accounts, acc domain.Account) {
+			acc.Email = acc.Email.Verified()
+			a[acc.ID] = acc
+		},
+		"Account deleted": func(a accounts, acc domain.Account) { delete(a, acc.ID) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			s := startScheduledEventTest(t)
+			acc := newAccount()
+			s.accounts[acc.ID] = acc
+			id := s.scheduleReminder(t, acc)
+			assert.Equal(t, 1, s.scheduler.Pending())
+
+			update(s.accounts, acc)
+			s.clock.Advance(domain.EmailValidationReminderDelay)
+
+			assert.Eventually(t, func() bool { return s.published(id) },
+				time.Second, time.Millisecond, "Cancelled event marked as published")
+			assert.Zero(t, s.scheduler.Pending())
+		})
+	}
+}
+
+func TestPumpDoesNotDeliverScheduledEventsBeforeDue(t *testing.T) {
+	s := startScheduledEventTest(t)
+	acc := newAccount()
+	s.accounts[acc.ID] = acc
+	id := s.scheduleReminder(t, acc)
+
+	s.clock.Advance(domain.EmailValidationReminderDelay - time.Nanosecond)
+	time.Sleep(10 * time.Millisecond)
+	assert.False(t, s.published(id), "Event published before due")
+	assert.Equal(t, 1, s.scheduler.Pending())
+}
+
+func TestPumpDeliversScheduledEventsWhenDue(t *testing.T) {
+	s := startScheduledEventTest(t)
+	acc := newAccount()
+	acc.Email.Address.Address = "j+d@example.com"
+	acc.StartEmailValidationChallenge(s.clock)
+	expired := acc.Email.Challenge.Code
+	s.accounts[acc.ID] = acc
+	id := s.scheduleReminder(t, acc)
+
+	s.clock.Advance(domain.EmailValidationReminderDelay)
+	assert.Eventually(t, func() bool { return s.published(id) },
+		time.Second, time.Millisecond, "Reminder sent, and marked as published")
+
+	challenge := s.accounts[acc.ID].Email.Challenge
+	if assert.NotNil(t, challenge, "New challenge started") {
+		assert.NotEqual(t, expired, challenge.Code, "New code")
+		assert.False(t, challenge.Expired(s.clock), "New challenge expired")
+		assert.Contains(t, s.sender.last(), string(challenge.Code), "Email contains new code")
+	}
+	assert.Contains(t, s.sender.last(), "validate-email?email=j%2Bd%40example.com")
+}
@@ -0,0 +1,45 @@
+// Package clocktest provides a [core.Clock] controlled by tests, so behaviour
+// depending on time, e.g., expiry, can be tested without sleeping.
+package clocktest
+
+import (
+	"sync"
+	"time"
+
+	"harmony/internal/core"
+)
+
+// FakeClock is a [core.Clock] that only moves when told to. It is safe for
+// concurrent use, e.g., by a message pump running in the background.
+type FakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+var _ core.Clock = (*FakeClock)(nil)
+
+// New returns a clock starting at the current time.
+func New() *FakeClock { return At(time.Now()) }
+
+// At returns a clock starting at t.
+func At(t time.Time) *FakeClock { return &FakeClock{now: t.UTC()} }
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the clock forward.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// Set moves the clock to t.
+func (c *FakeClock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = t.UTC()
+}
@@ -32,16 +32,6 @@ type allDocsValue struct {
 	Rev string `json:"rev"`
 }
 
-type DeleteDoc struct {
-	ID      string `json:"_id"`
-	Rev     string `json:"_rev"`
-	Deleted bool   `json:"_deleted"`
-}
-
-type BulkDocs struct {
-	Docs []DeleteDoc `json:"docs"`
-}
-
 type couchOption func(*CouchHelper)
 
 func WithT(t testing.TB) couchOption { return func(c *CouchHelper) { c.t.t = t } }
@@ -69,30 +59,16 @@ func (h CouchHelper) DeleteAllDocs(ctx context.Context) {
 	if err != nil {
 		h.t.Errorf("couchdbtest: cannot initialize: %v", err)
 	}
-	rows := docs.Rows
-	var deleteDoc BulkDocs
-	deleteDoc.Docs = make([]DeleteDoc, 0, len(rows))
-	for _, d := range rows {
+	deleted := make([]any, 0, len(docs.Rows))
+	for _, d := range docs.Rows {
 		if strings.HasPrefix(d.ID, "_design/") {
 			continue
 		}
-		deleteDoc.Docs = append(deleteDoc.Docs, DeleteDoc{ID: d.ID, Rev: d.Value.Rev,
-			Deleted: true,
-		})
+		deleted = append(deleted, corerepo.DeletedDocument(d.ID, d.Value.Rev))
 	}
-	resp, err := conn.RawPost(context.Background(), "_bulk_docs", deleteDoc)
-	if err != nil {
+	if _, err := conn.BulkDocs(context.Background(), deleted...); err != nil {
 		h.t.Errorf("couchdbtest: cannot delete: %v", err)
 	}
-	defer resp.Body.Close()
-	switch resp.StatusCode {
-	case 200, 201:
-		return
-	default:
-		h.t.Errorf(
-			"couchdbtest: DeleteAllDocs: unexpected status code: %d",
-			resp.StatusCode)
-	}
 }
 
 func init() {
@@ -51,6 +51,11 @@ func WithName(name string) InitAccountOption {
 	}
 }
 
+// WithRole grants the role to the account.
+func WithRole(role domain.Role) InitAccountOption {
+	return func(acc *domain.Account) { acc.GrantRole(role) }
+}
+
 // WithEmailValidation makes sure that the email address has been validated, so
 // the account can be treated as "authenticated".
 //
@@ -58,8 +63,8 @@ func WithName(name string) InitAccountOption {
 func WithEmailValidation() InitAccountOption {
 	return func(acc *domain.Account) {
 		if !acc.Email.Validated {
-			c := acc.Email.NewChallenge()
-			if err := acc.ValidateEmail(c.Code); err != nil {
+			c := acc.Email.NewChallenge(nil)
+			if err := acc.ValidateEmail(c.Code, nil); err != nil {
 				panic("WithValidatedEmail: error validating email: " + err.Error())
 			}
 		}
@@ -0,0 +1,58 @@
+// Package eventstest runs the message pump in memory, so tests can verify the
+// effects of domain events without CouchDB.
+package eventstest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"harmony/internal/auth"
+	"harmony/internal/core/projection"
+	"harmony/internal/messaging"
+)
+
+// WaitTimeout is the time [WaitForEvents] waits for events to be processed.
+const WaitTimeout = 5 * time.Second
+
+// StartPump starts a message pump on a new [messaging.MemoryBus], delivering
+// events to the validator and the projections. The pump stops when the test
+// ends. Events are published to the bus by assigning it as Publisher on a
+// repotest.RepositoryStub.
+//
+// Projection runners without an EventReader read historical events from the
+// bus.
+func StartPump(
+	t testing.TB,
+	validator *auth.EmailValidator,
+	projections ...*projection.Runner,
+) *messaging.MemoryBus {
+	t.Helper()
+	bus := messaging.NewMemoryBus()
+	for _, p := range projections {
+		if p.Events == nil {
+			p.Events = bus
+		}
+	}
+	pump := messaging.MessagePump{
+		Source:      bus,
+		Events:      bus,
+		Handler:     messaging.MessageHandler{EventUpdater: bus, Validator: validator},
+		Projections: projections,
+	}
+	if err := pump.Start(t.Context()); err != nil {
+		t.Fatalf("eventstest: start pump: %v", err)
+	}
+	return bus
+}
+
+// WaitForEvents waits until all events published to the bus have been
+// processed, failing the test after [WaitTimeout].
+func WaitForEvents(t testing.TB, bus *messaging.MemoryBus) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(t.Context(), WaitTimeout)
+	defer cancel()
+	if err := bus.Wait(ctx); err != nil {
+		t.Fatalf("eventstest: wait for events: %v", err)
+	}
+}
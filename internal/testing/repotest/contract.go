@@ -0,0 +1,131 @@
+package repotest
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"harmony/internal/core"
+	"harmony/internal/core/corerepo"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Repository is the common interface of repositories, implemented by both
+// [RepositoryStub] and [corerepo.Repository].
+type Repository[T any, ID comparable] interface {
+	Insert(context.Context, core.UseCaseResult[T]) (T, error)
+	Get(context.Context, ID) (T, error)
+	Update(context.Context, T) (T, error)
+	UpdateWithEvents(context.Context, core.UseCaseResult[T]) (T, error)
+}
+
+// ContractEvent is a domain event used by [RunRepositoryContract] to verify
+// that events are stored.
+type ContractEvent struct {
+	Value string `json:"value"`
+}
+
+func init() {
+	core.RegisterEventType(reflect.TypeFor[ContractEvent](), "repotest.ContractEvent")
+}
+
+// RepositoryContract describes how to test a repository implementation with
+// [RunRepositoryContract].
+type RepositoryContract[T any, ID comparable] struct {
+	// Repository is the repository under test.
+	Repository Repository[T, ID]
+	// NewEntity creates a new valid entity with a unique ID.
+	NewEntity func() T
+	// ID returns the ID of an entity.
+	ID func(T) ID
+	// Modify changes the entity, so it is different from the stored version.
+	Modify func(*T)
+	// StoredEvents returns the events stored for the entity with the ID.
+	StoredEvents func(t testing.TB, id ID) []core.DomainEvent
+}
+
+// RunRepositoryContract verifies the behaviour shared by all repositories.
+// Running the contract against both a real repository and the fake used in
+// tests of the domain layer ensures that the fake doesn't drift from the real
+// behaviour.
+func RunRepositoryContract[T any, ID comparable](t *testing.T, c RepositoryContract[T, ID]) {
+	t.Helper()
+	repo := c.Repository
+
+	insert := func(t *testing.T, events ...core.DomainEvent) T {
+		t.Helper()
+		uc := core.UseCaseOfEntity(c.NewEntity())
+		uc.Events = events
+		entity, err := repo.Insert(t.Context(), uc)
+		assert.NoError(t, err, "Inserting entity")
+		return entity
+	}
+
+	t.Run("Get returns inserted entity", func(t *testing.T) {
+		entity := insert(t)
+		reloaded, err := repo.Get(t.Context(), c.ID(entity))
+		assert.NoError(t, err)
+		assert.Equal(t, entity, reloaded)
+	})
+
+	t.Run("Insert existing entity returns ErrConflict", func(t *testing.T) {
+		entity := insert(t)
+		_, err := repo.Insert(t.Context(), core.UseCaseOfEntity(entity))
+		assert.ErrorIs(t, err, corerepo.ErrConflict)
+	})
+
+	t.Run("Get missing entity returns ErrNotFound", func(t *testing.T) {
+		_, err := repo.Get(t.Context(), c.ID(c.NewEntity()))
+		assert.ErrorIs(t, err, core.ErrNotFound)
+	})
+
+	t.Run("Update stores changes", func(t *testing.T) {
+		entity := insert(t)
+		original := entity
+		c.Modify(&entity)
+		updated, err := repo.Update(t.Context(), entity)
+		assert.NoError(t, err)
+		reloaded, err := repo.Get(t.Context(), c.ID(entity))
+		assert.NoError(t, err)
+		assert.Equal(t, updated, reloaded)
+		assert.NotEqual(t, original, reloaded)
+	})
+
+	t.Run("Update stale entity returns ErrConflict", func(t *testing.T) {
+		entity := insert(t)
+		stale := entity
+		c.Modify(&entity)
+		_, err := repo.Update(t.Context(), entity)
+		assert.NoError(t, err)
+
+		c.Modify(&stale)
+		_, err = repo.Update(t.Context(), stale)
+		assert.ErrorIs(t, err, corerepo.ErrConflict)
+	})
+
+	t.Run("Insert stores events", func(t *testing.T) {
+		event := core.NewDomainEvent(ContractEvent{"insert"})
+		entity := insert(t, event)
+		assert.Contains(t, eventIDs(c.StoredEvents(t, c.ID(entity))), event.ID)
+	})
+
+	t.Run("UpdateWithEvents stores events", func(t *testing.T) {
+		entity := insert(t)
+		c.Modify(&entity)
+		uc := core.UseCaseOfEntity(entity)
+		event := core.NewDomainEvent(ContractEvent{"update"})
+		uc.AddEvent(event)
+		_, err := repo.UpdateWithEvents(t.Context(), uc)
+		assert.NoError(t, err)
+		assert.Contains(t, eventIDs(c.StoredEvents(t, c.ID(entity))), event.ID)
+	})
+}
+
+func eventIDs(events []core.DomainEvent) []core.EventID {
+	res := make([]core.EventID, len(events))
+	for i, e := range events {
+		res[i] = e.ID
+	}
+	return res
+}
@@ -2,24 +2,38 @@ package repotest
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"harmony/internal/auth"
 	"harmony/internal/core"
+	"harmony/internal/core/corerepo"
 	"reflect"
 	"testing"
 )
 
-var ErrDuplicateKey = errors.New("duplicate key")
+// ErrDuplicateKey is returned when inserting an entity with an existing ID. It
+// is an [corerepo.ErrConflict], like the error returned by CouchDB.
+var ErrDuplicateKey = fmt.Errorf("duplicate key: %w", corerepo.ErrConflict)
 
 type EntityTranslator[T, ID any] interface {
 	ID(entity T) ID
 }
 
+// RevisionTranslator is implemented by translators of entities carrying a
+// document revision, e.g., [corerepo.DocumentMapper]. The stub then assigns
+// new revisions on insert and update, and rejects updates of stale entities
+// with [corerepo.ErrConflict], like the real repositories.
+type RevisionTranslator[T any] interface {
+	Rev(entity T) string
+	SetRev(entity *T, rev string)
+}
+
 type RepositoryStub[T any, ID comparable] struct {
 	Translator EntityTranslator[T, ID]
 	Entities   map[ID]*T
 	Events     []core.DomainEvent
+	// Publisher, if set, receives the events stored by the stub, e.g., a
+	// messaging.MemoryBus delivering the events to message handlers.
+	Publisher core.EventPublisher
 
 	t testing.TB
 }
@@ -53,18 +67,47 @@ func (s *RepositoryStub[T, ID]) Inject(e *T) error {
 }
 
 func (s *RepositoryStub[T, ID]) InsertEntity(_ context.Context, e T) error {
+	_, err := s.insert(e)
+	return err
+}
+
+func (s *RepositoryStub[T, ID]) insert(e T) (T, error) {
 	ptr := new(T)
 	*ptr = e
-	return s.Inject(ptr)
+	s.newRev(ptr)
+	if err := s.Inject(ptr); err != nil {
+		return e, err
+	}
+	return *ptr, nil
+}
+
+// newRev assigns a new revision to the entity, if the translator supports
+// revisions.
+func (s RepositoryStub[T, ID]) newRev(e *T) {
+	if revs, ok := s.Translator.(RevisionTranslator[T]); ok {
+		revs.SetRev(e, core.NewID())
+	}
 }
 
 func (s *RepositoryStub[T, ID]) Insert(ctx context.Context, e core.UseCaseResult[T]) (T, error) {
-	entity := e.Entity
-	err := s.InsertEntity(ctx, entity)
-	s.Events = append(s.Events, e.Events...)
+	entity, err := s.insert(e.Entity)
+	if err == nil {
+		err = s.storeEvents(ctx, e.Events)
+	}
 	return entity, err
 }
 
+// storeEvents records the events, and publishes them if the stub has a
+// Publisher.
+func (s *RepositoryStub[T, ID]) storeEvents(ctx context.Context, events []core.DomainEvent) error {
+	events = core.WithEventMetadata(ctx, events)
+	s.Events = append(s.Events, events...)
+	if s.Publisher == nil || len(events) == 0 {
+		return nil
+	}
+	return s.Publisher.Publish(ctx, events...)
+}
+
 func (s RepositoryStub[T, ID]) Get(_ context.Context, id ID) (res T, err error) {
 	if tmp, found := s.Entities[id]; found {
 		res = *tmp
@@ -81,10 +124,28 @@ func (s RepositoryStub[T, ID]) Update(_ context.Context, e T) (T, error) {
 		var dummy T
 		return dummy, auth.ErrNotFound
 	}
+	if revs, ok := s.Translator.(RevisionTranslator[T]); ok {
+		if revs.Rev(e) != revs.Rev(*existing) {
+			return e, corerepo.ErrConflict
+		}
+	}
+	s.newRev(&e)
 	*existing = e
 	return e, nil
 }
 
+// UpdateWithEvents updates the entity, and records the events of the use case.
+func (s *RepositoryStub[T, ID]) UpdateWithEvents(
+	ctx context.Context,
+	e core.UseCaseResult[T],
+) (T, error) {
+	res, err := s.Update(ctx, e.Entity)
+	if err == nil {
+		err = s.storeEvents(ctx, e.Events)
+	}
+	return res, err
+}
+
 func (s RepositoryStub[T, ID]) TestingT() testing.TB          { return s.t }
 func (s RepositoryStub[T, ID]) AllEvents() []core.DomainEvent { return s.Events }
 func (s RepositoryStub[T, ID]) All() (res []*T) {
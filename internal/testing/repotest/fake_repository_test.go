@@ -2,6 +2,7 @@ package repotest_test
 
 import (
 	"context"
+	"harmony/internal/core"
 	"harmony/internal/testing/repotest"
 	"testing"
 
@@ -25,3 +26,28 @@ func TestFakeRepositoryDisallowsDuplicates(t *testing.T) {
 	assert.NoError(t, err1, "First insert")
 	assert.ErrorIs(t, err2, repotest.ErrDuplicateKey)
 }
+
+type RevTestType struct {
+	ID    string
+	Rev   string
+	Value string
+}
+
+type RevTestTypeTranslator struct{}
+
+func (RevTestTypeTranslator) ID(e RevTestType) string           { return e.ID }
+func (RevTestTypeTranslator) Rev(e RevTestType) string          { return e.Rev }
+func (RevTestTypeTranslator) SetRev(e *RevTestType, rev string) { e.Rev = rev }
+
+func TestRepositoryStubContract(t *testing.T) {
+	repo := repotest.NewRepositoryStub(t, RevTestTypeTranslator{})
+	repotest.RunRepositoryContract(t, repotest.RepositoryContract[RevTestType, string]{
+		Repository: &repo,
+		NewEntity:  func() RevTestType { return RevTestType{ID: gonanoid.Must()} },
+		ID:         RevTestTypeTranslator{}.ID,
+		Modify:     func(e *RevTestType) { e.Value = gonanoid.Must() },
+		StoredEvents: func(testing.TB, string) []core.DomainEvent {
+			return repo.Events
+		},
+	})
+}
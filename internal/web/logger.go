@@ -48,9 +48,14 @@ func logHeader(h http.Header) log.Attr {
 	return log.Group("header", attrs...)
 }
 
+// Log logs requests and responses. Each request is assigned an ID, added to
+// log entries, and used as correlation ID of domain events created while
+// processing the request.
 func Log(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		log.ContextWith(&r, "reqID", core.NewID())
+		reqID := core.NewID()
+		log.ContextWith(&r, "reqID", reqID)
+		r = r.WithContext(core.WithCorrelationID(r.Context(), reqID))
 
 		rec := &StatusRecorder{ResponseWriter: w}
 		start := time.Now()
@@ -4,6 +4,8 @@ import (
 	"net/http"
 	"path/filepath"
 
+	adminrouter "harmony/internal/admin/router"
+	"harmony/internal/auth/domain"
 	authrouter "harmony/internal/auth/router"
 	hostrouter "harmony/internal/host/router"
 	"harmony/internal/web"
@@ -26,6 +28,7 @@ type Server struct {
 	AuthMiddlewares authrouter.Middlewares
 	AuthRouter      *authrouter.AuthRouter
 	HostRouter      *hostrouter.HostRouter
+	AdminRouter     *adminrouter.AdminRouter
 }
 
 // Init implements interface [surgeon.Initer].
@@ -34,12 +37,16 @@ func (s *Server) Init() {
 	mux.Handle("GET /{$}", templ.Handler(views.Index()))
 	mux.Handle("/auth/", http.StripPrefix("/auth", s.AuthRouter))
 	mux.Handle("GET /host", authrouter.RequireAuth(s.HostRouter.Index()))
+	mux.Handle("/admin/", authrouter.RequirePermission(domain.PermViewAccounts)(
+		http.StripPrefix("/admin", s.AdminRouter),
+	))
 	mux.Handle(
 		"GET /static/",
 		http.StripPrefix("/static", http.FileServer(
 			http.Dir(staticFilesPath()))),
 	)
 	s.Handler = web.ApplyMiddlewares(mux,
+		web.Tracing,
 		web.Logger,
 		noCache,
 		web.CSRFMiddleware,
@@ -49,8 +56,9 @@ func (s *Server) Init() {
 
 func New() *Server {
 	res := &Server{
-		AuthRouter: authrouter.New(),
-		HostRouter: hostrouter.New(),
+		AuthRouter:  authrouter.New(),
+		HostRouter:  hostrouter.New(),
+		AdminRouter: adminrouter.New(),
 	}
 	res.Init()
 	return res
@@ -0,0 +1,48 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"harmony/internal/infrastructure/log"
+	"harmony/internal/infrastructure/trace"
+)
+
+// parseTraceParent parses a W3C traceparent header,
+// "00-<trace-id>-<parent-id>-<flags>".
+func parseTraceParent(h string) (traceID, parentID string, ok bool) {
+	parts := strings.Split(h, "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return "", "", false
+	}
+	return parts[1], parts[2], true
+}
+
+// Trace starts a span for each request, continuing the trace of the caller if
+// the request has a traceparent header. Spans of operations, e.g., database
+// calls, using the request context become children of the request span.
+func Trace(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := fmt.Sprintf("HTTP %s", r.Method)
+		ctx := r.Context()
+		var span *trace.ActiveSpan
+		if traceID, parentID, ok := parseTraceParent(r.Header.Get("traceparent")); ok {
+			ctx, span = trace.StartRemote(ctx, name, traceID, parentID)
+		} else {
+			ctx, span = trace.Start(ctx, name)
+		}
+		defer span.End()
+		span.SetAttr("http.method", r.Method)
+		span.SetAttr("http.path", r.URL.Path)
+		r = r.WithContext(ctx)
+		log.ContextWith(&r, "traceID", span.TraceID())
+
+		rec := &StatusRecorder{ResponseWriter: w}
+		h.ServeHTTP(rec, r)
+		span.SetAttr("http.status_code", strconv.Itoa(rec.Code()))
+	})
+}
+
+var Tracing = MiddlewareFunc(Trace)